@@ -205,6 +205,9 @@ func (m *LRUCacher) PutBean(tableName string, id string, obj interface{}) {
 	var el *list.Element
 	var ok bool
 
+	if _, ok = m.idIndex[tableName]; !ok {
+		m.idIndex[tableName] = make(map[string]*list.Element)
+	}
 	if el, ok = m.idIndex[tableName][id]; !ok {
 		el = m.idList.PushBack(newIDNode(tableName, id))
 		m.idIndex[tableName][id] = el
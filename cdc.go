@@ -0,0 +1,315 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"time"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+const (
+	// DefaultCDCBatchSize is the number of the rows fetched by one query of CDCPoller
+	DefaultCDCBatchSize = 100
+
+	// DefaultCDCLag is the default lag of CDCPoller
+	DefaultCDCLag = time.Second
+)
+
+// ErrCDCNoMarkColumn is returned when the bean has no updated or version column
+var ErrCDCNoMarkColumn = errors.New("cdc needs an updated or version column")
+
+func init() {
+	gob.Register(time.Time{})
+}
+
+// CDCCheckpoint stores the high-water marks of a CDCPoller
+type CDCCheckpoint struct {
+	Name        string    `xorm:"pk varchar(100)"`
+	Mark        string    `xorm:"text"`
+	DeletedMark string    `xorm:"text"`
+	Updated     time.Time `xorm:"updated"`
+}
+
+// TableName implements TableName interface
+func (CDCCheckpoint) TableName() string {
+	return "xorm_cdc_checkpoint"
+}
+
+// CDCFunc handles a changed row, deleted is true if the row is soft deleted
+type CDCFunc func(bean interface{}, deleted bool) error
+
+// CDCPoller captures the changed rows by polling the updated or version
+// column. The high-water mark is the value of the column and the primary
+// keys of the last handled row, so the rows with the same mark are ordered
+// by the primary keys and never skipped. The marks are stored in the
+// checkpoint table with the name of the poller.
+//
+// Soft deleting doesn't change the updated column, so the rows are also
+// polled by the deleted column with another mark and handled after the
+// updated rows in every Poll.
+//
+// The rows with a time mark are polled only when the mark is older than the
+// lag, so the rows changed later within the same second of the database
+// time will not be skipped.
+//
+// The version column could be used as the mark only if the versions are
+// increasing over the whole table, the updated column is preferred.
+type CDCPoller struct {
+	engine     *Engine
+	name       string
+	beanType   reflect.Type
+	table      *core.Table
+	tableName  string
+	markCol    *core.Column
+	deletedCol *core.Column
+	batchSize  int
+	lag        time.Duration
+}
+
+// NewCDCPoller creates a CDCPoller of the bean's table, the checkpoint table
+// will be synced.
+func (engine *Engine) NewCDCPoller(name string, bean interface{}) (*CDCPoller, error) {
+	v := rValue(bean)
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("needs a pointer to a struct")
+	}
+	table, err := engine.autoMapType(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.PrimaryKeys) == 0 {
+		return nil, errors.New("cdc needs primary keys")
+	}
+
+	markCol := table.UpdatedColumn()
+	if markCol == nil {
+		markCol = table.VersionColumn()
+	}
+	if markCol == nil {
+		return nil, ErrCDCNoMarkColumn
+	}
+
+	if err := engine.Sync2(new(CDCCheckpoint)); err != nil {
+		return nil, err
+	}
+
+	return &CDCPoller{
+		engine:     engine,
+		name:       name,
+		beanType:   v.Type(),
+		table:      table,
+		tableName:  engine.TableName(bean, true),
+		markCol:    markCol,
+		deletedCol: table.DeletedColumn(),
+		batchSize:  DefaultCDCBatchSize,
+		lag:        DefaultCDCLag,
+	}, nil
+}
+
+// BatchSize sets the number of the rows fetched by one query
+func (poller *CDCPoller) BatchSize(size int) *CDCPoller {
+	if size > 0 {
+		poller.batchSize = size
+	}
+	return poller
+}
+
+// Lag sets the lag of the time marks, see CDCPoller
+func (poller *CDCPoller) Lag(lag time.Duration) *CDCPoller {
+	poller.lag = lag
+	return poller
+}
+
+// Reset removes the checkpoint, the next Poll will start from the beginning
+func (poller *CDCPoller) Reset() error {
+	_, err := poller.engine.Delete(&CDCCheckpoint{Name: poller.name})
+	return err
+}
+
+// Run polls the changes every interval until the context is done or fun returns an error
+func (poller *CDCPoller) Run(ctx context.Context, interval time.Duration, fun CDCFunc) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := poller.Poll(fun); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll handles all the rows changed since the last checkpoint in order and
+// returns the number of the handled rows. The checkpoint is saved after
+// every batch, or at the last handled row if fun returns an error, so
+// a row may be handled again only if saving the checkpoint failed.
+func (poller *CDCPoller) Poll(fun CDCFunc) (int, error) {
+	var checkpoint = CDCCheckpoint{Name: poller.name}
+	has, err := poller.engine.Get(&checkpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	total, err := poller.poll(&checkpoint, &checkpoint.Mark, poller.markCol, &has, fun)
+	if err != nil || poller.deletedCol == nil {
+		return total, err
+	}
+
+	cnt, err := poller.poll(&checkpoint, &checkpoint.DeletedMark, poller.deletedCol, &has, fun)
+	return total + cnt, err
+}
+
+// poll handles the rows changed after the mark of the column
+func (poller *CDCPoller) poll(checkpoint *CDCCheckpoint, mark *string, col *core.Column, has *bool, fun CDCFunc) (int, error) {
+	var total int
+	for {
+		cond, err := poller.markCond(col, *mark)
+		if err != nil {
+			return total, err
+		}
+		if poller.lag > 0 && col.SQLType.IsTime() {
+			cond = cond.And(builder.Lte{
+				poller.engine.Quote(col.Name): poller.engine.formatColTime(col, time.Now().Add(-poller.lag)),
+			})
+		}
+
+		var orders = []string{col.Name}
+		orders = append(orders, poller.table.PrimaryKeys...)
+
+		rows := reflect.New(reflect.SliceOf(reflect.PtrTo(poller.beanType)))
+		err = poller.engine.Table(poller.tableName).Unscoped().NoAutoCondition().
+			Where(cond).Asc(orders...).Limit(poller.batchSize).Find(rows.Interface())
+		if err != nil {
+			return total, err
+		}
+		rows = rows.Elem()
+
+		var handled int
+		for ; handled < rows.Len(); handled++ {
+			bean := rows.Index(handled).Interface()
+			deleted, err := poller.isDeleted(bean)
+			if err != nil {
+				return total, err
+			}
+			if err = fun(bean, deleted); err != nil {
+				if handled > 0 {
+					if err := poller.saveMark(checkpoint, mark, col, rows.Index(handled-1), has); err != nil {
+						return total, err
+					}
+				}
+				return total, err
+			}
+			total++
+		}
+
+		if handled > 0 {
+			if err := poller.saveMark(checkpoint, mark, col, rows.Index(handled-1), has); err != nil {
+				return total, err
+			}
+		}
+		if rows.Len() < poller.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// markCond returns the condition of the rows after the mark
+func (poller *CDCPoller) markCond(col *core.Column, mark string) (builder.Cond, error) {
+	if mark == "" {
+		if col.IsDeleted {
+			return builder.Not{poller.engine.CondDeleted(poller.engine.Quote(col.Name))}, nil
+		}
+		return builder.NewCond(), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(mark)
+	if err != nil {
+		return nil, err
+	}
+	var values core.PK
+	if err := values.FromString(string(data)); err != nil {
+		return nil, err
+	}
+
+	var cols = append([]*core.Column{col}, poller.table.PKColumns()...)
+	if len(values) != len(cols) {
+		return nil, errors.New("cdc checkpoint doesn't match the table")
+	}
+
+	// (a, b, c) > (x, y, z) is a > x OR (a = x AND (b > y OR (b = y AND c > z)))
+	var cond builder.Cond
+	for i := len(cols) - 1; i >= 0; i-- {
+		colName := poller.engine.Quote(cols[i].Name)
+		value := poller.markArg(cols[i], values[i])
+		if cond == nil {
+			cond = builder.Gt{colName: value}
+		} else {
+			cond = builder.Gt{colName: value}.Or(builder.Eq{colName: value}.And(cond))
+		}
+	}
+	return cond, nil
+}
+
+// markArg converts the mark value to the argument which could be compared
+// with the column
+func (poller *CDCPoller) markArg(col *core.Column, value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return poller.engine.formatColTime(col, t)
+	}
+	return value
+}
+
+// isDeleted returns true if the bean is soft deleted
+func (poller *CDCPoller) isDeleted(bean interface{}) (bool, error) {
+	if poller.deletedCol == nil {
+		return false, nil
+	}
+	fieldValue, err := poller.deletedCol.ValueOf(bean)
+	if err != nil {
+		return false, err
+	}
+	if t, ok := reflect.Indirect(*fieldValue).Interface().(time.Time); ok {
+		return !isTimeZero(t), nil
+	}
+	return false, nil
+}
+
+// saveMark saves the mark of the column and the primary keys of the row
+func (poller *CDCPoller) saveMark(checkpoint *CDCCheckpoint, mark *string, col *core.Column, row reflect.Value, has *bool) error {
+	row = row.Elem()
+	fieldValue, err := col.ValueOfV(&row)
+	if err != nil {
+		return err
+	}
+	pk, err := poller.engine.idOfV(row)
+	if err != nil {
+		return err
+	}
+
+	var values = append(core.PK{reflect.Indirect(*fieldValue).Interface()}, pk...)
+	s, err := values.ToString()
+	if err != nil {
+		return err
+	}
+	*mark = base64.StdEncoding.EncodeToString([]byte(s))
+
+	if *has {
+		_, err = poller.engine.ID(checkpoint.Name).AllCols().Update(checkpoint)
+	} else {
+		_, err = poller.engine.Insert(checkpoint)
+		*has = err == nil
+	}
+	return err
+}
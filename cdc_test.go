@@ -0,0 +1,114 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCDCPoller(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type CDCItem struct {
+		Id      int64
+		Name    string
+		Updated time.Time `xorm:"updated"`
+		Deleted time.Time `xorm:"deleted"`
+	}
+
+	assert.NoError(t, testEngine.Sync2(new(CDCItem)))
+	assert.NoError(t, testEngine.Sync2(new(CDCCheckpoint)))
+	_, err := testEngine.Where("1=1").Delete(new(CDCCheckpoint))
+	assert.NoError(t, err)
+
+	// the rows with the same updated time are ordered by the primary key
+	var base = time.Now().Add(-time.Hour).Truncate(time.Second)
+	var times = []time.Duration{2, 1, 1, 1, 3}
+	for i, d := range times {
+		_, err := testEngine.NoAutoTime().Insert(&CDCItem{
+			Name:    string(rune('a' + i)),
+			Updated: base.Add(d * time.Second),
+		})
+		assert.NoError(t, err)
+	}
+
+	poller, err := testEngine.(*Engine).NewCDCPoller("items", new(CDCItem))
+	assert.NoError(t, err)
+	poller.BatchSize(2).Lag(0)
+
+	var names []string
+	var fun = func(bean interface{}, deleted bool) error {
+		names = append(names, bean.(*CDCItem).Name)
+		return nil
+	}
+	cnt, err := poller.Poll(fun)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, cnt)
+	assert.EqualValues(t, []string{"b", "c", "d", "a", "e"}, names)
+
+	cnt, err = poller.Poll(fun)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	// the checkpoint is saved at the last handled row on error
+	_, err = testEngine.ID(2).NoAutoTime().Cols("name", "updated").Update(&CDCItem{Name: "b2", Updated: base.Add(4 * time.Second)})
+	assert.NoError(t, err)
+	_, err = testEngine.ID(3).NoAutoTime().Cols("name", "updated").Update(&CDCItem{Name: "c2", Updated: base.Add(5 * time.Second)})
+	assert.NoError(t, err)
+
+	names = nil
+	var errStop = errors.New("stop")
+	cnt, err = poller.Poll(func(bean interface{}, deleted bool) error {
+		if bean.(*CDCItem).Name == "c2" {
+			return errStop
+		}
+		return fun(bean, deleted)
+	})
+	assert.EqualValues(t, errStop, err)
+	assert.EqualValues(t, 1, cnt)
+
+	cnt, err = poller.Poll(fun)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, []string{"b2", "c2"}, names)
+
+	// soft deleted rows are captured by the deleted column
+	_, err = testEngine.ID(1).Delete(new(CDCItem))
+	assert.NoError(t, err)
+
+	var deletedIds []int64
+	cnt, err = poller.Poll(func(bean interface{}, deleted bool) error {
+		if deleted {
+			deletedIds = append(deletedIds, bean.(*CDCItem).Id)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, []int64{1}, deletedIds)
+
+	// the rows changed within the lag are not polled
+	poller.Lag(time.Minute)
+	_, err = testEngine.ID(4).Update(&CDCItem{Name: "d2"})
+	assert.NoError(t, err)
+	cnt, err = poller.Poll(fun)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	assert.NoError(t, poller.Reset())
+	cnt, err = poller.Lag(0).Poll(fun)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, cnt)
+
+	type CDCNoMark struct {
+		Id int64
+	}
+	_, err = testEngine.(*Engine).NewCDCPoller("no_mark", new(CDCNoMark))
+	assert.EqualValues(t, ErrCDCNoMarkColumn, err)
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueueTimeout is returned when a statement waits for a free slot of its
+// concurrency class longer than the queue timeout
+var ErrQueueTimeout = errors.New("timeout waiting for a free slot of the concurrency class")
+
+// ConcurrencyLimit limits the statements of a class executed concurrently
+type ConcurrencyLimit struct {
+	MaxConcurrent int           // the max statements executed concurrently
+	QueueTimeout  time.Duration // how long a statement waits for a free slot, until the context is done if zero
+}
+
+// ConcurrencyStats is the statistics of a concurrency class
+type ConcurrencyStats struct {
+	Active   int64 // the statements being executed
+	Waiting  int64 // the statements waiting for a free slot
+	Queued   int64 // the statements which have waited for a free slot
+	Rejected int64 // the statements failed since no slot is freed in time
+}
+
+type bulkhead struct {
+	stats   ConcurrencyStats
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+// SetConcurrencyLimit limits the concurrent statements of the class given by
+// Session.Class, the empty class is the default of the sessions without a
+// class or whose class has no limit. A limit with no MaxConcurrent removes
+// the limit of the class.
+//
+// A statement holds a slot while it's executed, a query holds it until its
+// rows are closed since they are still read from the connection. A
+// transaction holds a slot from Begin until it's committed or rollbacked.
+// The slot is not held while a retry waits for the next attempt.
+//
+//	engine.SetConcurrencyLimit("", xorm.ConcurrencyLimit{MaxConcurrent: 50})
+//	engine.SetConcurrencyLimit("reporting", xorm.ConcurrencyLimit{MaxConcurrent: 4, QueueTimeout: time.Second})
+//	engine.Class("reporting").Find(&orders)
+func (engine *Engine) SetConcurrencyLimit(class string, limit ConcurrencyLimit) {
+	if limit.MaxConcurrent <= 0 {
+		engine.bulkheads.Delete(class)
+		return
+	}
+	engine.bulkheads.Store(class, &bulkhead{
+		slots:   make(chan struct{}, limit.MaxConcurrent),
+		timeout: limit.QueueTimeout,
+	})
+}
+
+// ConcurrencyStats returns the statistics of the concurrency class
+func (engine *Engine) ConcurrencyStats(class string) ConcurrencyStats {
+	b, ok := engine.bulkheads.Load(class)
+	if !ok {
+		return ConcurrencyStats{}
+	}
+	stats := &b.(*bulkhead).stats
+	return ConcurrencyStats{
+		Active:   atomic.LoadInt64(&stats.Active),
+		Waiting:  atomic.LoadInt64(&stats.Waiting),
+		Queued:   atomic.LoadInt64(&stats.Queued),
+		Rejected: atomic.LoadInt64(&stats.Rejected),
+	}
+}
+
+// Class sets the concurrency class of the statements of the session
+func (session *Session) Class(class string) *Session {
+	session.class = class
+	return session
+}
+
+// acquireSlot releases the slot held by the session and waits for a free
+// slot of the session's class
+func (session *Session) acquireSlot() error {
+	session.releaseSlot()
+
+	b, ok := session.engine.bulkheads.Load(session.class)
+	if !ok && session.class != "" {
+		b, ok = session.engine.bulkheads.Load("")
+	}
+	if !ok {
+		return nil
+	}
+	if err := b.(*bulkhead).acquire(session.ctx); err != nil {
+		return err
+	}
+	session.slot = b.(*bulkhead)
+	return nil
+}
+
+// releaseSlot releases the slot held by the session if there is one
+func (session *Session) releaseSlot() {
+	if session.slot != nil {
+		session.slot.release()
+		session.slot = nil
+	}
+}
+
+// releaseQuerySlot releases the slot held by a query, the slot of a
+// transaction is kept until it's finished
+func (session *Session) releaseQuerySlot() {
+	if session.isAutoCommit {
+		session.releaseSlot()
+	}
+}
+
+func (b *bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		atomic.AddInt64(&b.stats.Active, 1)
+		return nil
+	default:
+	}
+
+	atomic.AddInt64(&b.stats.Queued, 1)
+	atomic.AddInt64(&b.stats.Waiting, 1)
+	defer atomic.AddInt64(&b.stats.Waiting, -1)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var timeout <-chan time.Time
+	if b.timeout > 0 {
+		timer := time.NewTimer(b.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		atomic.AddInt64(&b.stats.Active, 1)
+		return nil
+	case <-timeout:
+		atomic.AddInt64(&b.stats.Rejected, 1)
+		return ErrQueueTimeout
+	case <-ctx.Done():
+		atomic.AddInt64(&b.stats.Rejected, 1)
+		return ctx.Err()
+	}
+}
+
+func (b *bulkhead) release() {
+	atomic.AddInt64(&b.stats.Active, -1)
+	<-b.slots
+}
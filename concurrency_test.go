@@ -0,0 +1,126 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ConcurrencyOrder struct {
+	Id    int64
+	Total float64
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(ConcurrencyOrder))
+	_, err := testEngine.Insert(&ConcurrencyOrder{Total: 1})
+	assert.NoError(t, err)
+
+	testEngine.SetConcurrencyLimit("reporting", ConcurrencyLimit{MaxConcurrent: 1, QueueTimeout: 20 * time.Millisecond})
+	defer testEngine.SetConcurrencyLimit("reporting", ConcurrencyLimit{})
+
+	// the transaction holds the slot until it's committed
+	session := testEngine.NewSession().Class("reporting")
+	defer session.Close()
+	assert.NoError(t, session.Begin())
+	_, err = session.Count(new(ConcurrencyOrder))
+	assert.NoError(t, err)
+
+	_, err = testEngine.Class("reporting").Count(new(ConcurrencyOrder))
+	assert.EqualValues(t, ErrQueueTimeout, err)
+	// the other classes are not limited
+	_, err = testEngine.Count(new(ConcurrencyOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, ConcurrencyStats{Active: 1, Queued: 1, Rejected: 1}, testEngine.ConcurrencyStats("reporting"))
+
+	// the waiting statement gets the slot once it's freed
+	var committed = make(chan error)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		committed <- session.Commit()
+	}()
+	var orders []ConcurrencyOrder
+	assert.NoError(t, testEngine.Class("reporting").Find(&orders))
+	assert.NoError(t, <-committed)
+	assert.EqualValues(t, 1, len(orders))
+	assert.EqualValues(t, 0, testEngine.ConcurrencyStats("reporting").Active)
+
+	// the query releases the slot once its rows are closed
+	session2 := testEngine.NewSession().Class("reporting")
+	defer session2.Close()
+	orders = nil
+	assert.NoError(t, session2.Find(&orders))
+	assert.EqualValues(t, 0, testEngine.ConcurrencyStats("reporting").Active)
+	var order ConcurrencyOrder
+	has, err := session2.Get(&order)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	cnt, err := session2.Count(new(ConcurrencyOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, 0, testEngine.ConcurrencyStats("reporting").Active)
+	orders = nil
+	assert.NoError(t, testEngine.Class("reporting").Find(&orders))
+	assert.EqualValues(t, 1, len(orders))
+
+	// the classes without limits fall back to the default class
+	testEngine.SetConcurrencyLimit("", ConcurrencyLimit{MaxConcurrent: 1, QueueTimeout: 20 * time.Millisecond})
+	defer testEngine.SetConcurrencyLimit("", ConcurrencyLimit{})
+	session3 := testEngine.NewSession().Class("checkout")
+	defer session3.Close()
+	assert.NoError(t, session3.Begin())
+	_, err = testEngine.Count(new(ConcurrencyOrder))
+	assert.EqualValues(t, ErrQueueTimeout, err)
+	assert.NoError(t, session3.Rollback())
+	_, err = testEngine.Count(new(ConcurrencyOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, ConcurrencyStats{Queued: 1, Rejected: 1}, testEngine.ConcurrencyStats(""))
+}
+
+type ConcurrencyRetry struct {
+	Id   int64
+	Name string
+}
+
+func TestConcurrencyLimitRetry(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.DropTables(new(ConcurrencyRetry)))
+
+	testEngine.SetConcurrencyLimit("reporting", ConcurrencyLimit{MaxConcurrent: 1, QueueTimeout: 20 * time.Millisecond})
+	defer testEngine.SetConcurrencyLimit("reporting", ConcurrencyLimit{})
+
+	// the slot is not held while waiting for the next attempt
+	var active []int64
+	testEngine.SetRetryPolicy(&RetryPolicy{
+		MaxRetries: 1,
+		IsTransient: func(err error) bool {
+			return strings.Contains(err.Error(), "no such table") ||
+				strings.Contains(err.Error(), "doesn't exist") ||
+				strings.Contains(err.Error(), "does not exist") ||
+				strings.Contains(err.Error(), "Invalid object name")
+		},
+		OnRetry: func(err error, attempt int, delay time.Duration) {
+			active = append(active, testEngine.ConcurrencyStats("reporting").Active)
+			assert.NoError(t, testEngine.Sync2(new(ConcurrencyRetry)))
+		},
+	})
+	defer testEngine.SetRetryPolicy(nil)
+
+	var records []ConcurrencyRetry
+	assert.NoError(t, testEngine.Class("reporting").Find(&records))
+	assert.EqualValues(t, []int64{0}, active)
+
+	active = nil
+	assert.NoError(t, testEngine.DropTables(new(ConcurrencyRetry)))
+	_, err := testEngine.Class("reporting").Idempotent().Insert(&ConcurrencyRetry{Name: "a"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, []int64{0}, active)
+	assert.EqualValues(t, ConcurrencyStats{}, testEngine.ConcurrencyStats("reporting"))
+}
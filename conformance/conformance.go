@@ -0,0 +1,439 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package conformance is a test suite which checks whether a dialect works
+// with xorm. It runs against an engine of the dialect, so the third-party
+// dialects could prove their compatibility:
+//
+//	func TestConformance(t *testing.T) {
+//	    engine, err := xorm.NewEngine("mydb", dataSourceName)
+//	    if err != nil {
+//	        t.Fatal(err)
+//	    }
+//	    conformance.Run(t, engine)
+//	}
+//
+// The suite creates and drops the tables prefixed with conformance_.
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marlonfan/xorm"
+	"xorm.io/core"
+)
+
+// Run runs all the conformance tests against the engine
+func Run(t *testing.T, engine *xorm.Engine) {
+	t.Run("TypeRoundTrip", func(t *testing.T) { TypeRoundTrip(t, engine) })
+	t.Run("Introspection", func(t *testing.T) { Introspection(t, engine) })
+	t.Run("Pagination", func(t *testing.T) { Pagination(t, engine) })
+	t.Run("Transactions", func(t *testing.T) { Transactions(t, engine) })
+	t.Run("Sync2Idempotency", func(t *testing.T) { Sync2Idempotency(t, engine) })
+}
+
+// prepare drops and syncs the tables of the beans
+func prepare(t *testing.T, engine *xorm.Engine, beans ...interface{}) {
+	t.Helper()
+	if err := engine.DropTables(beans...); err != nil {
+		t.Fatalf("drop tables: %v", err)
+	}
+	if err := engine.Sync2(beans...); err != nil {
+		t.Fatalf("sync tables: %v", err)
+	}
+}
+
+// ConformanceTypes has the field types which should be stored and loaded
+// without changes
+type ConformanceTypes struct {
+	Id       int64
+	Int8     int8
+	Int16    int16
+	Int32    int32
+	Int64    int64
+	Uint8    uint8
+	Uint16   uint16
+	Uint32   uint32
+	Float32  float32
+	Float64  float64
+	Bool     bool
+	Varchar  string `xorm:"varchar(100)"`
+	Text     string `xorm:"text"`
+	Bytes    []byte
+	Time     time.Time
+	NullStr  *string `xorm:"null"`
+	NullInt  *int64  `xorm:"null"`
+	Strings  []string
+	Map      map[string]int
+	Unicode  string `xorm:"varchar(100)"`
+	Negative int64
+}
+
+// TypeRoundTrip checks the field types are stored and loaded without changes
+func TypeRoundTrip(t *testing.T, engine *xorm.Engine) {
+	prepare(t, engine, new(ConformanceTypes))
+
+	var str = "nullable"
+	var num int64 = 42
+	var now = time.Now().Truncate(time.Second)
+	var cases = []ConformanceTypes{
+		{
+			Int8: math.MaxInt8, Int16: math.MaxInt16, Int32: math.MaxInt32, Int64: math.MaxInt64,
+			Uint8: math.MaxUint8, Uint16: math.MaxUint16, Uint32: math.MaxUint32,
+			Float32: 1.5, Float64: 3.1415926, Bool: true,
+			Varchar: "varchar", Text: strings.Repeat("text", 1000), Bytes: []byte{0, 1, 2, 255},
+			Time: now, NullStr: &str, NullInt: &num,
+			Strings: []string{"a", "b"}, Map: map[string]int{"a": 1},
+			Unicode: "中文 ünïcödé 😀", Negative: math.MinInt64,
+		},
+		{
+			Int8: math.MinInt8, Int16: math.MinInt16, Int32: math.MinInt32, Int64: 0,
+			Float32: -1.25, Float64: -0.5, Time: now.Add(-24 * time.Hour),
+			Negative: -1,
+		},
+	}
+
+	for i := range cases {
+		if _, err := engine.Insert(&cases[i]); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		if cases[i].Id <= 0 {
+			t.Fatalf("the autoincrement id is not returned by insert")
+		}
+
+		var got ConformanceTypes
+		has, err := engine.ID(cases[i].Id).Get(&got)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if !has {
+			t.Fatalf("the inserted row %d is not found", cases[i].Id)
+		}
+
+		expected := cases[i]
+		if !expected.Time.Equal(got.Time) {
+			t.Errorf("time: expected %v, got %v", expected.Time, got.Time)
+		}
+		if !bytes.Equal(expected.Bytes, got.Bytes) {
+			t.Errorf("bytes: expected %v, got %v", expected.Bytes, got.Bytes)
+		}
+		expected.Time, got.Time = time.Time{}, time.Time{}
+		expected.Bytes, got.Bytes = nil, nil
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected %+v, got %+v", expected, got)
+		}
+	}
+}
+
+// ConformanceIntrospection has the columns and indexes to be introspected
+type ConformanceIntrospection struct {
+	Id    int64
+	Name  string `xorm:"varchar(50) notnull index"`
+	Email string `xorm:"varchar(100) unique"`
+	Age   int    `xorm:"index(age_name)"`
+	Nick  string `xorm:"varchar(20) index(age_name)"`
+}
+
+// Introspection checks the tables, columns and indexes loaded by the dialect
+func Introspection(t *testing.T, engine *xorm.Engine) {
+	prepare(t, engine, new(ConformanceIntrospection))
+	tableName := engine.TableName(new(ConformanceIntrospection))
+
+	exist, err := engine.IsTableExist(tableName)
+	if err != nil || !exist {
+		t.Fatalf("table %s should exist: %v", tableName, err)
+	}
+
+	tables, err := engine.Dialect().GetTables()
+	if err != nil {
+		t.Fatalf("get tables: %v", err)
+	}
+	var found bool
+	for _, table := range tables {
+		found = found || strings.EqualFold(table.Name, tableName)
+	}
+	if !found {
+		t.Errorf("table %s is not in the tables", tableName)
+	}
+
+	colSeq, cols, err := engine.Dialect().GetColumns(tableName)
+	if err != nil {
+		t.Fatalf("get columns: %v", err)
+	}
+	var names = make([]string, 0, len(colSeq))
+	for _, name := range colSeq {
+		names = append(names, strings.ToLower(name))
+	}
+	if expected := []string{"id", "name", "email", "age", "nick"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("columns: expected %v, got %v", expected, names)
+	}
+	for _, name := range colSeq {
+		col := cols[name]
+		switch strings.ToLower(name) {
+		case "id":
+			if !col.IsPrimaryKey || !col.IsAutoIncrement {
+				t.Errorf("id should be an autoincrement primary key")
+			}
+		case "name":
+			if col.Nullable {
+				t.Errorf("name should not be nullable")
+			}
+		case "email":
+			if !col.Nullable {
+				t.Errorf("email should be nullable")
+			}
+		}
+	}
+
+	indexes, err := engine.Dialect().GetIndexes(tableName)
+	if err != nil {
+		t.Fatalf("get indexes: %v", err)
+	}
+	var got []string
+	for _, index := range indexes {
+		var cols = make([]string, 0, len(index.Cols))
+		for _, col := range index.Cols {
+			cols = append(cols, strings.ToLower(col))
+		}
+		got = append(got, fmt.Sprintf("%d:%s", index.Type, strings.Join(cols, ",")))
+	}
+	sort.Strings(got)
+	expected := []string{
+		fmt.Sprintf("%d:age,nick", core.IndexType),
+		fmt.Sprintf("%d:email", core.UniqueType),
+		fmt.Sprintf("%d:name", core.IndexType),
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("indexes: expected %v, got %v", expected, got)
+	}
+}
+
+// ConformancePage is the table for pagination
+type ConformancePage struct {
+	Id  int64
+	Seq int
+}
+
+// Pagination checks the limit and offset of the queries
+func Pagination(t *testing.T, engine *xorm.Engine) {
+	prepare(t, engine, new(ConformancePage))
+
+	var pages = make([]ConformancePage, 0, 25)
+	for i := 0; i < 25; i++ {
+		pages = append(pages, ConformancePage{Seq: i})
+	}
+	if _, err := engine.Insert(&pages); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	for _, c := range []struct {
+		limit, start, first, count int
+	}{
+		{10, 0, 0, 10},
+		{10, 10, 10, 10},
+		{10, 20, 20, 5},
+		{10, 30, 0, 0},
+		{1, 24, 24, 1},
+	} {
+		var got []ConformancePage
+		if err := engine.Asc("seq").Limit(c.limit, c.start).Find(&got); err != nil {
+			t.Fatalf("find with limit %d offset %d: %v", c.limit, c.start, err)
+		}
+		if len(got) != c.count {
+			t.Errorf("limit %d offset %d: expected %d rows, got %d", c.limit, c.start, c.count, len(got))
+			continue
+		}
+		for i, page := range got {
+			if page.Seq != c.first+i {
+				t.Errorf("limit %d offset %d: expected seq %d, got %d", c.limit, c.start, c.first+i, page.Seq)
+			}
+		}
+	}
+
+	var page ConformancePage
+	has, err := engine.Where("seq > ?", 5).Asc("seq").Get(&page)
+	if err != nil || !has || page.Seq != 6 {
+		t.Errorf("get the first row: expected seq 6, got %d %v", page.Seq, err)
+	}
+
+	cnt, err := engine.Where("seq >= ?", 20).Count(new(ConformancePage))
+	if err != nil || cnt != 5 {
+		t.Errorf("count: expected 5, got %d %v", cnt, err)
+	}
+}
+
+// ConformanceTx is the table for transactions
+type ConformanceTx struct {
+	Id   int64
+	Name string `xorm:"varchar(20)"`
+}
+
+// ConformanceDDL is the table created in a rolled back transaction
+type ConformanceDDL struct {
+	Id int64
+}
+
+// Transactions checks committing and rolling back, and the transactional
+// DDL if the dialect declares it
+func Transactions(t *testing.T, engine *xorm.Engine) {
+	prepare(t, engine, new(ConformanceTx))
+
+	var count = func() int64 {
+		cnt, err := engine.Count(new(ConformanceTx))
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return cnt
+	}
+
+	session := engine.NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := session.Insert(&ConformanceTx{Name: "committed"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := session.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if cnt := count(); cnt != 1 {
+		t.Errorf("expected 1 row after commit, got %d", cnt)
+	}
+
+	if err := session.Begin(); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := session.Insert(&ConformanceTx{Name: "rolled back"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := session.Where("name = ?", "committed").Delete(new(ConformanceTx)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := session.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if cnt := count(); cnt != 1 {
+		t.Errorf("expected 1 row after rollback, got %d", cnt)
+	}
+
+	if !engine.Capabilities().TransactionalDDL {
+		return
+	}
+	if err := engine.DropTables(new(ConformanceDDL)); err != nil {
+		t.Fatalf("drop table: %v", err)
+	}
+	if err := session.Begin(); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := session.CreateTable(new(ConformanceDDL)); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if err := session.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	exist, err := engine.IsTableExist(new(ConformanceDDL))
+	if err != nil {
+		t.Fatalf("table exist: %v", err)
+	}
+	if exist {
+		t.Errorf("the table created in the transaction should be rolled back")
+	}
+}
+
+// ConformanceSync is the table to be synced
+type ConformanceSync struct {
+	Id      int64
+	Name    string    `xorm:"varchar(50) index"`
+	Email   string    `xorm:"varchar(100) unique"`
+	Score   float64   `xorm:"default 0"`
+	Created time.Time `xorm:"created"`
+}
+
+// ConformanceSyncV2 adds a column and an index to ConformanceSync
+type ConformanceSyncV2 struct {
+	Id      int64
+	Name    string    `xorm:"varchar(50) index"`
+	Email   string    `xorm:"varchar(100) unique"`
+	Score   float64   `xorm:"default 0"`
+	Created time.Time `xorm:"created"`
+	Level   int       `xorm:"index"`
+}
+
+// TableName implements xorm.TableName
+func (ConformanceSyncV2) TableName() string {
+	return "conformance_sync"
+}
+
+// schemaOf returns the columns and indexes of the table
+func schemaOf(t *testing.T, engine *xorm.Engine, tableName string) []string {
+	t.Helper()
+	colSeq, cols, err := engine.Dialect().GetColumns(tableName)
+	if err != nil {
+		t.Fatalf("get columns: %v", err)
+	}
+	var schema []string
+	for _, name := range colSeq {
+		col := cols[name]
+		schema = append(schema, fmt.Sprintf("%s %s %v", strings.ToLower(name), engine.Dialect().SqlType(col), col.Nullable))
+	}
+
+	indexes, err := engine.Dialect().GetIndexes(tableName)
+	if err != nil {
+		t.Fatalf("get indexes: %v", err)
+	}
+	var names []string
+	for _, index := range indexes {
+		names = append(names, fmt.Sprintf("%s %d %s", index.Name, index.Type, strings.Join(index.Cols, ",")))
+	}
+	sort.Strings(names)
+	return append(schema, names...)
+}
+
+// Sync2Idempotency checks Sync2 changes nothing when the table is up to date
+// and adds the new columns and indexes
+func Sync2Idempotency(t *testing.T, engine *xorm.Engine) {
+	prepare(t, engine, new(ConformanceSync))
+	tableName := engine.TableName(new(ConformanceSync))
+
+	if _, err := engine.Insert(&ConformanceSync{Name: "a", Email: "a@example.com"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	before := schemaOf(t, engine, tableName)
+	for i := 0; i < 2; i++ {
+		if err := engine.Sync2(new(ConformanceSync)); err != nil {
+			t.Fatalf("sync again: %v", err)
+		}
+		if after := schemaOf(t, engine, tableName); !reflect.DeepEqual(before, after) {
+			t.Errorf("the schema is changed by syncing again\nbefore: %v\nafter: %v", before, after)
+		}
+	}
+
+	if err := engine.Sync2(new(ConformanceSyncV2)); err != nil {
+		t.Fatalf("sync the new column: %v", err)
+	}
+	after := schemaOf(t, engine, tableName)
+	if err := engine.Sync2(new(ConformanceSyncV2)); err != nil {
+		t.Fatalf("sync the new column again: %v", err)
+	}
+	if again := schemaOf(t, engine, tableName); !reflect.DeepEqual(after, again) {
+		t.Errorf("the schema is changed by syncing again\nbefore: %v\nafter: %v", after, again)
+	}
+
+	var row ConformanceSyncV2
+	has, err := engine.Get(&row)
+	if err != nil || !has || row.Name != "a" || row.Level != 0 {
+		t.Errorf("the row should be kept after adding the column: %+v %v", row, err)
+	}
+}
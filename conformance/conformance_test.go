@@ -0,0 +1,39 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conformance
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	_ "github.com/denisenkom/go-mssqldb"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/marlonfan/xorm"
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/ziutek/mymysql/godrv"
+)
+
+var (
+	dbType  = flag.String("db", "sqlite3", "the tested database")
+	connStr = flag.String("conn_str", "./conformance.sqlite3", "the connection string of the tested database")
+	showSQL = flag.Bool("show_sql", false, "show generated SQLs")
+)
+
+func TestConformance(t *testing.T) {
+	if *dbType == "sqlite3" {
+		os.Remove(*connStr)
+	}
+
+	engine, err := xorm.NewEngine(*dbType, *connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	engine.ShowSQL(*showSQL)
+
+	Run(t, engine)
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"xorm.io/core"
+)
+
+// LimitStyle is how the dialect limits the rows of a query
+type LimitStyle int
+
+// the limit styles
+const (
+	LimitOffset LimitStyle = iota // LIMIT n OFFSET m
+	LimitTop                      // SELECT TOP n with a sub query for the offset
+	LimitRowNum                   // the ROWNUM sub queries
+)
+
+// UpsertStyle is the syntax of inserting or updating a row
+type UpsertStyle int
+
+// the upsert styles
+const (
+	UpsertNone           UpsertStyle = iota // upsert is not supported
+	UpsertOnConflict                        // INSERT ... ON CONFLICT (cols) DO UPDATE
+	UpsertOnDuplicateKey                    // INSERT ... ON DUPLICATE KEY UPDATE
+	UpsertMerge                             // MERGE INTO ... USING ...
+)
+
+// IdentityStrategy is how the autoincrement id of the inserted row is returned
+type IdentityStrategy int
+
+// the identity strategies
+const (
+	IdentityLastInsertID IdentityStrategy = iota // sql.Result.LastInsertId
+	IdentityReturning                            // INSERT ... RETURNING id
+	IdentityOutput                               // INSERT ... OUTPUT Inserted.id
+	IdentitySequence                             // the current value of the sequence
+)
+
+// PlaceholderStyle is how the args are bound in the SQLs
+type PlaceholderStyle int
+
+// the placeholder styles
+const (
+	PlaceholderQuestion PlaceholderStyle = iota // ?
+	PlaceholderDollar                           // $1, $2
+	PlaceholderColon                            // :1, :2
+)
+
+// MultiInsertStyle is how the rows are inserted by one statement
+type MultiInsertStyle int
+
+// the multiple rows insert styles
+const (
+	MultiInsertValues MultiInsertStyle = iota // INSERT INTO t (cols) VALUES (...), (...)
+	MultiInsertAll                            // INSERT ALL INTO t (cols) VALUES (...) ... SELECT 1 FROM DUAL
+)
+
+// Capabilities describes the features of a dialect which xorm depends on
+type Capabilities struct {
+	Returning        bool // INSERT/UPDATE/DELETE ... RETURNING is supported
+	Upsert           UpsertStyle
+	Savepoints       bool // SAVEPOINT and ROLLBACK TO SAVEPOINT are supported
+	TransactionalDDL bool // the schema changes could be rolled back
+	Limit            LimitStyle
+	Identity         IdentityStrategy
+	Placeholder      PlaceholderStyle
+	MultiInsert      MultiInsertStyle
+	MaxParams        int    // the max args of a statement, no limit if zero
+	WriteLimit       bool   // UPDATE and DELETE ... ORDER BY ... LIMIT n are supported
+	RowID            string // the pseudo column of the row's address, it limits UPDATE and DELETE by a sub query
+	UpdateFrom       bool   // the table aliases of UPDATE are declared by FROM, otherwise by AS
+	ModifyColumn     bool   // Sync2 could change varchar columns to text by ModifyColumnSql
+	WidenVarchar     bool   // Sync2 could enlarge varchar columns by ModifyColumnSql
+	DefaultValues    bool   // INSERT ... DEFAULT VALUES is supported, otherwise VALUES () is used
+	TableAliasAs     bool   // the table aliases are declared by AS
+	CrossDatabase    bool   // the table names like db..table are used as they are
+	ColumnComment    bool   // the column comments are declared by COMMENT in the column definitions
+	InplaceAlter     bool   // the online ALTER TABLE is declared by ALGORITHM=INPLACE, LOCK=NONE
+	ConcurrentIndex  bool   // the indexes are built online by CREATE INDEX CONCURRENTLY out of transactions
+	OnlineIndex      bool   // the indexes are built online by CREATE INDEX ... WITH (ONLINE = ON)
+	CompareLOB       bool   // the text and blob columns could be compared, they are not used as the bean's conditions otherwise
+	BoolLiterals     bool   // the bools are written as true and false, otherwise 1 and 0
+	BackslashEscape  bool   // the backslashes in the string literals are escaped
+	ZeroTime         bool   // the zero time is stored, so it's also taken as not soft deleted
+	TimestampzLayout string // the layout of the timestamps with time zones, time.RFC3339Nano if empty
+}
+
+// defaultCapabilities is used by the dialects which don't implement
+// CapableDialect, it assumes nothing but the standard SQL, LIMIT and
+// LastInsertId.
+var defaultCapabilities = Capabilities{
+	Limit:         LimitOffset,
+	Identity:      IdentityLastInsertID,
+	MaxParams:     999,
+	DefaultValues: true,
+	TableAliasAs:  true,
+	CompareLOB:    true,
+	BoolLiterals:  true,
+	ZeroTime:      true,
+}
+
+// CapableDialect is the dialect which declares its capabilities, the
+// dialects without it are treated as supporting LIMIT ... OFFSET and
+// sql.Result.LastInsertId only.
+type CapableDialect interface {
+	core.Dialect
+	Capabilities() Capabilities
+}
+
+// Capabilities returns the capabilities of the dialect of the engine
+func (engine *Engine) Capabilities() Capabilities {
+	if dialect, ok := engine.dialect.(CapableDialect); ok {
+		return dialect.Capabilities()
+	}
+	return defaultCapabilities
+}
+
+// RegisterDialect registers a third-party dialect with its driver, then the
+// engine could be created by NewEngine with the driver name. The dialect
+// should implement CapableDialect to use the features beyond the defaults.
+func RegisterDialect(driverName string, dbType core.DbType, driver core.Driver, dialect func() core.Dialect) {
+	core.RegisterDriver(driverName, driver)
+	core.RegisterDialect(dbType, dialect)
+}
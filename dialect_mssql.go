@@ -212,7 +212,30 @@ func (db *mssql) Init(d *core.DB, uri *core.Uri, drivername, dataSourceName stri
 	return db.Base.Init(d, db, uri, drivername, dataSourceName)
 }
 
+// Capabilities implements CapableDialect, the savepoints of mssql are
+// SAVE TRANSACTION which is not supported.
+func (db *mssql) Capabilities() Capabilities {
+	return Capabilities{
+		Upsert:           UpsertMerge,
+		TransactionalDDL: true,
+		Limit:            LimitTop,
+		Identity:         IdentityOutput,
+		MaxParams:        2100,
+		Placeholder:      PlaceholderColon,
+		UpdateFrom:       true,
+		DefaultValues:    true,
+		TableAliasAs:     true,
+		CrossDatabase:    true,
+		OnlineIndex:      true,
+		TimestampzLayout: "2006-01-02T15:04:05.9999999Z07:00",
+	}
+}
+
 func (db *mssql) SqlType(c *core.Column) string {
+	if isSpatialType(c.SQLType) {
+		return spatialSQLType(db.DBType(), c)
+	}
+
 	var res string
 	switch t := c.SQLType.Name; t {
 	case core.Bool:
@@ -393,7 +416,7 @@ func (db *mssql) GetColumns(tableName string) ([]string, map[string]*core.Column
 		case "IMAGE":
 			col.SQLType = core.SQLType{Name: core.VarBinary, DefaultLength: 0, DefaultLength2: 0}
 		default:
-			if _, ok := core.SqlTypes[ct]; ok {
+			if _, ok := core.SqlTypes[ct]; ok || isSpatialType(core.SQLType{Name: ct}) {
 				col.SQLType = core.SQLType{Name: ct, DefaultLength: 0, DefaultLength2: 0}
 			} else {
 				return nil, nil, fmt.Errorf("Unknown colType %v for %v - %v", ct, tableName, col.Name)
@@ -199,7 +199,32 @@ func (db *mysql) SetParams(params map[string]string) {
 	}
 }
 
+// Capabilities implements CapableDialect
+func (db *mysql) Capabilities() Capabilities {
+	return Capabilities{
+		Upsert:          UpsertOnDuplicateKey,
+		Savepoints:      true,
+		Limit:           LimitOffset,
+		Identity:        IdentityLastInsertID,
+		MaxParams:       65535,
+		WriteLimit:      true,
+		ModifyColumn:    true,
+		WidenVarchar:    true,
+		TableAliasAs:    true,
+		ColumnComment:   true,
+		InplaceAlter:    true,
+		CompareLOB:      true,
+		BoolLiterals:    true,
+		BackslashEscape: true,
+		ZeroTime:        true,
+	}
+}
+
 func (db *mysql) SqlType(c *core.Column) string {
+	if isSpatialType(c.SQLType) {
+		return spatialSQLType(db.DBType(), c)
+	}
+
 	var res string
 	switch t := c.SQLType.Name; t {
 	case core.Bool:
@@ -394,7 +419,7 @@ func (db *mysql) GetColumns(tableName string) ([]string, map[string]*core.Column
 		}
 		col.Length = len1
 		col.Length2 = len2
-		if _, ok := core.SqlTypes[colType]; ok {
+		if _, ok := core.SqlTypes[colType]; ok || isSpatialType(core.SQLType{Name: colType}) {
 			col.SQLType = core.SQLType{Name: colType, DefaultLength: len1, DefaultLength2: len2}
 		} else {
 			return nil, nil, fmt.Errorf("Unknown colType %v", colType)
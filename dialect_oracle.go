@@ -506,7 +506,27 @@ func (db *oracle) Init(d *core.DB, uri *core.Uri, drivername, dataSourceName str
 	return db.Base.Init(d, db, uri, drivername, dataSourceName)
 }
 
+// Capabilities implements CapableDialect
+func (db *oracle) Capabilities() Capabilities {
+	return Capabilities{
+		Upsert:        UpsertMerge,
+		Savepoints:    true,
+		Limit:         LimitRowNum,
+		Identity:      IdentitySequence,
+		MaxParams:     65535,
+		MultiInsert:   MultiInsertAll,
+		DefaultValues: true,
+		CompareLOB:    true,
+		BoolLiterals:  true,
+		ZeroTime:      true,
+	}
+}
+
 func (db *oracle) SqlType(c *core.Column) string {
+	if isSpatialType(c.SQLType) {
+		return spatialSQLType(db.DBType(), c)
+	}
+
 	var res string
 	switch t := c.SQLType.Name; t {
 	case core.Bit, core.TinyInt, core.SmallInt, core.MediumInt, core.Int, core.Integer, core.BigInt, core.Bool, core.Serial, core.BigSerial:
@@ -754,7 +774,7 @@ func (db *oracle) GetColumns(tableName string) ([]string, map[string]*core.Colum
 			continue
 		}
 
-		if _, ok := core.SqlTypes[col.SQLType.Name]; !ok {
+		if _, ok := core.SqlTypes[col.SQLType.Name]; !ok && !isSpatialType(col.SQLType) {
 			return nil, nil, fmt.Errorf("Unknown colType %v %v", *dataType, col.SQLType)
 		}
 
@@ -786,7 +786,33 @@ func (db *postgres) Init(d *core.DB, uri *core.Uri, drivername, dataSourceName s
 	return nil
 }
 
+// Capabilities implements CapableDialect
+func (db *postgres) Capabilities() Capabilities {
+	return Capabilities{
+		Returning:        true,
+		Upsert:           UpsertOnConflict,
+		Savepoints:       true,
+		TransactionalDDL: true,
+		Limit:            LimitOffset,
+		Identity:         IdentityReturning,
+		MaxParams:        65535,
+		Placeholder:      PlaceholderDollar,
+		RowID:            "ctid",
+		ModifyColumn:     true,
+		DefaultValues:    true,
+		TableAliasAs:     true,
+		ConcurrentIndex:  true,
+		CompareLOB:       true,
+		BoolLiterals:     true,
+		ZeroTime:         true,
+	}
+}
+
 func (db *postgres) SqlType(c *core.Column) string {
+	if isSpatialType(c.SQLType) {
+		return spatialSQLType(db.DBType(), c)
+	}
+
 	var res string
 	switch t := c.SQLType.Name; t {
 	case core.TinyInt:
@@ -1039,7 +1065,7 @@ WHERE c.relkind = 'r'::char AND c.relname = $1%s AND f.attnum > 0 ORDER BY f.att
 		default:
 			col.SQLType = core.SQLType{Name: strings.ToUpper(dataType), DefaultLength: 0, DefaultLength2: 0}
 		}
-		if _, ok := core.SqlTypes[col.SQLType.Name]; !ok {
+		if _, ok := core.SqlTypes[col.SQLType.Name]; !ok && !isSpatialType(col.SQLType) {
 			return nil, nil, fmt.Errorf("Unknown colType: %v", dataType)
 		}
 
@@ -151,7 +151,29 @@ func (db *sqlite3) Init(d *core.DB, uri *core.Uri, drivername, dataSourceName st
 	return db.Base.Init(d, db, uri, drivername, dataSourceName)
 }
 
+// Capabilities implements CapableDialect
+func (db *sqlite3) Capabilities() Capabilities {
+	return Capabilities{
+		Upsert:           UpsertOnConflict,
+		Savepoints:       true,
+		TransactionalDDL: true,
+		Limit:            LimitOffset,
+		Identity:         IdentityLastInsertID,
+		MaxParams:        999,
+		RowID:            "rowid",
+		DefaultValues:    true,
+		TableAliasAs:     true,
+		CompareLOB:       true,
+		BoolLiterals:     true,
+		ZeroTime:         true,
+	}
+}
+
 func (db *sqlite3) SqlType(c *core.Column) string {
+	if isSpatialType(c.SQLType) {
+		return spatialSQLType(db.DBType(), c)
+	}
+
 	switch t := c.SQLType.Name; t {
 	case core.Bool:
 		if c.Default == "true" {
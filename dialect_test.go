@@ -0,0 +1,129 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func TestCapabilities(t *testing.T) {
+	var cases = []struct {
+		dbType       core.DbType
+		limit        LimitStyle
+		identity     IdentityStrategy
+		placeholder  PlaceholderStyle
+		upsert       UpsertStyle
+		returning    bool
+		savepoints   bool
+		modifyColumn bool
+		widenVarchar bool
+	}{
+		{core.MYSQL, LimitOffset, IdentityLastInsertID, PlaceholderQuestion, UpsertOnDuplicateKey, false, true, true, true},
+		{core.POSTGRES, LimitOffset, IdentityReturning, PlaceholderDollar, UpsertOnConflict, true, true, true, false},
+		{core.SQLITE, LimitOffset, IdentityLastInsertID, PlaceholderQuestion, UpsertOnConflict, false, true, false, false},
+		{core.MSSQL, LimitTop, IdentityOutput, PlaceholderColon, UpsertMerge, false, false, false, false},
+		{core.ORACLE, LimitRowNum, IdentitySequence, PlaceholderQuestion, UpsertMerge, false, true, false, false},
+	}
+	for _, c := range cases {
+		engine := &Engine{dialect: core.QueryDialect(c.dbType)}
+		caps := engine.Capabilities()
+		assert.EqualValues(t, c.limit, caps.Limit, c.dbType)
+		assert.EqualValues(t, c.identity, caps.Identity, c.dbType)
+		assert.EqualValues(t, c.placeholder, caps.Placeholder, c.dbType)
+		assert.EqualValues(t, c.upsert, caps.Upsert, c.dbType)
+		assert.EqualValues(t, c.returning, caps.Returning, c.dbType)
+		assert.EqualValues(t, c.savepoints, caps.Savepoints, c.dbType)
+		assert.EqualValues(t, c.modifyColumn, caps.ModifyColumn, c.dbType)
+		assert.EqualValues(t, c.widenVarchar, caps.WidenVarchar, c.dbType)
+	}
+
+	// the dialect without Capabilities
+	engine := &Engine{dialect: &struct{ core.Dialect }{&sqlite3{}}}
+	assert.EqualValues(t, defaultCapabilities, engine.Capabilities())
+}
+
+// limitTopDialect pretends to be a dialect limiting rows with TOP
+type limitTopDialect struct {
+	sqlite3
+}
+
+func (db *limitTopDialect) Capabilities() Capabilities {
+	caps := db.sqlite3.Capabilities()
+	caps.Limit = LimitTop
+	return caps
+}
+
+func TestCapabilitiesLimit(t *testing.T) {
+	dialect := new(limitTopDialect)
+	assert.NoError(t, dialect.Init(nil, &core.Uri{DbType: core.SQLITE}, "", ""))
+
+	var statement Statement
+	statement.Engine = &Engine{dialect: dialect}
+	statement.Init()
+	statement.AltTableName = "user"
+	statement.LimitN = 5
+
+	sqlStr, err := statement.genSelectSQL("*", "", true, false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT TOP 5 * FROM `user`", sqlStr)
+}
+
+// aliasDialect pretends to be a dialect without AS of the table aliases
+type aliasDialect struct {
+	sqlite3
+}
+
+func (db *aliasDialect) Capabilities() Capabilities {
+	caps := db.sqlite3.Capabilities()
+	caps.TableAliasAs = false
+	return caps
+}
+
+func TestCapabilitiesSyntax(t *testing.T) {
+	dialect := new(aliasDialect)
+	assert.NoError(t, dialect.Init(nil, &core.Uri{DbType: core.SQLITE}, "", ""))
+
+	var statement Statement
+	statement.Engine = &Engine{dialect: dialect}
+	statement.Init()
+	statement.AltTableName = "user"
+	statement.TableAlias = "u"
+
+	sqlStr, err := statement.genSelectSQL("*", "", true, false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT * FROM `user` `u`", sqlStr)
+}
+
+// writeLimitDialect pretends to be a dialect supporting DELETE ... LIMIT
+type writeLimitDialect struct {
+	sqlite3
+}
+
+func (db *writeLimitDialect) Capabilities() Capabilities {
+	caps := db.sqlite3.Capabilities()
+	caps.WriteLimit = true
+	caps.RowID = ""
+	return caps
+}
+
+func TestCapabilitiesWriteLimit(t *testing.T) {
+	engine := &Engine{dialect: new(sqlite3)}
+	sqlStr, err := engine.limitWriteSQL("`user`", "", " LIMIT 5")
+	assert.NoError(t, err)
+	assert.EqualValues(t, " WHERE rowid IN (SELECT rowid FROM `user` LIMIT 5)", sqlStr)
+
+	engine = &Engine{dialect: new(writeLimitDialect)}
+	sqlStr, err = engine.limitWriteSQL("`user`", "`id` > ?", " LIMIT 5")
+	assert.NoError(t, err)
+	assert.EqualValues(t, " LIMIT 5", sqlStr)
+
+	// the dialect can't limit the writes without the row ids
+	engine = &Engine{dialect: &struct{ core.Dialect }{&sqlite3{}}}
+	_, err = engine.limitWriteSQL("`user`", "", " LIMIT 5")
+	assert.EqualValues(t, ErrNotImplemented, err)
+}
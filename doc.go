@@ -179,6 +179,49 @@ Attention: the above 8 methods should be the last chainable method.
     engine.Join("LEFT", "userdetail", "user.id=userdetail.id").Find(&users)
     //SELECT * FROM user LEFT JOIN userdetail ON user.id=userdetail.id
 
+Third-party Dialects
+
+A dialect is a core.Dialect implementation. Register it with a driver which
+parses the data source name, then NewEngine could use the driver name:
+
+    xorm.RegisterDialect("mydb", "mydb", &myDriver{}, func() core.Dialect {
+        return &myDialect{}
+    })
+    engine, err := xorm.NewEngine("mydb", dataSourceName)
+
+The dialect should implement xorm.CapableDialect to declare the features
+xorm depends on, i.e. how the rows are limited, how the autoincrement id of
+the inserted row is returned, the placeholders, RETURNING, the upsert
+syntax, savepoints, transactional DDL and the syntax differences of
+inserts, limited writes, aliases and schema changes. Without it the
+dialect is treated as supporting the standard SQL, LIMIT ... OFFSET and
+sql.Result.LastInsertId only.
+
+    func (db *myDialect) Capabilities() xorm.Capabilities {
+        return xorm.Capabilities{
+            Returning:        true,
+            Upsert:           xorm.UpsertOnConflict,
+            Savepoints:       true,
+            TransactionalDDL: true,
+            Limit:            xorm.LimitOffset,
+            Identity:         xorm.IdentityReturning,
+            Placeholder:      xorm.PlaceholderDollar,
+            DefaultValues:    true,
+            TableAliasAs:     true,
+            CompareLOB:       true,
+            BoolLiterals:     true,
+            ZeroTime:         true,
+        }
+    }
+
+The package github.com/marlonfan/xorm/conformance has the tests which the
+dialect should pass, run them against an engine of the dialect:
+
+    func TestConformance(t *testing.T) {
+        engine, _ := xorm.NewEngine("mydb", dataSourceName)
+        conformance.Run(t, engine)
+    }
+
 More usage, please visit http://xorm.io/docs
 */
 package xorm
@@ -0,0 +1,647 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// ErrTableNotRegistered the table is not registered by RegisterTable
+type ErrTableNotRegistered struct {
+	TableName string
+}
+
+func (e ErrTableNotRegistered) Error() string {
+	return fmt.Sprintf("table %s is not registered", e.TableName)
+}
+
+// RegisterTable registers a table defined at runtime under its name. The rows
+// of it are operated as map[string]interface{} by InsertRow, GetRow, FindRows,
+// UpdateRow and DeleteRow, and the name could be passed to Sync2 and
+// CreateTables instead of a bean.
+//
+//	table := core.NewEmptyTable()
+//	table.Name = "custom_record"
+//	col := core.NewColumn("id", "", core.SQLType{Name: core.BigInt}, 0, 0, false)
+//	col.IsPrimaryKey, col.IsAutoIncrement = true, true
+//	table.AddColumn(col)
+//	table.PrimaryKeys, table.AutoIncrement = []string{"id"}, "id"
+//	...
+//	err := engine.RegisterTable(table)
+//
+// The created, updated, deleted and version columns are maintained as the
+// struct fields tagged with them.
+func (engine *Engine) RegisterTable(table *core.Table) error {
+	if table == nil || table.Name == "" {
+		return errors.New("registered table needs a name")
+	}
+	if len(table.Columns()) == 0 {
+		return fmt.Errorf("registered table %s has no column", table.Name)
+	}
+	engine.dynamicTables.Store(table.Name, table)
+	return nil
+}
+
+// UnregisterTable removes the table registered by RegisterTable
+func (engine *Engine) UnregisterTable(name string) {
+	engine.dynamicTables.Delete(name)
+}
+
+// RegisteredTable returns the table registered by RegisterTable, nil if there is none
+func (engine *Engine) RegisteredTable(name string) *core.Table {
+	table, ok := engine.dynamicTables.Load(name)
+	if !ok {
+		return nil
+	}
+	return table.(*core.Table)
+}
+
+// registeredTableOf returns the registered table if the bean is its name
+func (engine *Engine) registeredTableOf(bean interface{}) *core.Table {
+	if name, ok := bean.(string); ok {
+		return engine.RegisteredTable(name)
+	}
+	return nil
+}
+
+// rowTable sets the registered table to the statement
+func (session *Session) rowTable(tableName string) (*core.Table, error) {
+	if session.statement.lastError != nil {
+		return nil, session.statement.lastError
+	}
+	table := session.engine.RegisteredTable(tableName)
+	if table == nil {
+		return nil, ErrTableNotRegistered{tableName}
+	}
+	if err := session.statement.setRefBean(tableName); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// rowColumns returns the values of the row by the columns of the table
+func rowColumns(table *core.Table, row map[string]interface{}) (map[*core.Column]interface{}, error) {
+	var values = make(map[*core.Column]interface{}, len(row))
+	for name, value := range row {
+		col := table.GetColumn(name)
+		if col == nil {
+			return nil, ErrFieldIsNotExist{name, table.Name}
+		}
+		values[col] = value
+	}
+	return values, nil
+}
+
+// rowValue converts the value of the row to the one put into database
+func (session *Session) rowValue(col *core.Column, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return session.value2Interface(col, reflect.ValueOf(value))
+}
+
+// rowColumnType returns the type of the values of the column read from database
+func rowColumnType(col *core.Column) reflect.Type {
+	if col.SQLType.Name == core.Bool || col.SQLType.Name == core.Boolean {
+		return reflect.TypeOf(true)
+	}
+	switch tp := core.SQLType2Type(col.SQLType); tp.Kind() {
+	case reflect.Int:
+		return core.Int64Type
+	case reflect.Float32:
+		return core.Float64Type
+	default:
+		return tp
+	}
+}
+
+// rowColumnValue converts the value read from database by the column type
+func (session *Session) rowColumnValue(col *core.Column, raw interface{}) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	tp := rowColumnType(col)
+	if t, ok := raw.(time.Time); ok && tp == core.TimeType {
+		dbTZ := session.engine.DatabaseTZ
+		if col.TimeZone != nil {
+			dbTZ = col.TimeZone
+		}
+		// set new location if database don't save timezone or give an incorrect timezone
+		if z, _ := t.Zone(); len(z) == 0 || t.Year() == 0 || t.Location().String() != dbTZ.String() {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(),
+				t.Minute(), t.Second(), t.Nanosecond(), dbTZ)
+		}
+		return t.In(session.engine.TZLocation), nil
+	}
+
+	rawValue := reflect.ValueOf(raw)
+	data, err := value2Bytes(&rawValue)
+	if err != nil {
+		return nil, err
+	}
+	fieldValue := reflect.New(tp).Elem()
+	if err := session.bytes2Value(col, &fieldValue, data); err != nil {
+		return nil, err
+	}
+	return fieldValue.Interface(), nil
+}
+
+// queryRowMaps queries the rows of the table as maps
+func (session *Session) queryRowMaps(table *core.Table, sqlStr string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := session.queryRows(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer session.closeRows(rows)
+
+	fields, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for rows.Next() {
+		var scanResults = make([]interface{}, len(fields))
+		for i := range scanResults {
+			var cell interface{}
+			scanResults[i] = &cell
+		}
+		if err := rows.Scan(scanResults...); err != nil {
+			return nil, err
+		}
+
+		var row = make(map[string]interface{}, len(fields))
+		for i, field := range fields {
+			raw := *(scanResults[i].(*interface{}))
+			col := table.GetColumn(field)
+			if col == nil {
+				row[field] = raw
+				continue
+			}
+			value, err := session.rowColumnValue(col, raw)
+			if err != nil {
+				return nil, err
+			}
+			row[col.Name] = value
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
+// copyRow returns a shallow copy of the row
+func copyRow(row map[string]interface{}) map[string]interface{} {
+	var newRow = make(map[string]interface{}, len(row))
+	for k, v := range row {
+		newRow[k] = v
+	}
+	return newRow
+}
+
+// clearRowsCache removes the cached rows of the table after they are changed
+func (session *Session) clearRowsCache(tableName string) {
+	if !session.statement.UseCache {
+		return
+	}
+	if cacher := session.engine.getCacher(tableName); cacher != nil {
+		session.engine.logger.Debug("[cache] clear rows:", tableName)
+		cacher.ClearIds(tableName)
+		cacher.ClearBeans(tableName)
+	}
+}
+
+// InsertRow inserts the row into the registered table. The generated
+// autoincrement primary key and the values of the created, updated and
+// version columns are set to the row after inserted.
+func (session *Session) InsertRow(tableName string, row map[string]interface{}) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+	defer session.resetStatement()
+
+	table, err := session.rowTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	values, err := rowColumns(table, row)
+	if err != nil {
+		return 0, err
+	}
+	if writeCheck := session.statement.writePolicy(); writeCheck != nil {
+		if err := writeCheck(row); err != nil {
+			return 0, err
+		}
+	}
+
+	var colNames []string
+	var args []interface{}
+	var maintained = make(map[string]interface{})
+	var needID bool
+	for _, col := range table.Columns() {
+		if col.MapType == core.ONLYFROMDB || col.IsDeleted ||
+			session.engine.columnExpr(col) != "" ||
+			session.statement.omitColumnMap.contain(col.Name) {
+			continue
+		}
+
+		var value interface{}
+		if (col.IsCreated || col.IsUpdated) && session.statement.UseAutoTime {
+			var t time.Time
+			value, t = session.engine.nowTime(col)
+			maintained[col.Name] = t
+		} else if col.IsVersion && session.statement.checkVersion {
+			value = 1
+			maintained[col.Name] = int64(1)
+		} else {
+			v, ok := values[col]
+			if col.IsAutoIncrement && (!ok || v == nil || isZero(v)) {
+				needID = true
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if value, err = session.rowValue(col, v); err != nil {
+				return 0, err
+			}
+		}
+		colNames = append(colNames, session.engine.Quote(col.Name))
+		args = append(args, value)
+	}
+
+	var identity = session.engine.Capabilities().Identity
+	var output string
+	if needID && identity == IdentityOutput {
+		output = fmt.Sprintf(" OUTPUT Inserted.%s", table.AutoIncrement)
+	}
+
+	tableName = session.statement.TableName()
+	var sqlStr = fmt.Sprintf("INSERT INTO %s", session.engine.Quote(tableName))
+	if len(colNames) == 0 {
+		if session.engine.Capabilities().DefaultValues {
+			sqlStr += output + " DEFAULT VALUES"
+		} else {
+			sqlStr += " VALUES ()"
+		}
+	} else {
+		sqlStr += fmt.Sprintf(" (%s)%s VALUES (%s)", strings.Join(colNames, ", "), output,
+			strings.Join(makeArray("?", len(colNames)), ", "))
+	}
+	if needID && identity == IdentityReturning {
+		sqlStr += " RETURNING " + session.engine.Quote(table.AutoIncrement)
+	}
+
+	var affected int64 = 1
+	var id int64
+	if needID && (identity == IdentityReturning || identity == IdentityOutput) {
+		res, err := session.queryBytes(sqlStr, args...)
+		if err != nil {
+			return 0, err
+		}
+		if len(res) < 1 {
+			return 0, errors.New("insert successfully but not returned id")
+		}
+		if id, err = strconv.ParseInt(string(res[0][table.AutoIncrement]), 10, 64); err != nil {
+			return 0, err
+		}
+	} else {
+		res, err := session.exec(sqlStr, args...)
+		if err != nil {
+			return 0, err
+		}
+		if affected, err = res.RowsAffected(); err != nil {
+			return 0, err
+		}
+		if needID && identity == IdentitySequence {
+			if id, err = session.sequenceID(tableName, table.AutoIncrement); err != nil {
+				return 0, err
+			}
+		} else if needID {
+			id, _ = res.LastInsertId()
+		}
+	}
+
+	session.cacheInsert(tableName)
+
+	if id > 0 {
+		row[table.AutoIncrement] = id
+	}
+	for name, value := range maintained {
+		row[name] = value
+	}
+	return affected, nil
+}
+
+// sequenceID returns the current value of the sequence of the table's
+// autoincrement column on the databases of IdentitySequence
+func (session *Session) sequenceID(tableName, colName string) (int64, error) {
+	sqlStr := fmt.Sprintf("select %s.currval AS %s from dual", session.engine.sequenceName(tableName), session.engine.Quote(colName))
+	res, err := session.queryBytes(sqlStr)
+	if err != nil {
+		return 0, err
+	}
+	if len(res) < 1 {
+		return 0, errors.New("insert no error but not returned id")
+	}
+	return strconv.ParseInt(string(res[0][colName]), 10, 64)
+}
+
+// GetRow retrieves one row of the registered table by the conditions of the
+// session into the row. The values are converted by the column types, the
+// integers as int64, the floats as float64 and the times as time.Time.
+func (session *Session) GetRow(tableName string, row map[string]interface{}) (bool, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+	defer session.resetStatement()
+
+	table, err := session.rowTable(tableName)
+	if err != nil {
+		return false, err
+	}
+	session.statement.Limit(1)
+	rows, err := session.findRows(table)
+	if err != nil || len(rows) == 0 {
+		return false, err
+	}
+	for k, v := range rows[0] {
+		row[k] = v
+	}
+	return true, nil
+}
+
+// FindRows retrieves the rows of the registered table by the conditions of
+// the session, the soft deleted rows are excluded unless Unscoped is called.
+func (session *Session) FindRows(tableName string) ([]map[string]interface{}, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+	defer session.resetStatement()
+
+	table, err := session.rowTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return session.findRows(table)
+}
+
+func (session *Session) findRows(table *core.Table) ([]map[string]interface{}, error) {
+	var tableName = session.statement.TableName()
+	if col := table.DeletedColumn(); col != nil && !session.statement.unscoped {
+		session.statement.cond = session.statement.cond.And(
+			session.engine.CondDeleted(session.statement.colName(col, tableName)))
+	}
+	session.statement.mergePolicyCond()
+	if err := session.statement.processIDParam(); err != nil {
+		return nil, err
+	}
+
+	condSQL, condArgs, err := builder.ToSQL(session.statement.cond)
+	if err != nil {
+		return nil, err
+	}
+	sqlStr, err := session.statement.genSelectSQL(session.statement.genColumnStr(), condSQL, true, true)
+	if err != nil {
+		return nil, err
+	}
+	args := append(session.statement.joinArgs, condArgs...)
+
+	// the cached rows should have all the columns
+	if session.canCache() && !session.statement.unscoped &&
+		len(session.statement.columnMap) == 0 && len(session.statement.omitColumnMap) == 0 {
+		if cacher := session.engine.getCacher(tableName); cacher != nil {
+			rows, err := session.cacheFindRows(cacher, table, tableName, sqlStr, args...)
+			if err != ErrCacheFailed {
+				return rows, err
+			}
+		}
+	}
+	return session.queryRowMaps(table, sqlStr, args...)
+}
+
+// cacheFindRows returns the rows by the cached primary keys, the rows are
+// cached as copies since the maps could be changed by the caller.
+func (session *Session) cacheFindRows(cacher core.Cacher, table *core.Table, tableName, sqlStr string, args ...interface{}) ([]map[string]interface{}, error) {
+	for _, filter := range session.engine.dialect.Filters() {
+		sqlStr = filter.Do(sqlStr, session.engine.dialect, table)
+	}
+	newsql := session.statement.convertIDSQL(sqlStr)
+	if newsql == "" {
+		return nil, ErrCacheFailed
+	}
+
+	if ids, err := core.GetCacheSql(cacher, tableName, newsql, args); err == nil {
+		var rows = make([]map[string]interface{}, 0, len(ids))
+		for _, id := range ids {
+			sid, err := id.ToString()
+			if err != nil {
+				return nil, err
+			}
+			row, ok := cacher.GetBean(tableName, sid).(map[string]interface{})
+			if !ok {
+				break
+			}
+			rows = append(rows, copyRow(row))
+		}
+		if len(rows) == len(ids) {
+			session.engine.logger.Debug("[cacheFindRows] cache hit sql:", newsql, ids)
+			return rows, nil
+		}
+	}
+
+	rows, err := session.queryRowMaps(table, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids = make([]core.PK, 0, len(rows))
+	for _, row := range rows {
+		var pk = make(core.PK, 0, len(table.PrimaryKeys))
+		for _, col := range table.PKColumns() {
+			pk = append(pk, row[col.Name])
+		}
+		ids = append(ids, pk)
+	}
+
+	session.engine.logger.Debug("[cacheFindRows] cache ids:", newsql, ids)
+	if err := core.PutCacheSql(cacher, ids, tableName, newsql, args); err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		sid, err := id.ToString()
+		if err != nil {
+			return nil, err
+		}
+		cacher.PutBean(tableName, sid, copyRow(rows[i]))
+	}
+	return rows, nil
+}
+
+// UpdateRow updates the columns in the row of the registered table by the
+// conditions of the session, or by the primary keys in the row if there is
+// no condition. The primary keys are never updated, the updated column is set
+// to now and the version column is increased, and checked if it's in the row.
+func (session *Session) UpdateRow(tableName string, row map[string]interface{}) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+	defer session.resetStatement()
+
+	table, err := session.rowTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	values, err := rowColumns(table, row)
+	if err != nil {
+		return 0, err
+	}
+
+	var hasCond = session.statement.cond.IsValid() || session.statement.idParam != nil
+	var cond = builder.NewCond()
+	var colNames []string
+	var args []interface{}
+	var maintained = make(map[string]interface{})
+	for _, col := range table.Columns() {
+		if col.IsUpdated && session.statement.UseAutoTime &&
+			!session.statement.omitColumnMap.contain(col.Name) {
+			val, t := session.engine.nowTime(col)
+			colNames = append(colNames, session.engine.Quote(col.Name)+" = ?")
+			args = append(args, val)
+			maintained[col.Name] = t
+			continue
+		}
+
+		v, ok := values[col]
+		if !ok {
+			continue
+		}
+		value, err := session.rowValue(col, v)
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case col.IsPrimaryKey:
+			if !hasCond {
+				cond = cond.And(builder.Eq{session.engine.Quote(col.Name): value})
+			}
+		case col.IsVersion:
+			if session.statement.checkVersion {
+				cond = cond.And(builder.Eq{session.engine.Quote(col.Name): value})
+				version, err := convertInt(value)
+				if err != nil {
+					return 0, err
+				}
+				maintained[col.Name] = version + 1
+			}
+		case col.MapType == core.ONLYFROMDB, col.IsCreated, col.IsDeleted,
+			session.engine.columnExpr(col) != "",
+			session.statement.omitColumnMap.contain(col.Name),
+			len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(col.Name):
+		default:
+			colNames = append(colNames, session.engine.Quote(col.Name)+" = ?")
+			args = append(args, value)
+		}
+	}
+	if !hasCond && !cond.IsValid() {
+		return 0, errors.New("update row needs conditions or the primary keys in the row")
+	}
+
+	if table.Version != "" {
+		colNames = append(colNames, session.engine.Quote(table.Version)+" = "+session.engine.Quote(table.Version)+" + 1")
+	}
+	if len(colNames) == 0 {
+		return 0, errors.New("No content found to be updated")
+	}
+	if col := table.DeletedColumn(); col != nil && !session.statement.unscoped {
+		cond = cond.And(session.engine.CondDeleted(session.engine.Quote(col.Name)))
+	}
+	session.statement.mergePolicyCond()
+	if err := session.statement.processIDParam(); err != nil {
+		return 0, err
+	}
+
+	condSQL, condArgs, err := builder.ToSQL(session.statement.cond.And(cond))
+	if err != nil {
+		return 0, err
+	}
+
+	tableName = session.statement.TableName()
+	sqlStr := fmt.Sprintf("UPDATE %s SET %s WHERE %s", session.engine.Quote(tableName),
+		strings.Join(colNames, ", "), condSQL)
+	res, err := session.exec(sqlStr, append(args, condArgs...)...)
+	if err != nil {
+		return 0, err
+	}
+	session.clearRowsCache(tableName)
+
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return affected, err
+	}
+	for name, value := range maintained {
+		row[name] = value
+	}
+	return affected, nil
+}
+
+// DeleteRow deletes the rows of the registered table by the conditions of
+// the session, the rows are soft deleted if the table has a deleted column
+// unless Unscoped is called.
+func (session *Session) DeleteRow(tableName string) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+	defer session.resetStatement()
+
+	table, err := session.rowTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	if !session.statement.cond.IsValid() && session.statement.idParam == nil {
+		return 0, ErrNeedDeletedCond
+	}
+	session.statement.mergePolicyCond()
+	if err := session.statement.processIDParam(); err != nil {
+		return 0, err
+	}
+
+	tableName = session.statement.TableName()
+	var sqlStr string
+	var args []interface{}
+	if col := table.DeletedColumn(); col != nil && !session.statement.unscoped {
+		condSQL, condArgs, err := builder.ToSQL(session.statement.cond.And(
+			session.engine.CondDeleted(session.engine.Quote(col.Name))))
+		if err != nil {
+			return 0, err
+		}
+		val, _ := session.engine.nowTime(col)
+		sqlStr = fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s", session.engine.Quote(tableName),
+			session.engine.Quote(col.Name), condSQL)
+		args = append([]interface{}{val}, condArgs...)
+	} else {
+		condSQL, condArgs, err := builder.ToSQL(session.statement.cond)
+		if err != nil {
+			return 0, err
+		}
+		sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s", session.engine.Quote(tableName), condSQL)
+		args = condArgs
+	}
+
+	res, err := session.exec(sqlStr, args...)
+	if err != nil {
+		return 0, err
+	}
+	session.clearRowsCache(tableName)
+	return res.RowsAffected()
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func newDynamicTable(name string, softDelete bool) *core.Table {
+	table := core.NewEmptyTable()
+	table.Name = name
+
+	id := core.NewColumn("id", "", core.SQLType{Name: core.BigInt}, 0, 0, false)
+	id.IsPrimaryKey = true
+	id.IsAutoIncrement = true
+	table.AddColumn(id)
+	table.PrimaryKeys = []string{"id"}
+	table.AutoIncrement = "id"
+
+	nameCol := core.NewColumn("name", "", core.SQLType{Name: core.Varchar}, 50, 0, false)
+	nameCol.Indexes["name"] = core.UniqueType
+	table.AddColumn(nameCol)
+	index := core.NewIndex("name", core.UniqueType)
+	index.AddColumn("name")
+	table.AddIndex(index)
+
+	table.AddColumn(core.NewColumn("score", "", core.SQLType{Name: core.Double}, 0, 0, true))
+	table.AddColumn(core.NewColumn("active", "", core.SQLType{Name: core.Bool}, 0, 0, true))
+
+	created := core.NewColumn("created", "", core.SQLType{Name: core.DateTime}, 0, 0, true)
+	created.IsCreated = true
+	table.AddColumn(created)
+	table.Created = map[string]bool{"created": true}
+
+	updated := core.NewColumn("updated", "", core.SQLType{Name: core.DateTime}, 0, 0, true)
+	updated.IsUpdated = true
+	table.AddColumn(updated)
+	table.Updated = "updated"
+
+	version := core.NewColumn("version", "", core.SQLType{Name: core.Int}, 0, 0, true)
+	version.IsVersion = true
+	table.AddColumn(version)
+	table.Version = "version"
+
+	if softDelete {
+		deleted := core.NewColumn("deleted", "", core.SQLType{Name: core.DateTime}, 0, 0, true)
+		deleted.IsDeleted = true
+		table.AddColumn(deleted)
+		table.Deleted = "deleted"
+	}
+	return table
+}
+
+func TestDynamicTable(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.RegisterTable(newDynamicTable("dynamic_record", true)))
+	defer testEngine.UnregisterTable("dynamic_record")
+
+	assert.NoError(t, testEngine.DropTables("dynamic_record"))
+	assert.NoError(t, testEngine.Sync2("dynamic_record"))
+	// sync again changes nothing
+	assert.NoError(t, testEngine.Sync2("dynamic_record"))
+	colSeq, _, err := testEngine.Dialect().GetColumns(testEngine.TableName("dynamic_record", true))
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"id", "name", "score", "active", "created", "updated", "version", "deleted"}, colSeq)
+
+	var row = map[string]interface{}{"name": "a", "score": 1.5, "active": true}
+	affected, err := testEngine.InsertRow("dynamic_record", row)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	assert.EqualValues(t, 1, row["id"])
+	assert.EqualValues(t, 1, row["version"])
+	assert.False(t, row["created"].(time.Time).IsZero())
+
+	_, err = testEngine.InsertRow("dynamic_record", map[string]interface{}{"name": "b", "score": 2})
+	assert.NoError(t, err)
+
+	var got = make(map[string]interface{})
+	has, err := testEngine.ID(1).GetRow("dynamic_record", got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, int64(1), got["id"])
+	assert.EqualValues(t, "a", got["name"])
+	assert.EqualValues(t, 1.5, got["score"])
+	assert.EqualValues(t, true, got["active"])
+	assert.EqualValues(t, int64(1), got["version"])
+	assert.Nil(t, got["deleted"])
+	assert.EqualValues(t, row["created"].(time.Time).Unix(), got["created"].(time.Time).Unix())
+
+	rows, err := testEngine.Where("score > ?", 1).Asc("name").FindRows("dynamic_record")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(rows))
+	assert.EqualValues(t, "b", rows[1]["name"])
+	assert.Nil(t, rows[1]["active"])
+
+	// the version is checked and increased
+	got["score"] = 3.5
+	affected, err = testEngine.UpdateRow("dynamic_record", got)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	assert.EqualValues(t, 2, got["version"])
+	got["version"] = int64(1)
+	affected, err = testEngine.UpdateRow("dynamic_record", got)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+
+	has, err = testEngine.Where("name = ?", "a").GetRow("dynamic_record", got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 3.5, got["score"])
+	assert.EqualValues(t, int64(2), got["version"])
+
+	_, err = testEngine.UpdateRow("dynamic_record", map[string]interface{}{"score": 1})
+	assert.Error(t, err)
+	_, err = testEngine.InsertRow("dynamic_record", map[string]interface{}{"nonexist": 1})
+	assert.EqualValues(t, ErrFieldIsNotExist{"nonexist", "dynamic_record"}, err)
+
+	// the rows are soft deleted
+	_, err = testEngine.DeleteRow("dynamic_record")
+	assert.EqualValues(t, ErrNeedDeletedCond, err)
+	affected, err = testEngine.ID(1).DeleteRow("dynamic_record")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	rows, err = testEngine.FindRows("dynamic_record")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(rows))
+	rows, err = testEngine.Unscoped().FindRows("dynamic_record")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(rows))
+	affected, err = testEngine.ID(1).Unscoped().DeleteRow("dynamic_record")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+
+	_, err = testEngine.FindRows("dynamic_unknown")
+	assert.EqualValues(t, ErrTableNotRegistered{"dynamic_unknown"}, err)
+}
+
+func TestDynamicTableUpdateKeysOnly(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	table := core.NewEmptyTable()
+	table.Name = "dynamic_tag"
+	id := core.NewColumn("id", "", core.SQLType{Name: core.BigInt}, 0, 0, false)
+	id.IsPrimaryKey = true
+	id.IsAutoIncrement = true
+	table.AddColumn(id)
+	table.PrimaryKeys = []string{"id"}
+	table.AutoIncrement = "id"
+	table.AddColumn(core.NewColumn("name", "", core.SQLType{Name: core.Varchar}, 50, 0, true))
+	assert.NoError(t, testEngine.RegisterTable(table))
+	defer testEngine.UnregisterTable("dynamic_tag")
+
+	assert.NoError(t, testEngine.DropTables("dynamic_tag"))
+	assert.NoError(t, testEngine.Sync2("dynamic_tag"))
+	var row = map[string]interface{}{"name": "a"}
+	_, err := testEngine.InsertRow("dynamic_tag", row)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, row["id"])
+
+	// nothing is updated if the row has the primary keys only
+	_, err = testEngine.UpdateRow("dynamic_tag", map[string]interface{}{"id": 1})
+	assert.EqualError(t, err, "No content found to be updated")
+	affected, err := testEngine.UpdateRow("dynamic_tag", map[string]interface{}{"id": 1, "name": "b"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+}
+
+func TestDynamicTableCache(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.RegisterTable(newDynamicTable("dynamic_cached", false)))
+	defer testEngine.UnregisterTable("dynamic_cached")
+
+	cacher := NewLRUCacher2(NewMemoryStore(), time.Hour, 10000)
+	testEngine.SetCacher("dynamic_cached", cacher)
+	defer testEngine.SetCacher("dynamic_cached", nil)
+
+	assert.NoError(t, testEngine.DropTables("dynamic_cached"))
+	assert.NoError(t, testEngine.Sync2("dynamic_cached"))
+
+	var row = map[string]interface{}{"name": "a", "score": 1}
+	_, err := testEngine.InsertRow("dynamic_cached", row)
+	assert.NoError(t, err)
+
+	var got = make(map[string]interface{})
+	has, err := testEngine.ID(row["id"]).GetRow("dynamic_cached", got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	var pk = core.PK{row["id"]}
+	sid, err := pk.ToString()
+	assert.NoError(t, err)
+	assert.NotNil(t, cacher.GetBean("dynamic_cached", sid))
+
+	// the cached row is not changed by the caller
+	got["name"] = "changed"
+	got = make(map[string]interface{})
+	has, err = testEngine.ID(row["id"]).GetRow("dynamic_cached", got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "a", got["name"])
+
+	// the cached rows are removed after updated
+	got["score"] = 2
+	_, err = testEngine.UpdateRow("dynamic_cached", got)
+	assert.NoError(t, err)
+	assert.Nil(t, cacher.GetBean("dynamic_cached", sid))
+	has, err = testEngine.ID(row["id"]).GetRow("dynamic_cached", got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 2, got["score"])
+
+	affected, err := testEngine.ID(row["id"]).DeleteRow("dynamic_cached")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	has, err = testEngine.ID(row["id"]).GetRow("dynamic_cached", got)
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
@@ -9,12 +9,14 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/gob"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -54,6 +56,28 @@ type Engine struct {
 	cachers    map[string]core.Cacher
 	cacherLock sync.RWMutex
 
+	columnExtras  sync.Map // map[*core.Column]*columnExtra
+	graphs        sync.Map // map[reflect.Type][]*graphRelation of the children tags
+	blobChunkSize int
+
+	maxIdentifierLength int
+
+	sqlMap *SQLMap
+
+	dynamicTables sync.Map // map[string]*core.Table registered by RegisterTable
+
+	policies    sync.Map // map[reflect.Type or string]PolicyFunc
+	policyAudit PolicyAuditFunc
+
+	sqlRedactor    SQLRedactor
+	redactPatterns []*regexp.Regexp
+	secretColumns  map[string]map[string]bool // the lower case names of the secret columns and their tables
+	secretLock     sync.RWMutex
+
+	retrier *retrier
+
+	bulkheads sync.Map // map[string]*bulkhead of the concurrency classes
+
 	defaultContext context.Context
 }
 
@@ -92,7 +116,7 @@ func (engine *Engine) BufferSize(size int) *Session {
 
 // CondDeleted returns the conditions whether a record is soft deleted.
 func (engine *Engine) CondDeleted(colName string) builder.Cond {
-	if engine.dialect.DBType() == core.MSSQL {
+	if !engine.Capabilities().ZeroTime {
 		return builder.IsNull{colName}
 	}
 	return builder.IsNull{colName}.Or(builder.Eq{colName: zeroTime1})
@@ -355,7 +379,7 @@ func (engine *Engine) Ping() error {
 func (engine *Engine) logSQL(sqlStr string, sqlArgs ...interface{}) {
 	if engine.showSQL && !engine.showExecTime {
 		if len(sqlArgs) > 0 {
-			engine.logger.Infof("[SQL] %v %#v", sqlStr, sqlArgs)
+			engine.logger.Infof("[SQL] %v %#v", sqlStr, engine.redactArgs(sqlStr, sqlArgs))
 		} else {
 			engine.logger.Infof("[SQL] %v", sqlStr)
 		}
@@ -470,11 +494,12 @@ func (engine *Engine) DumpTablesToFile(tables []*core.Table, fp string, tp ...co
 
 // DumpTables dump specify tables to io.Writer
 func (engine *Engine) DumpTables(tables []*core.Table, w io.Writer, tp ...core.DbType) error {
-	return engine.dumpTables(tables, w, tp...)
+	return engine.dumpTables(tables, w, nil, tp...)
 }
 
-// dumpTables dump database all table structs and data to w with specify db type
-func (engine *Engine) dumpTables(tables []*core.Table, w io.Writer, tp ...core.DbType) error {
+// dumpTables dump database all table structs and data to w with specify db type,
+// the values of the sensitive columns are transformed if anonymizer is not nil
+func (engine *Engine) dumpTables(tables []*core.Table, w io.Writer, anonymizer *Anonymizer, tp ...core.DbType) error {
 	var dialect core.Dialect
 	var distDBName string
 	if len(tp) == 0 {
@@ -507,6 +532,7 @@ func (engine *Engine) dumpTables(tables []*core.Table, w io.Writer, tp ...core.D
 			return err
 		}
 		for _, index := range table.Indexes {
+			index = limitIndex(table.Name, index, engine.maxIdentifierLengthOf(dialect.DBType()))
 			_, err = io.WriteString(w, dialect.CreateIndexSql(table.Name, index)+";\n")
 			if err != nil {
 				return err
@@ -517,6 +543,14 @@ func (engine *Engine) dumpTables(tables []*core.Table, w io.Writer, tp ...core.D
 		colNames := engine.dialect.Quote(strings.Join(cols, engine.dialect.Quote(", ")))
 		destColNames := dialect.Quote(strings.Join(cols, dialect.Quote(", ")))
 
+		var rules []anonymizeRule
+		if anonymizer != nil {
+			rules, err = anonymizer.tableRules(engine, table, cols)
+			if err != nil {
+				return err
+			}
+		}
+
 		rows, err := engine.DB().Query("SELECT " + colNames + " FROM " + engine.Quote(table.Name))
 		if err != nil {
 			return err
@@ -542,7 +576,10 @@ func (engine *Engine) dumpTables(tables []*core.Table, w io.Writer, tp ...core.D
 					return errors.New("unknow column error")
 				}
 
-				if d == nil {
+				if len(rules) > 0 && rules[i].name != "" {
+					// the transformed values are formatted by their types
+					temp += ", " + formatAnonymized(dialect, anonymizer.transform(rules[i], col, d))
+				} else if d == nil {
 					temp += ", NULL"
 				} else if col.SQLType.IsText() || col.SQLType.IsTime() {
 					var v = fmt.Sprintf("%s", d)
@@ -551,7 +588,7 @@ func (engine *Engine) dumpTables(tables []*core.Table, w io.Writer, tp ...core.D
 					} else {
 						temp += ", '" + strings.Replace(v, "'", "''", -1) + "'"
 					}
-				} else if col.SQLType.IsBlob() {
+				} else if col.SQLType.IsBlob() || isSpatialType(col.SQLType) {
 					if reflect.TypeOf(d).Kind() == reflect.Slice {
 						temp += fmt.Sprintf(", %s", dialect.FormatBytes(d.([]byte)))
 					} else if reflect.TypeOf(d).Kind() == reflect.String {
@@ -601,7 +638,7 @@ func (engine *Engine) dumpTables(tables []*core.Table, w io.Writer, tp ...core.D
 
 		// FIXME: Hack for postgres
 		if string(dialect.DBType()) == core.POSTGRES && table.AutoIncrColumn() != nil {
-			_, err = io.WriteString(w, "SELECT setval('"+table.Name+"_id_seq', COALESCE((SELECT MAX("+table.AutoIncrColumn().Name+") + 1 FROM "+dialect.Quote(table.Name)+"), 1), false);\n")
+			_, err = io.WriteString(w, "SELECT setval(pg_get_serial_sequence('"+table.Name+"', '"+table.AutoIncrColumn().Name+"'), COALESCE((SELECT MAX("+table.AutoIncrColumn().Name+") + 1 FROM "+dialect.Quote(table.Name)+"), 1), false);\n")
 			if err != nil {
 				return err
 			}
@@ -638,6 +675,13 @@ func (engine *Engine) ID(id interface{}) *Session {
 	return session.ID(id)
 }
 
+// Idempotent marks the writes could be retried by the retry policy
+func (engine *Engine) Idempotent() *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Idempotent()
+}
+
 // Before apply before Processor, affected bean is passed to closure arg
 func (engine *Engine) Before(closures func(interface{})) *Session {
 	session := engine.NewSession()
@@ -666,6 +710,13 @@ func (engine *Engine) StoreEngine(storeEngine string) *Session {
 	return session.StoreEngine(storeEngine)
 }
 
+// OnlineDDL builds the indexes without blocking the writes of the table
+func (engine *Engine) OnlineDDL(online ...bool) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.OnlineDDL(online...)
+}
+
 // Distinct use for distinct columns. Caution: when you are using cache,
 // distinct will not be cached because cache system need id,
 // but distinct will not provide id
@@ -682,6 +733,13 @@ func (engine *Engine) Select(str string) *Session {
 	return session.Select(str)
 }
 
+// Class sets the concurrency class of the statements
+func (engine *Engine) Class(class string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Class(class)
+}
+
 // Cols only use the parameters as select or update columns
 func (engine *Engine) Cols(columns ...string) *Session {
 	session := engine.NewSession()
@@ -908,13 +966,96 @@ func addIndex(indexName string, table *core.Table, col *core.Column, indexType i
 	}
 }
 
+// isEmbeddedStruct returns true if the field is an untagged anonymous struct
+// or pointer to struct which should be flattened into its parent like
+// encoding/json does. Time types and types implementing core.Conversion,
+// driver.Valuer or sql.Scanner are still mapped as a single column.
+func isEmbeddedStruct(parent reflect.Type, field reflect.StructField, fieldValue reflect.Value) bool {
+	if !field.Anonymous {
+		return false
+	}
+
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Ptr {
+		// an unexported embedded pointer could not be allocated when scanning
+		if field.PkgPath != "" || fieldType.Elem() == parent {
+			return false
+		}
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() != reflect.Struct || fieldType.ConvertibleTo(core.TimeType) {
+		return false
+	}
+
+	for _, tp := range []reflect.Type{tpConversion, tpValuer, tpScanner} {
+		if fieldType.Implements(tp) || reflect.PtrTo(fieldType).Implements(tp) {
+			return false
+		}
+	}
+	return true
+}
+
+// shadowPromotedColumns applies Go's field shadowing rules to the columns
+// promoted from anonymous embedded structs. A promoted column is dropped when
+// another column with the same name is declared at a shallower depth, or when
+// it is ambiguous with another column at the same depth. The columns of the
+// explicit extends tags take part in the shadowing but are never dropped, so
+// that the joined structs could still have the columns with the same names.
+func shadowPromotedColumns(table *core.Table, promoted, extended []*core.Column) *core.Table {
+	var depthOf = func(col *core.Column) int {
+		return strings.Count(col.FieldName, ".")
+	}
+
+	var isPromoted = make(map[*core.Column]bool, len(promoted))
+	for _, col := range promoted {
+		isPromoted[col] = true
+	}
+	for _, col := range extended {
+		isPromoted[col] = true
+	}
+
+	var dropped = make(map[*core.Column]bool)
+	for _, col := range promoted {
+		for _, other := range table.Columns() {
+			if other == col || !strings.EqualFold(other.Name, col.Name) {
+				continue
+			}
+			if depthOf(other) < depthOf(col) || (depthOf(other) == depthOf(col) && isPromoted[other]) {
+				dropped[col] = true
+				break
+			}
+		}
+	}
+
+	if len(dropped) == 0 {
+		return table
+	}
+
+	newTable := core.NewEmptyTable()
+	newTable.Name = table.Name
+	newTable.Type = table.Type
+	for _, col := range table.Columns() {
+		if dropped[col] {
+			continue
+		}
+		newTable.AddColumn(col)
+		for indexName, indexType := range col.Indexes {
+			addIndex(indexName, newTable, col, indexType)
+		}
+	}
+	return newTable
+}
+
 // TableName table name interface to define customerize table name
 type TableName interface {
 	TableName() string
 }
 
 var (
-	tpTableName = reflect.TypeOf((*TableName)(nil)).Elem()
+	tpTableName  = reflect.TypeOf((*TableName)(nil)).Elem()
+	tpConversion = reflect.TypeOf((*core.Conversion)(nil)).Elem()
+	tpValuer     = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	tpScanner    = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
 )
 
 func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
@@ -925,6 +1066,8 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 
 	var idFieldColName string
 	var hasCacheTag, hasNoCacheTag bool
+	var promoted, extended []*core.Column
+	var relations []*graphRelation
 
 	for i := 0; i < t.NumField(); i++ {
 		tag := t.Field(i).Tag
@@ -969,9 +1112,11 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 						ctx.params = []string{tagPrefix}
 					}
 
+					var start = len(table.Columns())
 					if err := ExtendsTagHandler(&ctx); err != nil {
 						return nil, err
 					}
+					extended = append(extended, table.Columns()[start:]...)
 					continue
 				}
 
@@ -1027,8 +1172,20 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 					}
 				}
 
+				if ctx.children != nil {
+					ctx.children.fieldIndex = i
+					relations = append(relations, ctx.children)
+					continue
+				}
+
 				if col.SQLType.Name == "" {
-					col.SQLType = core.Type2SQLType(fieldType)
+					if fieldType == tpReader {
+						col.SQLType = core.SQLType{Name: core.Blob}
+					} else if fieldType.Implements(tpGeometryValue) {
+						col.SQLType = core.SQLType{Name: Geometry}
+					} else {
+						col.SQLType = core.Type2SQLType(fieldType)
+					}
 				}
 				engine.dialect.SqlType(col)
 				if col.Length == 0 {
@@ -1045,12 +1202,28 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 					ctx.indexNames[col.Name] = core.UniqueType
 				} else if ctx.isIndex {
 					ctx.indexNames[col.Name] = core.IndexType
+				} else if ctx.isSpatial {
+					ctx.indexNames[col.Name] = SpatialIndexType
 				}
 
 				for indexName, indexType := range ctx.indexNames {
 					addIndex(indexName, table, col, indexType)
 				}
 			}
+		} else if isEmbeddedStruct(t, t.Field(i), fieldValue) {
+			var ctx = tagContext{
+				table:      table,
+				col:        &core.Column{FieldName: t.Field(i).Name},
+				fieldValue: fieldValue,
+				indexNames: make(map[string]int),
+				engine:     engine,
+			}
+			var start = len(table.Columns())
+			if err := ExtendsTagHandler(&ctx); err != nil {
+				return nil, err
+			}
+			promoted = append(promoted, table.Columns()[start:]...)
+			continue
 		} else {
 			var sqlType core.SQLType
 			if fieldValue.CanAddr() {
@@ -1060,6 +1233,10 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 			}
 			if _, ok := fieldValue.Interface().(core.Conversion); ok {
 				sqlType = core.SQLType{Name: core.Text}
+			} else if fieldType == tpReader {
+				sqlType = core.SQLType{Name: core.Blob}
+			} else if fieldType.Implements(tpGeometryValue) {
+				sqlType = core.SQLType{Name: Geometry}
 			} else {
 				sqlType = core.Type2SQLType(fieldType)
 			}
@@ -1074,11 +1251,21 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 		if col.IsAutoIncrement {
 			col.Nullable = false
 		}
+		if fieldType == tpReader {
+			// io.Reader fields are streamed and never selected by default
+			extra := engine.setColumnExtra(col)
+			extra.stream = true
+			extra.lazy = true
+		}
 
 		table.AddColumn(col)
 
 	} // end for
 
+	if len(promoted) > 0 {
+		table = shadowPromotedColumns(table, promoted, extended)
+	}
+
 	if idFieldColName != "" && len(table.PrimaryKeys) == 0 {
 		col := table.GetColumn(idFieldColName)
 		col.IsPrimaryKey = true
@@ -1102,6 +1289,9 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 		engine.setCacher(table.Name, nil)
 	}
 
+	engine.addSecretColumns(table)
+	engine.graphs.Store(t, relations)
+
 	return table, nil
 }
 
@@ -1287,6 +1477,9 @@ func (engine *Engine) Sync(beans ...interface{}) error {
 			}
 		} else {
 			for _, col := range table.Columns() {
+				if engine.columnExpr(col) != "" {
+					continue
+				}
 				isExist, err := engine.dialect.IsColumnExist(tableNameNoSchema, col.Name)
 				if err != nil {
 					return err
@@ -1399,6 +1592,20 @@ func (engine *Engine) DropIndexes(bean interface{}) error {
 	return session.DropIndexes(bean)
 }
 
+// InvalidIndexes returns the invalid indexes left by the failed concurrent builds
+func (engine *Engine) InvalidIndexes(beanOrTableName interface{}) ([]string, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.InvalidIndexes(beanOrTableName)
+}
+
+// DropInvalidIndexes drops the invalid indexes left by the failed concurrent builds
+func (engine *Engine) DropInvalidIndexes(beanOrTableName interface{}) ([]string, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.DropInvalidIndexes(beanOrTableName)
+}
+
 // Exec raw sql
 func (engine *Engine) Exec(sqlOrArgs ...interface{}) (sql.Result, error) {
 	session := engine.NewSession()
@@ -1441,6 +1648,13 @@ func (engine *Engine) InsertOne(bean interface{}) (int64, error) {
 	return session.InsertOne(bean)
 }
 
+// InsertGraph inserts the bean and its children in a transaction
+func (engine *Engine) InsertGraph(bean interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.InsertGraph(bean)
+}
+
 // Update records, bean's non-empty fields are updated contents,
 // condiBean' non-empty filds are conditions
 // CAUTION:
@@ -1460,6 +1674,55 @@ func (engine *Engine) Delete(bean interface{}) (int64, error) {
 	return session.Delete(bean)
 }
 
+// DeleteGraph deletes the bean and its children in a transaction
+func (engine *Engine) DeleteGraph(bean interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.DeleteGraph(bean)
+}
+
+// SyncRows makes the rows in the scope the same as the given rows in a transaction
+func (engine *Engine) SyncRows(rowsSlicePtr interface{}, scope builder.Cond, keys ...string) (*SyncRowsResult, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.SyncRows(rowsSlicePtr, scope, keys...)
+}
+
+// InsertRow inserts a row into the table registered by RegisterTable
+func (engine *Engine) InsertRow(tableName string, row map[string]interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.InsertRow(tableName, row)
+}
+
+// GetRow retrieves one row of the table registered by RegisterTable
+func (engine *Engine) GetRow(tableName string, row map[string]interface{}) (bool, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.GetRow(tableName, row)
+}
+
+// FindRows retrieves the rows of the table registered by RegisterTable
+func (engine *Engine) FindRows(tableName string) ([]map[string]interface{}, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.FindRows(tableName)
+}
+
+// UpdateRow updates a row of the table registered by RegisterTable by its primary keys
+func (engine *Engine) UpdateRow(tableName string, row map[string]interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.UpdateRow(tableName, row)
+}
+
+// DeleteRow deletes the rows of the table registered by RegisterTable
+func (engine *Engine) DeleteRow(tableName string) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.DeleteRow(tableName)
+}
+
 // Get retrieve one record from table, bean's non-empty fields
 // are conditions
 func (engine *Engine) Get(bean interface{}) (bool, error) {
@@ -1468,6 +1731,47 @@ func (engine *Engine) Get(bean interface{}) (bool, error) {
 	return session.Get(bean)
 }
 
+// Load loads the lazy columns of a bean or a slice of beans by primary keys
+func (engine *Engine) Load(beans interface{}, cols ...string) error {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.Load(beans, cols...)
+}
+
+// Move moves the tree node and its descendants under the parent node
+func (engine *Engine) Move(node, parent interface{}) error {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.Move(node, parent)
+}
+
+// Ancestors finds the ancestors of the tree node into beans
+func (engine *Engine) Ancestors(node interface{}, beans interface{}) error {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.Ancestors(node, beans)
+}
+
+// Descendants finds the descendants of the tree node into beans
+func (engine *Engine) Descendants(node interface{}, depth int, beans interface{}) error {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.Descendants(node, depth, beans)
+}
+
+// OpenBlob returns a reader of the blob column of the record which has the
+// same primary keys as the bean, the reader should be closed after used
+func (engine *Engine) OpenBlob(bean interface{}, colName string) (io.ReadCloser, error) {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	r, err := session.OpenBlob(bean, colName)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
 // Exist returns true if the record exist otherwise return false
 func (engine *Engine) Exist(bean ...interface{}) (bool, error) {
 	session := engine.NewSession()
@@ -1499,6 +1803,14 @@ func (engine *Engine) Iterate(bean interface{}, fun IterFunc) error {
 	return session.Iterate(bean, fun)
 }
 
+// IterateParallel iterates the records and calls fun from workers goroutines,
+// see Session.IterateParallel
+func (engine *Engine) IterateParallel(bean interface{}, workers int, fun IterFunc, emit ...IterFunc) error {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.IterateParallel(bean, workers, fun, emit...)
+}
+
 // Rows return sql.Rows compatible Rows obj, as a forward Iterator object for iterating record by record, bean's non-empty fields
 // are conditions.
 func (engine *Engine) Rows(bean interface{}) (*Rows, error) {
@@ -1624,8 +1936,8 @@ func (engine *Engine) formatTime(sqlTypeName string, t time.Time) (v interface{}
 	case core.DateTime, core.TimeStamp:
 		v = t.Format("2006-01-02 15:04:05")
 	case core.TimeStampz:
-		if engine.dialect.DBType() == core.MSSQL {
-			v = t.Format("2006-01-02T15:04:05.9999999Z07:00")
+		if layout := engine.Capabilities().TimestampzLayout; layout != "" {
+			v = t.Format(layout)
 		} else {
 			v = t.Format(time.RFC3339Nano)
 		}
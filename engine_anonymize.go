@@ -0,0 +1,347 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"xorm.io/core"
+)
+
+// the supported anonymizing rules
+const (
+	anonymizeHash   = "hash"   // hmac of the value, numbers for numeric columns
+	anonymizeEmail  = "email"  // a fake email address
+	anonymizeName   = "name"   // a fake full name
+	anonymizeFormat = "format" // random digits and letters with the same format
+	anonymizeNull   = "null"   // NULL
+	anonymizeFixed  = "fixed"  // the fixed value given by the argument
+)
+
+var (
+	anonymizeFirstNames = []string{"James", "Mary", "John", "Patricia", "Robert",
+		"Jennifer", "Michael", "Linda", "William", "Elizabeth", "David", "Barbara"}
+	anonymizeLastNames = []string{"Smith", "Johnson", "Williams", "Brown", "Jones",
+		"Garcia", "Miller", "Davis", "Wilson", "Anderson", "Taylor", "Moore"}
+)
+
+// anonymizeRule is a parsed rule like email or fixed(N/A)
+type anonymizeRule struct {
+	name string
+	arg  string
+}
+
+func newAnonymizeRule(name string, args ...string) (anonymizeRule, error) {
+	rule := anonymizeRule{
+		name: strings.ToLower(strings.TrimSpace(name)),
+		arg:  strings.TrimSpace(strings.Join(args, ",")),
+	}
+	switch rule.name {
+	case anonymizeHash, anonymizeEmail, anonymizeName, anonymizeFormat, anonymizeNull:
+	case anonymizeFixed:
+		rule.arg = strings.Trim(rule.arg, "'")
+	default:
+		return rule, fmt.Errorf("unknown anonymizing rule %s", name)
+	}
+	return rule, nil
+}
+
+// isNumeric returns true if the rule keeps the values of numeric columns numeric
+func (rule anonymizeRule) isNumeric() bool {
+	switch rule.name {
+	case anonymizeEmail, anonymizeName:
+		return false
+	case anonymizeFixed:
+		_, err := strconv.ParseFloat(rule.arg, 64)
+		return err == nil
+	}
+	return true
+}
+
+// parseAnonymizeRule parses the rule like email or fixed(N/A)
+func parseAnonymizeRule(s string) (anonymizeRule, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "("); i > 0 && strings.HasSuffix(s, ")") {
+		return newAnonymizeRule(s[:i], s[i+1:len(s)-1])
+	}
+	return newAnonymizeRule(s)
+}
+
+// Anonymizer transforms the values of the sensitive columns when dumping so
+// that the personal data could be copied to other environments. The rules
+// are declared by pii tags on the struct fields or loaded from a rules file,
+// the rules file takes precedence.
+//
+// The values are transformed with a keyed hash, so the same value is always
+// transformed to the same result in all the tables and join keys still match.
+type Anonymizer struct {
+	secret []byte
+	rules  map[string]anonymizeRule // table.column -> rule
+}
+
+// NewAnonymizer creates an anonymizer with the secret key of the hash
+func NewAnonymizer(secret []byte) *Anonymizer {
+	return &Anonymizer{
+		secret: secret,
+		rules:  make(map[string]anonymizeRule),
+	}
+}
+
+// AddRule adds a rule like email or fixed(N/A) for the column of the table
+func (a *Anonymizer) AddRule(tableName, colName, rule string) error {
+	r, err := parseAnonymizeRule(rule)
+	if err != nil {
+		return err
+	}
+	a.rules[strings.ToLower(tableName+"."+colName)] = r
+	return nil
+}
+
+// LoadRules loads the rules from r, one rule per line like
+//
+//	# comment
+//	user.email = email
+//	user.note = fixed(N/A)
+func (a *Anonymizer) LoadRules(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	var lineNo int
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		names := strings.SplitN(strings.TrimSpace(kv[0]), ".", 2)
+		if len(kv) != 2 || len(names) != 2 {
+			return fmt.Errorf("line %d: rule should be table.column = rule", lineNo)
+		}
+		if err := a.AddRule(names[0], names[1], kv[1]); err != nil {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadRulesFile loads the rules from the file, see LoadRules
+func (a *Anonymizer) LoadRulesFile(fp string) error {
+	f, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return a.LoadRules(f)
+}
+
+// tableRules returns the rules of the columns, from the rules file or the
+// pii tags of the mapped structs
+func (a *Anonymizer) tableRules(engine *Engine, table *core.Table, cols []string) ([]anonymizeRule, error) {
+	var tagRules = make(map[string]anonymizeRule)
+	engine.mutex.RLock()
+	for _, t := range engine.Tables {
+		if !strings.EqualFold(t.Name, table.Name) {
+			continue
+		}
+		for _, col := range t.Columns() {
+			if extra := engine.columnExtra(col); extra != nil && extra.pii.name != "" {
+				tagRules[strings.ToLower(col.Name)] = extra.pii
+			}
+		}
+	}
+	engine.mutex.RUnlock()
+
+	var rules = make([]anonymizeRule, len(cols))
+	var hasRule bool
+	for i, col := range cols {
+		rule, ok := a.rules[strings.ToLower(table.Name+"."+col)]
+		if !ok {
+			rule = tagRules[strings.ToLower(col)]
+		}
+		if c := table.GetColumn(col); c != nil && c.SQLType.IsNumeric() && !rule.isNumeric() {
+			return nil, fmt.Errorf("anonymizing rule %s gives non-numeric values for the numeric column %s.%s", rule.name, table.Name, col)
+		}
+		rules[i] = rule
+		hasRule = hasRule || rule.name != ""
+	}
+	if hasRule && len(a.secret) == 0 {
+		return nil, errors.New("anonymizer needs a secret")
+	}
+	return rules, nil
+}
+
+// keyStream returns n bytes derived from the value with the secret key
+func (a *Anonymizer) keyStream(value string, n int) []byte {
+	var stream = make([]byte, 0, n+sha256.Size)
+	var counter [4]byte
+	for i := uint32(0); len(stream) < n; i++ {
+		mac := hmac.New(sha256.New, a.secret)
+		binary.BigEndian.PutUint32(counter[:], i)
+		mac.Write(counter[:])
+		mac.Write([]byte(value))
+		stream = mac.Sum(stream)
+	}
+	return stream[:n]
+}
+
+// transform returns the anonymized value, NULL is kept
+func (a *Anonymizer) transform(rule anonymizeRule, col *core.Column, value interface{}) interface{} {
+	if value == nil || rule.name == anonymizeNull {
+		return nil
+	}
+
+	var s string
+	switch t := value.(type) {
+	case []byte:
+		s = string(t)
+	case time.Time:
+		s = t.Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprintf("%v", t)
+	}
+
+	var result string
+	switch rule.name {
+	case anonymizeFixed:
+		result = rule.arg
+		if col.SQLType.IsNumeric() {
+			return anonymizedNumber(result)
+		}
+	case anonymizeHash:
+		if col.SQLType.IsNumeric() {
+			// the hash is kept in the range of the column, so the join
+			// keys are still mapped consistently
+			hash := int64(binary.BigEndian.Uint64(a.keyStream(s, 8)) >> 1)
+			if max := anonymizeMaxInt(col); max < math.MaxInt64 {
+				hash %= max + 1
+			}
+			return hash
+		}
+		result = hex.EncodeToString(a.keyStream(s, 16))
+	case anonymizeEmail:
+		local, domain := "user_"+hex.EncodeToString(a.keyStream(s, 5)), "@example.com"
+		// the local part is shortened to keep the email valid in the column
+		if col.SQLType.IsText() && col.Length > len(domain) && len(local)+len(domain) > col.Length {
+			local = local[:col.Length-len(domain)]
+		}
+		result = local + domain
+	case anonymizeName:
+		ks := a.keyStream(s, 2)
+		result = anonymizeFirstNames[int(ks[0])%len(anonymizeFirstNames)] + " " +
+			anonymizeLastNames[int(ks[1])%len(anonymizeLastNames)]
+	case anonymizeFormat:
+		runes := []rune(s)
+		ks := a.keyStream(s, len(runes))
+		for i, r := range runes {
+			switch {
+			case unicode.IsDigit(r):
+				runes[i] = rune('0' + ks[i]%10)
+			case unicode.IsUpper(r):
+				runes[i] = rune('A' + ks[i]%26)
+			case unicode.IsLetter(r):
+				runes[i] = rune('a' + ks[i]%26)
+			}
+		}
+		result = string(runes)
+		if col.SQLType.IsNumeric() {
+			return anonymizedNumber(result)
+		}
+	}
+
+	if col.SQLType.IsText() && col.Length > 0 && utf8.RuneCountInString(result) > col.Length {
+		result = string([]rune(result)[:col.Length])
+	}
+	return result
+}
+
+// anonymizeMaxInt returns the max value of the integer or fixed point
+// column, math.MaxInt64 if the column could hold any int64
+func anonymizeMaxInt(col *core.Column) int64 {
+	switch col.SQLType.Name {
+	case core.Bit, core.Bool:
+		return 1
+	case core.TinyInt:
+		return math.MaxInt8
+	case core.SmallInt:
+		return math.MaxInt16
+	case core.MediumInt:
+		return 1<<23 - 1
+	case core.Int, core.Integer, core.Serial:
+		return math.MaxInt32
+	case core.Decimal, core.Numeric:
+		digits := col.Length - col.Length2
+		if col.Length <= 0 || digits > 18 {
+			return math.MaxInt64
+		}
+		var max int64 = 1
+		for i := 0; i < digits; i++ {
+			max *= 10
+		}
+		return max - 1
+	}
+	return math.MaxInt64
+}
+
+// anonymizedNumber parses the result of a numeric column, NULL is returned
+// if it's not a number
+func anonymizedNumber(s string) interface{} {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return nil
+}
+
+// formatAnonymized formats the transformed value as a SQL literal by its type
+func formatAnonymized(dialect core.Dialect, value interface{}) string {
+	switch t := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	case []byte:
+		return dialect.FormatBytes(t)
+	case string:
+		return "'" + strings.Replace(t, "'", "''", -1) + "'"
+	}
+	return "'" + strings.Replace(fmt.Sprintf("%v", value), "'", "''", -1) + "'"
+}
+
+// DumpAllAnonymized dumps all the tables like DumpAll with the sensitive
+// columns anonymized while streaming the rows
+func (engine *Engine) DumpAllAnonymized(w io.Writer, anonymizer *Anonymizer, tp ...core.DbType) error {
+	tables, err := engine.DBMetas()
+	if err != nil {
+		return err
+	}
+	return engine.DumpTablesAnonymized(tables, w, anonymizer, tp...)
+}
+
+// DumpTablesAnonymized dumps the tables like DumpTables with the sensitive
+// columns anonymized while streaming the rows
+func (engine *Engine) DumpTablesAnonymized(tables []*core.Table, w io.Writer, anonymizer *Anonymizer, tp ...core.DbType) error {
+	if anonymizer == nil {
+		return errors.New("anonymizer is nil")
+	}
+	return engine.dumpTables(tables, w, anonymizer, tp...)
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"xorm.io/core"
+)
+
+// columnExtra holds the column attributes defined by xorm tags which could
+// not be stored on core.Column
+type columnExtra struct {
+	expr   string        // the sql expression of a computed read-only column
+	lazy   bool          // the column is not selected by default and loaded by Load
+	stream bool          // the field is an io.Reader which is written chunk by chunk
+	pii    anonymizeRule // how the column is anonymized when dumping
+	tree   string        // the parent or path column of a tree
+	secret bool          // the arguments bound to the column are masked in the SQL logs
+
+	renamedFrom string // the old name of the column which is renamed by Sync2
+}
+
+// columnExtra returns the extra attributes of the column, nil if there is none
+func (engine *Engine) columnExtra(col *core.Column) *columnExtra {
+	if col == nil {
+		return nil
+	}
+	extra, ok := engine.columnExtras.Load(col)
+	if !ok {
+		return nil
+	}
+	return extra.(*columnExtra)
+}
+
+// setColumnExtra returns the extra attributes of the column and creates it
+// if there is none
+func (engine *Engine) setColumnExtra(col *core.Column) *columnExtra {
+	extra, _ := engine.columnExtras.LoadOrStore(col, &columnExtra{})
+	return extra.(*columnExtra)
+}
+
+// columnExpr returns the sql expression if the column is a computed column
+func (engine *Engine) columnExpr(col *core.Column) string {
+	if extra := engine.columnExtra(col); extra != nil {
+		return extra.expr
+	}
+	return ""
+}
+
+// isLazyColumn returns true if the column should be loaded on demand
+func (engine *Engine) isLazyColumn(col *core.Column) bool {
+	if extra := engine.columnExtra(col); extra != nil {
+		return extra.lazy
+	}
+	return false
+}
+
+// isStreamColumn returns true if the field of the column is an io.Reader
+func (engine *Engine) isStreamColumn(col *core.Column) bool {
+	if extra := engine.columnExtra(col); extra != nil {
+		return extra.stream
+	}
+	return false
+}
+
+// hasStreamColumn returns true if the table has any io.Reader field
+func (engine *Engine) hasStreamColumn(table *core.Table) bool {
+	for _, col := range table.Columns() {
+		if engine.isStreamColumn(col) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasExprColumn returns true if the table has any computed column
+func (engine *Engine) hasExprColumn(table *core.Table) bool {
+	for _, col := range table.Columns() {
+		if engine.columnExpr(col) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ddlTable returns the table which will be used to generate DDL, the
+// computed columns are removed since they don't exist on database.
+func (engine *Engine) ddlTable(table *core.Table) *core.Table {
+	if !engine.hasExprColumn(table) {
+		return table
+	}
+
+	newTable := core.NewEmptyTable()
+	newTable.Name = table.Name
+	newTable.Type = table.Type
+	newTable.StoreEngine = table.StoreEngine
+	newTable.Charset = table.Charset
+	newTable.Comment = table.Comment
+	newTable.Cacher = table.Cacher
+	for _, col := range table.Columns() {
+		if engine.columnExpr(col) != "" {
+			continue
+		}
+		newTable.AddColumn(col)
+	}
+	for name, index := range table.Indexes {
+		newTable.Indexes[name] = index
+	}
+	return newTable
+}
@@ -31,7 +31,7 @@ func (engine *Engine) buildConds(table *core.Table, bean interface{},
 			continue
 		}
 
-		if engine.dialect.DBType() == core.MSSQL && (col.SQLType.Name == core.Text || col.SQLType.IsBlob() || col.SQLType.Name == core.TimeStampz) {
+		if !engine.Capabilities().CompareLOB && (col.SQLType.Name == core.Text || col.SQLType.IsBlob() || col.SQLType.Name == core.TimeStampz) {
 			continue
 		}
 		if col.SQLType.IsJson() {
@@ -39,7 +39,9 @@ func (engine *Engine) buildConds(table *core.Table, bean interface{},
 		}
 
 		var colName string
-		if addedTableName {
+		if expr := engine.columnExpr(col); expr != "" {
+			colName = "(" + expr + ")"
+		} else if addedTableName {
 			var nm = tableName
 			if len(aliasName) > 0 {
 				nm = aliasName
@@ -66,6 +68,11 @@ func (engine *Engine) buildConds(table *core.Table, bean interface{},
 			continue
 		}
 
+		// the spatial values could not be compared by equal
+		if isSpatialType(col.SQLType) {
+			continue
+		}
+
 		fieldType := reflect.TypeOf(fieldValue.Interface())
 		requiredField := useAllCols
 
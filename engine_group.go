@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"xorm.io/core"
@@ -89,6 +90,16 @@ func (eg *EngineGroup) NewSession() *Session {
 	return sess
 }
 
+// LoadSQLMap loads the sql files in the directory as the sql map of the engines
+func (eg *EngineGroup) LoadSQLMap(dir string) error {
+	m, err := NewSQLMap(dir)
+	if err != nil {
+		return err
+	}
+	eg.SetSQLMap(m)
+	return nil
+}
+
 // Master returns the master engine
 func (eg *EngineGroup) Master() *Engine {
 	return eg.Engine
@@ -108,6 +119,40 @@ func (eg *EngineGroup) Ping() error {
 	return nil
 }
 
+// RegisterPolicy registers the row level policy of the bean's table to the engines
+func (eg *EngineGroup) RegisterPolicy(bean interface{}, policy PolicyFunc) error {
+	if err := eg.Engine.RegisterPolicy(bean, policy); err != nil {
+		return err
+	}
+	for i := 0; i < len(eg.slaves); i++ {
+		if err := eg.slaves[i].RegisterPolicy(bean, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterSpatialTypes enables the spatial types as the tags of the engines
+func (eg *EngineGroup) RegisterSpatialTypes() {
+	eg.Engine.RegisterSpatialTypes()
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].RegisterSpatialTypes()
+	}
+}
+
+// RegisterTable registers the table defined at runtime to the engines
+func (eg *EngineGroup) RegisterTable(table *core.Table) error {
+	if err := eg.Engine.RegisterTable(table); err != nil {
+		return err
+	}
+	for i := 0; i < len(eg.slaves); i++ {
+		if err := eg.slaves[i].RegisterTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SetColumnMapper set the column name mapping rule
 func (eg *EngineGroup) SetColumnMapper(mapper core.IMapper) {
 	eg.Engine.ColumnMapper = mapper
@@ -116,6 +161,14 @@ func (eg *EngineGroup) SetColumnMapper(mapper core.IMapper) {
 	}
 }
 
+// SetConcurrencyLimit limits the concurrent statements of the class on the engines
+func (eg *EngineGroup) SetConcurrencyLimit(class string, limit ConcurrencyLimit) {
+	eg.Engine.SetConcurrencyLimit(class, limit)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetConcurrencyLimit(class, limit)
+	}
+}
+
 // SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
 func (eg *EngineGroup) SetConnMaxLifetime(d time.Duration) {
 	eg.Engine.SetConnMaxLifetime(d)
@@ -172,12 +225,52 @@ func (eg *EngineGroup) SetMaxOpenConns(conns int) {
 	}
 }
 
+// SetPolicyAudit sets the function called when a row level policy is bypassed
+func (eg *EngineGroup) SetPolicyAudit(audit PolicyAuditFunc) {
+	eg.Engine.SetPolicyAudit(audit)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetPolicyAudit(audit)
+	}
+}
+
 // SetPolicy set the group policy
 func (eg *EngineGroup) SetPolicy(policy GroupPolicy) *EngineGroup {
 	eg.policy = policy
 	return eg
 }
 
+// SetRedactPatterns masks the string arguments matching the patterns in the SQL logs of the engines
+func (eg *EngineGroup) SetRedactPatterns(patterns ...*regexp.Regexp) {
+	eg.Engine.SetRedactPatterns(patterns...)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetRedactPatterns(patterns...)
+	}
+}
+
+// SetRetryPolicy sets the policy of retrying the transient failures of the engines
+func (eg *EngineGroup) SetRetryPolicy(policy *RetryPolicy) {
+	eg.Engine.SetRetryPolicy(policy)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetRetryPolicy(policy)
+	}
+}
+
+// SetSQLMap sets the sql map of the engines
+func (eg *EngineGroup) SetSQLMap(m *SQLMap) {
+	eg.Engine.SetSQLMap(m)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetSQLMap(m)
+	}
+}
+
+// SetSQLRedactor sets the redactor of the SQL logs of the engines
+func (eg *EngineGroup) SetSQLRedactor(redactor SQLRedactor) {
+	eg.Engine.SetSQLRedactor(redactor)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetSQLRedactor(redactor)
+	}
+}
+
 // SetTableMapper set the table name mapping rule
 func (eg *EngineGroup) SetTableMapper(mapper core.IMapper) {
 	eg.Engine.TableMapper = mapper
@@ -217,3 +310,11 @@ func (eg *EngineGroup) Slave() *Engine {
 func (eg *EngineGroup) Slaves() []*Engine {
 	return eg.slaves
 }
+
+// UnregisterTable removes the table registered by RegisterTable from the engines
+func (eg *EngineGroup) UnregisterTable(name string) {
+	eg.Engine.UnregisterTable(name)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].UnregisterTable(name)
+	}
+}
@@ -98,12 +98,17 @@ func str2PK(s string, tp reflect.Type) (interface{}, error) {
 func splitTag(tag string) (tags []string) {
 	tag = strings.TrimSpace(tag)
 	var hasQuote = false
+	var parens = 0
 	var lastIdx = 0
 	for i, t := range tag {
 		if t == '\'' {
 			hasQuote = !hasQuote
+		} else if t == '(' && !hasQuote {
+			parens++
+		} else if t == ')' && !hasQuote && parens > 0 {
+			parens--
 		} else if t == ' ' {
-			if lastIdx < i && !hasQuote {
+			if lastIdx < i && !hasQuote && parens == 0 {
 				tags = append(tags, strings.TrimSpace(tag[lastIdx:i]))
 				lastIdx = i + 1
 			}
@@ -306,8 +311,8 @@ func sliceEq(left, right []string) bool {
 	return true
 }
 
-func indexName(tableName, idxName string) string {
-	return fmt.Sprintf("IDX_%v_%v", tableName, idxName)
+func indexName(tableName, idxName string, max int) string {
+	return truncateIdentifier(fmt.Sprintf("IDX_%v_%v", tableName, idxName), max)
 }
 
 func eraseAny(value string, strToErase ...string) string {
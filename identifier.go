@@ -0,0 +1,137 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"hash/fnv"
+	"unicode/utf8"
+
+	"xorm.io/core"
+)
+
+// MaxIdentifierLength returns the max length in bytes of the identifiers of
+// the database, 0 means no limit.
+func (engine *Engine) MaxIdentifierLength() int {
+	return engine.maxIdentifierLengthOf(engine.dialect.DBType())
+}
+
+// maxIdentifierLengthOf returns the max length of the identifiers of the
+// database type, the length set by SetMaxIdentifierLength is only for the
+// database type of the engine.
+func (engine *Engine) maxIdentifierLengthOf(dbType core.DbType) int {
+	if engine.maxIdentifierLength > 0 && dbType == engine.dialect.DBType() {
+		return engine.maxIdentifierLength
+	}
+
+	switch dbType {
+	case core.POSTGRES:
+		return 63
+	case core.MYSQL:
+		return 64
+	case core.MSSQL:
+		return 128
+	case core.ORACLE:
+		// oracle 12.2 and later supports 128 bytes, see SetMaxIdentifierLength
+		return 30
+	}
+	return 0
+}
+
+// SetMaxIdentifierLength sets the max length in bytes of the identifiers,
+// the generated names longer than it will be truncated. If it's less than
+// 10, only the hash of the names is kept.
+func (engine *Engine) SetMaxIdentifierLength(length int) {
+	engine.maxIdentifierLength = length
+}
+
+// truncateIdentifier truncates the name to the max length, the hash of the
+// whole name is appended so the truncated names are deterministic and don't
+// collide with each other.
+func truncateIdentifier(name string, max int) string {
+	if max <= 0 || len(name) <= max {
+		return name
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	hash := fmt.Sprintf("%08x", h.Sum32())
+	// the limit is too small to keep a prefix, only the hash is kept
+	if max <= len(hash)+1 {
+		if max > len(hash) {
+			max = len(hash)
+		}
+		return hash[len(hash)-max:]
+	}
+
+	suffix := "_" + hash
+
+	prefix := name[:max-len(suffix)]
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix + suffix
+}
+
+// truncateName truncates the generated name to the max length of the database
+func (engine *Engine) truncateName(name string) string {
+	return truncateIdentifier(name, engine.MaxIdentifierLength())
+}
+
+// sequenceName returns the name of the sequence of the table's autoincrement
+// column on the databases of IdentitySequence. The serial sequences of
+// postgres are named by the database and found by pg_get_serial_sequence.
+func (engine *Engine) sequenceName(tableName string) string {
+	return engine.truncateName("seq_" + tableName)
+}
+
+// limitIndex returns the index whose name is in the max length. If the name
+// is too long, a copy of the index with the truncated name is returned, the
+// dialects use the name as it is since it has the prefix IDX_ or UQE_.
+func limitIndex(tableName string, index *core.Index, max int) *core.Index {
+	name := index.XName(tableName)
+	truncated := truncateIdentifier(name, max)
+	if truncated == name {
+		return index
+	}
+
+	var newIndex = *index
+	newIndex.Name = truncated
+	newIndex.IsRegular = false
+	return &newIndex
+}
+
+// matchIndexNames renames the keys of the indexes loaded from the database
+// to the names of the bean's indexes if their names are the truncated ones,
+// or the ones truncated by the database silently.
+func (engine *Engine) matchIndexNames(tableName string, table, oriTable *core.Table) {
+	max := engine.MaxIdentifierLength()
+	if max <= 0 {
+		return
+	}
+
+	for name, index := range table.Indexes {
+		xname := index.XName(tableName)
+		if len(xname) <= max {
+			continue
+		}
+		truncated := engine.truncateName(xname)
+
+		for name2, index2 := range oriTable.Indexes {
+			dbName := index2.Name
+			if index2.IsRegular {
+				dbName = index2.XName(tableName)
+			}
+			if dbName == truncated || dbName == xname[:max] {
+				if _, ok := oriTable.Indexes[name]; ok && name != name2 {
+					break
+				}
+				delete(oriTable.Indexes, name2)
+				oriTable.Indexes[name] = index2
+				break
+			}
+		}
+	}
+}
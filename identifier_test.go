@@ -0,0 +1,106 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func TestTruncateIdentifier(t *testing.T) {
+	assert.EqualValues(t, "IDX_user_name", truncateIdentifier("IDX_user_name", 63))
+	assert.EqualValues(t, "IDX_user_name", truncateIdentifier("IDX_user_name", 0))
+
+	long := "IDX_" + strings.Repeat("a", 70) + "_name"
+	truncated := truncateIdentifier(long, 63)
+	assert.EqualValues(t, 63, len(truncated))
+	assert.True(t, strings.HasPrefix(truncated, "IDX_aaaa"))
+	assert.EqualValues(t, truncated, truncateIdentifier(long, 63))
+
+	// the names with the same prefix don't collide
+	assert.NotEqual(t, truncated, truncateIdentifier("IDX_"+strings.Repeat("a", 70)+"_email", 63))
+
+	// the multi-byte characters are not cut
+	truncated = truncateIdentifier("IDX_"+strings.Repeat("名", 20), 30)
+	assert.True(t, len(truncated) <= 30)
+	assert.True(t, strings.HasPrefix(truncated, "IDX_名"))
+
+	// the small limits keep the hash only
+	long = "IDX_user_name_and_email"
+	for _, kase := range []struct {
+		max    int
+		length int
+	}{
+		{1, 1},
+		{5, 5},
+		{9, 8},
+		{10, 10},
+		{12, 12},
+		{len(long), len(long)},
+	} {
+		truncated = truncateIdentifier(long, kase.max)
+		assert.EqualValues(t, kase.length, len(truncated), kase.max)
+		assert.EqualValues(t, truncated, truncateIdentifier(long, kase.max))
+	}
+	assert.EqualValues(t, "IDX_user_name_and_email", truncateIdentifier(long, 100))
+	assert.False(t, strings.HasPrefix(truncateIdentifier(long, 9), "_"))
+	assert.True(t, strings.HasPrefix(truncateIdentifier(long, 10), "I_"))
+
+	var index = core.NewIndex("name", core.IndexType)
+	index.AddColumn("name")
+	limited := limitIndex(strings.Repeat("t", 40), index, 30)
+	assert.EqualValues(t, 30, len(limited.Name))
+	assert.EqualValues(t, limited.Name, limited.XName(strings.Repeat("t", 40)))
+	assert.EqualValues(t, "name", index.Name)
+	assert.True(t, index == limitIndex("user", index, 30))
+
+	// the unique constraint and sequence names are truncated the same way
+	assert.EqualValues(t, "UQE_user_name", uniqueName("user", "name", 30))
+	assert.EqualValues(t, limitIndex(strings.Repeat("t", 40), core.NewIndex("name", core.UniqueType), 30).Name,
+		uniqueName(strings.Repeat("t", 40), "name", 30))
+	dialect := &oracle{}
+	assert.NoError(t, dialect.Init(nil, &core.Uri{DbType: core.ORACLE}, "", ""))
+	engine := &Engine{dialect: dialect}
+	assert.EqualValues(t, "seq_user", engine.sequenceName("user"))
+	assert.EqualValues(t, 30, len(engine.sequenceName(strings.Repeat("t", 40))))
+}
+
+type IdentifierLengthWithVeryLongTableName struct {
+	Id       int64
+	UserName string `xorm:"index(user_name_and_email_index) unique(the_unique_name)"`
+	Email    string `xorm:"index(user_name_and_email_index)"`
+}
+
+func TestSync2_IdentifierLength(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+	engine.SetMaxIdentifierLength(30)
+	defer engine.SetMaxIdentifierLength(0)
+
+	assert.NoError(t, testEngine.DropTables(new(IdentifierLengthWithVeryLongTableName)))
+	assert.NoError(t, testEngine.Sync2(new(IdentifierLengthWithVeryLongTableName)))
+
+	tableName := testEngine.TableName(new(IdentifierLengthWithVeryLongTableName))
+	indexes, err := testEngine.Dialect().GetIndexes(tableName)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(indexes))
+	for _, index := range indexes {
+		assert.True(t, len(index.XName(tableName)) <= 30)
+	}
+
+	// the truncated names are recognized and the indexes are kept
+	assert.NoError(t, testEngine.Sync2(new(IdentifierLengthWithVeryLongTableName)))
+	indexes2, err := testEngine.Dialect().GetIndexes(tableName)
+	assert.NoError(t, err)
+	assert.EqualValues(t, indexes, indexes2)
+
+	assert.NoError(t, testEngine.DropIndexes(new(IdentifierLengthWithVeryLongTableName)))
+	indexes, err = testEngine.Dialect().GetIndexes(tableName)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(indexes))
+}
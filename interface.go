@@ -7,9 +7,12 @@ package xorm
 import (
 	"context"
 	"database/sql"
+	"io"
 	"reflect"
+	"regexp"
 	"time"
 
+	"xorm.io/builder"
 	"xorm.io/core"
 )
 
@@ -17,8 +20,10 @@ import (
 type Interface interface {
 	AllCols() *Session
 	Alias(alias string) *Session
+	Ancestors(node interface{}, beans interface{}) error
 	Asc(colNames ...string) *Session
 	BufferSize(size int) *Session
+	Class(class string) *Session
 	Cols(columns ...string) *Session
 	Count(...interface{}) (int64, error)
 	CreateIndexes(bean interface{}) error
@@ -26,28 +31,42 @@ type Interface interface {
 	Decr(column string, arg ...interface{}) *Session
 	Desc(...string) *Session
 	Delete(interface{}) (int64, error)
+	DeleteGraph(interface{}) (int64, error)
+	DeleteRow(tableName string) (int64, error)
+	Descendants(node interface{}, depth int, beans interface{}) error
 	Distinct(columns ...string) *Session
 	DropIndexes(bean interface{}) error
+	DropInvalidIndexes(beanOrTableName interface{}) ([]string, error)
 	Exec(sqlOrArgs ...interface{}) (sql.Result, error)
 	Exist(bean ...interface{}) (bool, error)
 	Find(interface{}, ...interface{}) error
 	FindAndCount(interface{}, ...interface{}) (int64, error)
+	FindRows(tableName string) ([]map[string]interface{}, error)
 	Get(interface{}) (bool, error)
+	GetRow(tableName string, row map[string]interface{}) (bool, error)
 	GroupBy(keys string) *Session
 	ID(interface{}) *Session
+	Idempotent() *Session
 	In(string, ...interface{}) *Session
 	Incr(column string, arg ...interface{}) *Session
 	Insert(...interface{}) (int64, error)
+	InsertGraph(interface{}) (int64, error)
 	InsertOne(interface{}) (int64, error)
+	InsertRow(tableName string, row map[string]interface{}) (int64, error)
+	InvalidIndexes(beanOrTableName interface{}) ([]string, error)
 	IsTableEmpty(bean interface{}) (bool, error)
 	IsTableExist(beanOrTableName interface{}) (bool, error)
 	Iterate(interface{}, IterFunc) error
+	IterateParallel(bean interface{}, workers int, fun IterFunc, emit ...IterFunc) error
 	Limit(int, ...int) *Session
+	Load(interface{}, ...string) error
+	Move(node, parent interface{}) error
 	MustCols(columns ...string) *Session
 	NoAutoCondition(...bool) *Session
 	NotIn(string, ...interface{}) *Session
 	Join(joinOperator string, tablename interface{}, condition string, args ...interface{}) *Session
 	Omit(columns ...string) *Session
+	OpenBlob(bean interface{}, colName string) (io.ReadCloser, error)
 	OrderBy(order string) *Session
 	Ping() error
 	Query(sqlOrArgs ...interface{}) (resultsSlice []map[string][]byte, err error)
@@ -56,13 +75,16 @@ type Interface interface {
 	Rows(bean interface{}) (*Rows, error)
 	SetExpr(string, interface{}) *Session
 	SQL(interface{}, ...interface{}) *Session
+	SQLTemplate(name string, params interface{}) *Session
 	Sum(bean interface{}, colName string) (float64, error)
 	SumInt(bean interface{}, colName string) (int64, error)
 	Sums(bean interface{}, colNames ...string) ([]float64, error)
+	SyncRows(rowsSlicePtr interface{}, scope builder.Cond, keys ...string) (*SyncRowsResult, error)
 	SumsInt(bean interface{}, colNames ...string) ([]int64, error)
 	Table(tableNameOrBean interface{}) *Session
 	Unscoped() *Session
 	Update(bean interface{}, condiBeans ...interface{}) (int64, error)
+	UpdateRow(tableName string, row map[string]interface{}) (int64, error)
 	UseBool(...string) *Session
 	Where(interface{}, ...interface{}) *Session
 }
@@ -74,11 +96,13 @@ type EngineInterface interface {
 	Before(func(interface{})) *Session
 	Charset(charset string) *Session
 	ClearCache(...interface{}) error
+	ConcurrencyStats(class string) ConcurrencyStats
 	Context(context.Context) *Session
 	CreateTables(...interface{}) error
 	DBMetas() ([]*core.Table, error)
 	Dialect() core.Dialect
 	DropTables(...interface{}) error
+	DumpAllAnonymized(w io.Writer, anonymizer *Anonymizer, tp ...core.DbType) error
 	DumpAllToFile(fp string, tp ...core.DbType) error
 	GetCacher(string) core.Cacher
 	GetColumnMapper() core.IMapper
@@ -86,11 +110,20 @@ type EngineInterface interface {
 	GetTableMapper() core.IMapper
 	GetTZDatabase() *time.Location
 	GetTZLocation() *time.Location
+	LoadSQLMap(dir string) error
 	MapCacher(interface{}, core.Cacher) error
 	NewSession() *Session
 	NoAutoTime() *Session
+	OnlineDDL(online ...bool) *Session
 	Quote(string) string
+	RegisterPolicy(bean interface{}, policy PolicyFunc) error
+	RegisterSpatialTypes()
+	RegisterTable(*core.Table) error
+	RegisteredTable(string) *core.Table
+	RetryStats() RetryStats
+	SetBlobChunkSize(int)
 	SetCacher(string, core.Cacher)
+	SetConcurrencyLimit(class string, limit ConcurrencyLimit)
 	SetConnMaxLifetime(time.Duration)
 	SetDefaultCacher(core.Cacher)
 	SetLogger(logger core.ILogger)
@@ -98,7 +131,12 @@ type EngineInterface interface {
 	SetMapper(core.IMapper)
 	SetMaxOpenConns(int)
 	SetMaxIdleConns(int)
+	SetPolicyAudit(PolicyAuditFunc)
+	SetRedactPatterns(...*regexp.Regexp)
+	SetRetryPolicy(*RetryPolicy)
 	SetSchema(string)
+	SetSQLMap(*SQLMap)
+	SetSQLRedactor(SQLRedactor)
 	SetTZDatabase(tz *time.Location)
 	SetTZLocation(tz *time.Location)
 	ShowExecTime(...bool)
@@ -109,6 +147,7 @@ type EngineInterface interface {
 	TableInfo(bean interface{}) *Table
 	TableName(interface{}, ...bool) string
 	UnMapType(reflect.Type)
+	UnregisterTable(string)
 }
 
 var (
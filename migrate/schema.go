@@ -0,0 +1,489 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/marlonfan/xorm"
+	"xorm.io/core"
+)
+
+var (
+	// ErrModifyColumnUnsupported is returned when the dialect could not
+	// change the column types.
+	ErrModifyColumnUnsupported = errors.New("Modifying columns is not supported by the dialect")
+)
+
+// ErrIndexNotFound is returned when the dropped index does not exist.
+type ErrIndexNotFound struct {
+	TableName string
+	IndexName string
+}
+
+func (e ErrIndexNotFound) Error() string {
+	return fmt.Sprintf("index %s is not found on table %s", e.IndexName, e.TableName)
+}
+
+// Schema changes the tables by the table builders instead of the model
+// structs, so the migrations are not broken when the structs are changed.
+//
+//	schema := migrate.NewSchema(engine)
+//	err := schema.CreateTable("users", func(t *migrate.TableBuilder) {
+//		t.BigInt("id").PK().AutoIncr()
+//		t.Varchar("email", 255).NotNull().Unique()
+//	})
+//
+// The indexes are named the same as the index tags of the structs, so Sync2
+// could be used with the tables.
+type Schema struct {
+	engine *xorm.Engine
+}
+
+// NewSchema returns a schema of the engine
+func NewSchema(engine *xorm.Engine) *Schema {
+	return &Schema{engine: engine}
+}
+
+// TableBuilder describes the columns and indexes of a table
+type TableBuilder struct {
+	name        string
+	columns     []*ColumnBuilder
+	indexes     []*core.Index
+	dropColumns []string
+	dropIndexes []string
+	storeEngine string
+	charset     string
+}
+
+// ColumnBuilder describes a column
+type ColumnBuilder struct {
+	table  *TableBuilder
+	column *core.Column
+	modify bool
+}
+
+func newTableBuilder(name string, build func(*TableBuilder)) *TableBuilder {
+	t := &TableBuilder{name: name}
+	build(t)
+	return t
+}
+
+// Column adds a column of the sql type, the sql types are defined in
+// xorm.io/core
+func (t *TableBuilder) Column(name string, sqlType string, length ...int) *ColumnBuilder {
+	var len1, len2 int
+	if len(length) > 0 {
+		len1 = length[0]
+	}
+	if len(length) > 1 {
+		len2 = length[1]
+	}
+
+	col := core.NewColumn(name, "", core.SQLType{Name: sqlType}, len1, len2, true)
+	c := &ColumnBuilder{table: t, column: col}
+	t.columns = append(t.columns, c)
+	return c
+}
+
+// BigInt adds a BIGINT column
+func (t *TableBuilder) BigInt(name string) *ColumnBuilder {
+	return t.Column(name, core.BigInt)
+}
+
+// Int adds an INT column
+func (t *TableBuilder) Int(name string) *ColumnBuilder {
+	return t.Column(name, core.Int)
+}
+
+// SmallInt adds a SMALLINT column
+func (t *TableBuilder) SmallInt(name string) *ColumnBuilder {
+	return t.Column(name, core.SmallInt)
+}
+
+// TinyInt adds a TINYINT column
+func (t *TableBuilder) TinyInt(name string) *ColumnBuilder {
+	return t.Column(name, core.TinyInt)
+}
+
+// Bool adds a BOOL column
+func (t *TableBuilder) Bool(name string) *ColumnBuilder {
+	return t.Column(name, core.Bool)
+}
+
+// Varchar adds a VARCHAR column
+func (t *TableBuilder) Varchar(name string, length int) *ColumnBuilder {
+	return t.Column(name, core.Varchar, length)
+}
+
+// Char adds a CHAR column
+func (t *TableBuilder) Char(name string, length int) *ColumnBuilder {
+	return t.Column(name, core.Char, length)
+}
+
+// Text adds a TEXT column
+func (t *TableBuilder) Text(name string) *ColumnBuilder {
+	return t.Column(name, core.Text)
+}
+
+// Blob adds a BLOB column
+func (t *TableBuilder) Blob(name string) *ColumnBuilder {
+	return t.Column(name, core.Blob)
+}
+
+// Float adds a FLOAT column
+func (t *TableBuilder) Float(name string) *ColumnBuilder {
+	return t.Column(name, core.Float)
+}
+
+// Double adds a DOUBLE column
+func (t *TableBuilder) Double(name string) *ColumnBuilder {
+	return t.Column(name, core.Double)
+}
+
+// Decimal adds a DECIMAL column
+func (t *TableBuilder) Decimal(name string, precision, scale int) *ColumnBuilder {
+	return t.Column(name, core.Decimal, precision, scale)
+}
+
+// DateTime adds a DATETIME column
+func (t *TableBuilder) DateTime(name string) *ColumnBuilder {
+	return t.Column(name, core.DateTime)
+}
+
+// Date adds a DATE column
+func (t *TableBuilder) Date(name string) *ColumnBuilder {
+	return t.Column(name, core.Date)
+}
+
+// Index adds an index of the columns
+func (t *TableBuilder) Index(name string, cols ...string) *TableBuilder {
+	return t.addIndex(name, core.IndexType, cols)
+}
+
+// Unique adds an unique index of the columns
+func (t *TableBuilder) Unique(name string, cols ...string) *TableBuilder {
+	return t.addIndex(name, core.UniqueType, cols)
+}
+
+func (t *TableBuilder) addIndex(name string, indexType int, cols []string) *TableBuilder {
+	index := core.NewIndex(name, indexType)
+	index.AddColumn(cols...)
+	t.indexes = append(t.indexes, index)
+	return t
+}
+
+// DropColumn drops the columns, it's only for AlterTable
+func (t *TableBuilder) DropColumn(names ...string) *TableBuilder {
+	t.dropColumns = append(t.dropColumns, names...)
+	return t
+}
+
+// DropIndex drops the indexes by their names, it's only for AlterTable
+func (t *TableBuilder) DropIndex(names ...string) *TableBuilder {
+	t.dropIndexes = append(t.dropIndexes, names...)
+	return t
+}
+
+// StoreEngine sets the store engine of the table, it's only for MySQL
+func (t *TableBuilder) StoreEngine(storeEngine string) *TableBuilder {
+	t.storeEngine = storeEngine
+	return t
+}
+
+// Charset sets the charset of the table
+func (t *TableBuilder) Charset(charset string) *TableBuilder {
+	t.charset = charset
+	return t
+}
+
+// Table returns the table described by the builder
+func (t *TableBuilder) Table() *core.Table {
+	table := core.NewEmptyTable()
+	table.Name = t.name
+	table.StoreEngine = t.storeEngine
+	table.Charset = t.charset
+	for _, c := range t.columns {
+		c.column.TableName = t.name
+		table.AddColumn(c.column)
+	}
+	for _, index := range t.indexes {
+		table.AddIndex(index)
+		for _, name := range index.Cols {
+			if col := table.GetColumn(name); col != nil {
+				col.Indexes[index.Name] = index.Type
+			}
+		}
+	}
+	return table
+}
+
+// PK sets the column as a primary key
+func (c *ColumnBuilder) PK() *ColumnBuilder {
+	c.column.IsPrimaryKey = true
+	c.column.Nullable = false
+	return c
+}
+
+// AutoIncr sets the column as an autoincrement column
+func (c *ColumnBuilder) AutoIncr() *ColumnBuilder {
+	c.column.IsAutoIncrement = true
+	c.column.Nullable = false
+	return c
+}
+
+// NotNull sets the column as not null
+func (c *ColumnBuilder) NotNull() *ColumnBuilder {
+	c.column.Nullable = false
+	return c
+}
+
+// Null sets the column as nullable
+func (c *ColumnBuilder) Null() *ColumnBuilder {
+	c.column.Nullable = true
+	return c
+}
+
+// Default sets the default value of the column, it's a sql expression so
+// the strings should be quoted, i.e. Default("'active'")
+func (c *ColumnBuilder) Default(value string) *ColumnBuilder {
+	c.column.Default = value
+	return c
+}
+
+// Index adds an index of the column named by the column
+func (c *ColumnBuilder) Index() *ColumnBuilder {
+	c.table.Index(c.column.Name, c.column.Name)
+	return c
+}
+
+// Unique adds an unique index of the column named by the column
+func (c *ColumnBuilder) Unique() *ColumnBuilder {
+	c.table.Unique(c.column.Name, c.column.Name)
+	return c
+}
+
+// Change changes the existing column to the definition instead of adding
+// it, it's only for AlterTable and the dialect should support ModifyColumn
+func (c *ColumnBuilder) Change() *ColumnBuilder {
+	c.modify = true
+	return c
+}
+
+// CreateTableSQL returns the sqls to create the table and its indexes
+func (s *Schema) CreateTableSQL(name string, build func(*TableBuilder)) []string {
+	table := newTableBuilder(name, build).Table()
+	dialect := s.engine.Dialect()
+
+	var sqls = []string{dialect.CreateTableSql(table, name, table.StoreEngine, table.Charset)}
+	for _, index := range table.Indexes {
+		sqls = append(sqls, dialect.CreateIndexSql(name, index))
+	}
+	return sqls
+}
+
+// CreateTable creates the table and its indexes
+func (s *Schema) CreateTable(name string, build func(*TableBuilder)) error {
+	return s.exec(s.CreateTableSQL(name, build)...)
+}
+
+// DropTable drops the table if it exists
+func (s *Schema) DropTable(name string) error {
+	return s.engine.DropTables(name)
+}
+
+// RenameTable renames the table
+func (s *Schema) RenameTable(oldName, newName string) error {
+	sqlStr, args := s.engine.RenameTableSQL(oldName, newName)
+	_, err := s.engine.Exec(append([]interface{}{sqlStr}, args...)...)
+	return err
+}
+
+// AlterTable changes the table, the indexes and columns are dropped first,
+// then the columns are added or changed and the indexes are created. All the
+// changes are executed in one transaction.
+func (s *Schema) AlterTable(name string, build func(*TableBuilder)) error {
+	t := newTableBuilder(name, build)
+	table := t.Table()
+	dialect := s.engine.Dialect()
+
+	var sqls []string
+	if len(t.dropIndexes) > 0 || len(t.dropColumns) > 0 {
+		indexes, err := dialect.GetIndexes(name)
+		if err != nil {
+			return err
+		}
+		dropSQLs, err := s.dropIndexSQL(name, indexes, t.dropIndexes)
+		if err != nil {
+			return err
+		}
+		sqls = append(sqls, dropSQLs...)
+		if len(t.dropColumns) > 0 {
+			dropSQLs, err = s.dropColumnSQL(name, indexes, t.dropColumns)
+			if err != nil {
+				return err
+			}
+			sqls = append(sqls, dropSQLs...)
+		}
+	}
+
+	for _, c := range t.columns {
+		if c.modify {
+			if !s.engine.Capabilities().ModifyColumn {
+				return ErrModifyColumnUnsupported
+			}
+			sqls = append(sqls, dialect.ModifyColumnSql(name, c.column))
+			continue
+		}
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD %s", s.engine.Quote(name), c.column.String(dialect)))
+	}
+	for _, index := range table.Indexes {
+		sqls = append(sqls, dialect.CreateIndexSql(name, index))
+	}
+	return s.exec(sqls...)
+}
+
+// AddIndex creates an index of the columns
+func (s *Schema) AddIndex(tableName, name string, cols ...string) error {
+	return s.AlterTable(tableName, func(t *TableBuilder) {
+		t.Index(name, cols...)
+	})
+}
+
+// AddUnique creates an unique index of the columns
+func (s *Schema) AddUnique(tableName, name string, cols ...string) error {
+	return s.AlterTable(tableName, func(t *TableBuilder) {
+		t.Unique(name, cols...)
+	})
+}
+
+// DropIndex drops the indexes, the names are the ones of the index tags or
+// the full names in the database
+func (s *Schema) DropIndex(tableName string, names ...string) error {
+	indexes, err := s.engine.Dialect().GetIndexes(tableName)
+	if err != nil {
+		return err
+	}
+	sqls, err := s.dropIndexSQL(tableName, indexes, names)
+	if err != nil {
+		return err
+	}
+	return s.exec(sqls...)
+}
+
+// dropIndexSQL returns the sqls to drop the indexes, the dropped ones are
+// removed from indexes
+func (s *Schema) dropIndexSQL(tableName string, indexes map[string]*core.Index, names []string) ([]string, error) {
+	dialect := s.engine.Dialect()
+	var sqls = make([]string, 0, len(names))
+	for _, name := range names {
+		var found bool
+		for key, index := range indexes {
+			if index.Name == name || index.XName(tableName) == name {
+				sqls = append(sqls, dialect.DropIndexSql(tableName, index))
+				delete(indexes, key)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrIndexNotFound{tableName, name}
+		}
+	}
+	return sqls, nil
+}
+
+// DropColumn drops the columns and the indexes on them. SQLite tables are
+// always rebuilt without the columns, since DROP COLUMN is only supported
+// since SQLite 3.35 and not on the columns with constraints.
+func (s *Schema) DropColumn(tableName string, names ...string) error {
+	indexes, err := s.engine.Dialect().GetIndexes(tableName)
+	if err != nil {
+		return err
+	}
+	sqls, err := s.dropColumnSQL(tableName, indexes, names)
+	if err != nil {
+		return err
+	}
+	return s.exec(sqls...)
+}
+
+// dropColumnSQL returns the sqls to drop the columns and the indexes on them
+func (s *Schema) dropColumnSQL(tableName string, indexes map[string]*core.Index, names []string) ([]string, error) {
+	dialect := s.engine.Dialect()
+	var dropped = make(map[string]bool, len(names))
+	for _, name := range names {
+		dropped[strings.ToLower(name)] = true
+	}
+
+	var sqls []string
+	var kept []*core.Index
+	for _, index := range indexes {
+		var onDropped bool
+		for _, col := range index.Cols {
+			onDropped = onDropped || dropped[strings.ToLower(col)]
+		}
+		if onDropped {
+			sqls = append(sqls, dialect.DropIndexSql(tableName, index))
+		} else {
+			kept = append(kept, index)
+		}
+	}
+
+	if dialect.DBType() == core.SQLITE {
+		return s.rebuildSQLiteTable(tableName, dropped, sqls, kept)
+	}
+
+	for _, name := range names {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", s.engine.Quote(tableName), s.engine.Quote(name)))
+	}
+	return sqls, nil
+}
+
+// rebuildSQLiteTable returns the sqls to copy the table without the dropped
+// columns
+func (s *Schema) rebuildSQLiteTable(tableName string, dropped map[string]bool, sqls []string, indexes []*core.Index) ([]string, error) {
+	dialect := s.engine.Dialect()
+	colSeq, cols, err := dialect.GetColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	sqls = append(sqls, s.engine.RebuildTableSQL(tableName, colSeq, cols, nil, dropped)...)
+	for _, index := range indexes {
+		sqls = append(sqls, dialect.CreateIndexSql(tableName, index))
+	}
+	return sqls, nil
+}
+
+// exec executes the sqls in a transaction, they are executed one by one on
+// the databases without transactional DDL since the changes could not be
+// rolled back.
+func (s *Schema) exec(sqls ...string) error {
+	if len(sqls) == 0 {
+		return nil
+	}
+
+	if !s.engine.Capabilities().TransactionalDDL {
+		for _, sqlStr := range sqls {
+			if _, err := s.engine.Exec(sqlStr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	session := s.engine.NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	for _, sqlStr := range sqls {
+		if _, err := session.Exec(sqlStr); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+	return session.Commit()
+}
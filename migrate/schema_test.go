@@ -0,0 +1,131 @@
+package migrate
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/marlonfan/xorm"
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+type SchemaUser struct {
+	Id     int64
+	Email  string `xorm:"varchar(255) notnull unique"`
+	Name   string `xorm:"varchar(50) index(name_age)"`
+	Age    int    `xorm:"index(name_age)"`
+	Status string `xorm:"varchar(20) default 'active'"`
+}
+
+func indexNames(t *testing.T, db *xorm.Engine, tableName string) []string {
+	indexes, err := db.Dialect().GetIndexes(tableName)
+	assert.NoError(t, err)
+	var names []string
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSchema(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	schema := NewSchema(db)
+	assert.NoError(t, schema.CreateTable("schema_user", func(t *TableBuilder) {
+		t.BigInt("id").PK().AutoIncr()
+		t.Varchar("email", 255).NotNull().Unique()
+		t.Varchar("name", 50)
+		t.Int("age")
+		t.Varchar("nickname", 20)
+		t.Index("name_age", "name", "age")
+	}))
+
+	colSeq, cols, err := db.Dialect().GetColumns("schema_user")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"id", "email", "name", "age", "nickname"}, colSeq)
+	assert.True(t, cols["id"].IsPrimaryKey)
+	assert.True(t, cols["id"].IsAutoIncrement)
+	assert.False(t, cols["email"].Nullable)
+	assert.EqualValues(t, []string{"email", "name_age"}, indexNames(t, db, "schema_user"))
+
+	_, err = db.Exec("INSERT INTO schema_user (email, name, age, nickname) VALUES (?, ?, ?, ?)", "a@example.com", "a", 10, "aa")
+	assert.NoError(t, err)
+
+	assert.NoError(t, schema.AlterTable("schema_user", func(t *TableBuilder) {
+		t.Varchar("status", 20).Default("'active'")
+		t.DropColumn("nickname")
+	}))
+	colSeq, _, err = db.Dialect().GetColumns("schema_user")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"id", "email", "name", "age", "status"}, colSeq)
+	assert.EqualValues(t, []string{"email", "name_age"}, indexNames(t, db, "schema_user"))
+
+	var user SchemaUser
+	has, err := db.Table("schema_user").Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "a@example.com", user.Email)
+	assert.EqualValues(t, "active", user.Status)
+
+	// the table is the same as the one synced from the struct
+	assert.NoError(t, db.Table("schema_user").Sync2(new(SchemaUser)))
+	assert.EqualValues(t, []string{"email", "name_age"}, indexNames(t, db, "schema_user"))
+
+	assert.NoError(t, schema.DropColumn("schema_user", "age"))
+	assert.EqualValues(t, []string{"email"}, indexNames(t, db, "schema_user"))
+
+	assert.NoError(t, schema.AddIndex("schema_user", "status", "status"))
+	assert.EqualValues(t, []string{"email", "status"}, indexNames(t, db, "schema_user"))
+	assert.NoError(t, schema.DropIndex("schema_user", "IDX_schema_user_status"))
+	assert.EqualValues(t, []string{"email"}, indexNames(t, db, "schema_user"))
+	assert.EqualValues(t, ErrIndexNotFound{"schema_user", "status"}, schema.DropIndex("schema_user", "status"))
+
+	assert.EqualValues(t, ErrModifyColumnUnsupported, schema.AlterTable("schema_user", func(t *TableBuilder) {
+		t.Text("name").Change()
+	}))
+
+	// the dropped column is kept if the rest of the changes fail
+	assert.Error(t, schema.AlterTable("schema_user", func(t *TableBuilder) {
+		t.DropColumn("status")
+		t.Varchar("email", 100)
+	}))
+	colSeq, _, err = db.Dialect().GetColumns("schema_user")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"id", "email", "name", "status"}, colSeq)
+
+	assert.NoError(t, schema.RenameTable("schema_user", "schema_member"))
+	exist, err := db.IsTableExist("schema_member")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	assert.NoError(t, schema.DropTable("schema_member"))
+	exist, err = db.IsTableExist("schema_member")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
+
+func TestSchemaCreateTableSQL(t *testing.T) {
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqls := NewSchema(db).CreateTableSQL("schema_pet", func(t *TableBuilder) {
+		t.BigInt("id").PK().AutoIncr()
+		t.Varchar("name", 50).NotNull().Index()
+		t.Decimal("weight", 5, 2).Default("0")
+	})
+	assert.EqualValues(t, []string{
+		"CREATE TABLE IF NOT EXISTS `schema_pet` (`id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, `name` TEXT NOT NULL, `weight` NUMERIC DEFAULT 0 NULL)",
+		"CREATE INDEX `IDX_schema_pet_name` ON `schema_pet` (`name`)",
+	}, sqls)
+
+	table := (&TableBuilder{name: "schema_pet"}).Varchar("name", 50).Unique().table.Table()
+	assert.EqualValues(t, core.UniqueType, table.Indexes["name"].Type)
+	assert.EqualValues(t, map[string]int{"name": core.UniqueType}, table.GetColumn("name").Indexes)
+}
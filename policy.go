@@ -0,0 +1,121 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// PolicyFunc returns the row level policy of a table for the context of the
+// session. The readCond restricts the rows which could be read, updated and
+// deleted, the writeCheck validates every bean before it's inserted, either
+// of them could be nil.
+type PolicyFunc func(ctx context.Context) (readCond builder.Cond, writeCheck func(bean interface{}) error)
+
+// PolicyAuditFunc is called every time a policy is bypassed by WithoutPolicy
+type PolicyAuditFunc func(ctx context.Context, tableName, reason string)
+
+type policyBypassKey struct{}
+
+// WithoutPolicy returns a context which bypasses the row level policies, it's
+// used by the background jobs which should see all the rows. The reason is
+// passed to the audit function of the engine every time a policy is bypassed.
+//
+//	engine.Context(xorm.WithoutPolicy(ctx, "nightly report")).Find(&tickets)
+func WithoutPolicy(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, policyBypassKey{}, reason)
+}
+
+// RegisterPolicy registers the row level policy of the bean's table, or the
+// name of a table registered by RegisterTable. The policy is applied to the
+// sessions with the context given by Context, a nil policy removes it.
+//
+//	engine.RegisterPolicy(new(Ticket), func(ctx context.Context) (builder.Cond, func(interface{}) error) {
+//		region := ctx.Value(regionKey).(string)
+//		return builder.Eq{"region": region}, func(bean interface{}) error {
+//			if bean.(*Ticket).Region != region {
+//				return errors.New("ticket is out of region")
+//			}
+//			return nil
+//		}
+//	})
+func (engine *Engine) RegisterPolicy(bean interface{}, policy PolicyFunc) error {
+	var key interface{}
+	if table := engine.registeredTableOf(bean); table != nil {
+		key = table.Name
+	} else {
+		v := rValue(bean)
+		if v.Kind() != reflect.Struct {
+			return errors.New("needs a struct or the name of a registered table")
+		}
+		key = v.Type()
+	}
+
+	if policy == nil {
+		engine.policies.Delete(key)
+	} else {
+		engine.policies.Store(key, policy)
+	}
+	return nil
+}
+
+// SetPolicyAudit sets the function called when a policy is bypassed, the
+// bypass is logged as a warning by default.
+func (engine *Engine) SetPolicyAudit(audit PolicyAuditFunc) {
+	engine.policyAudit = audit
+}
+
+// policy returns the row level policy of the table for the context, both
+// are nil if the table has no policy or it's bypassed.
+func (engine *Engine) policy(ctx context.Context, table *core.Table, tableName string) (builder.Cond, func(interface{}) error) {
+	if table == nil {
+		return nil, nil
+	}
+	var key interface{} = table.Name
+	if table.Type != nil {
+		key = table.Type
+	}
+	policy, ok := engine.policies.Load(key)
+	if !ok {
+		return nil, nil
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if reason, ok := ctx.Value(policyBypassKey{}).(string); ok {
+		if engine.policyAudit != nil {
+			engine.policyAudit(ctx, tableName, reason)
+		} else {
+			engine.logger.Warnf("[policy] bypass the policy of %s: %s", tableName, reason)
+		}
+		return nil, nil
+	}
+	return policy.(PolicyFunc)(ctx)
+}
+
+// mergePolicyCond adds the read condition of the row level policy to the
+// statement once
+func (statement *Statement) mergePolicyCond() {
+	if statement.policyMerged || statement.RefTable == nil {
+		return
+	}
+	statement.policyMerged = true
+	readCond, _ := statement.Engine.policy(statement.ctx, statement.RefTable, statement.TableName())
+	if readCond != nil {
+		statement.cond = statement.cond.And(readCond)
+	}
+}
+
+// writePolicy returns the write check of the row level policy, nil if there is none
+func (statement *Statement) writePolicy() func(interface{}) error {
+	_, writeCheck := statement.Engine.policy(statement.ctx, statement.RefTable, statement.TableName())
+	return writeCheck
+}
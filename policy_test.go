@@ -0,0 +1,122 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/builder"
+)
+
+type PolicyTicket struct {
+	Id     int64
+	Region string
+	Title  string
+}
+
+type policyRegionKey struct{}
+
+var errPolicyRegion = errors.New("ticket is out of the region")
+
+func policyTicketRegion(ctx context.Context) (builder.Cond, func(interface{}) error) {
+	region, ok := ctx.Value(policyRegionKey{}).(string)
+	if !ok {
+		return builder.Expr("1=0"), func(interface{}) error { return errPolicyRegion }
+	}
+	return builder.Eq{"region": region}, func(bean interface{}) error {
+		if bean.(*PolicyTicket).Region != region {
+			return errPolicyRegion
+		}
+		return nil
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(PolicyTicket))
+
+	assert.NoError(t, testEngine.RegisterPolicy(new(PolicyTicket), policyTicketRegion))
+	defer testEngine.RegisterPolicy(new(PolicyTicket), nil)
+
+	var audits []string
+	testEngine.SetPolicyAudit(func(ctx context.Context, tableName, reason string) {
+		audits = append(audits, tableName+":"+reason)
+	})
+	defer testEngine.SetPolicyAudit(nil)
+
+	var jobCtx = WithoutPolicy(context.Background(), "seed")
+	_, err := testEngine.Context(jobCtx).Insert([]PolicyTicket{
+		{Region: "eu", Title: "a"},
+		{Region: "eu", Title: "b"},
+		{Region: "us", Title: "c"},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"policy_ticket:seed"}, audits)
+
+	var euCtx = context.WithValue(context.Background(), policyRegionKey{}, "eu")
+
+	// the rows out of the region could not be inserted
+	_, err = testEngine.Context(euCtx).Insert(&PolicyTicket{Region: "us", Title: "d"})
+	assert.EqualValues(t, errPolicyRegion, err)
+	_, err = testEngine.Context(euCtx).Insert([]*PolicyTicket{{Region: "eu", Title: "d"}, {Region: "us", Title: "e"}})
+	assert.EqualValues(t, errPolicyRegion, err)
+	_, err = testEngine.Context(euCtx).Insert(&PolicyTicket{Region: "eu", Title: "d"})
+	assert.NoError(t, err)
+
+	var tickets []PolicyTicket
+	assert.NoError(t, testEngine.Context(euCtx).Asc("id").Find(&tickets))
+	assert.EqualValues(t, 3, len(tickets))
+	for _, ticket := range tickets {
+		assert.EqualValues(t, "eu", ticket.Region)
+	}
+
+	tickets = nil
+	cnt, err := testEngine.Context(euCtx).Where("title <> ?", "b").FindAndCount(&tickets)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	assert.EqualValues(t, 2, len(tickets))
+
+	cnt, err = testEngine.Context(euCtx).NoAutoCondition().Count(new(PolicyTicket))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+
+	// the rows out of the region could not be read, updated or deleted
+	var ticket PolicyTicket
+	has, err := testEngine.Context(euCtx).ID(3).Get(&ticket)
+	assert.NoError(t, err)
+	assert.False(t, has)
+	has, err = testEngine.Context(euCtx).Table(new(PolicyTicket)).Where("id = ?", 3).Exist()
+	assert.NoError(t, err)
+	assert.False(t, has)
+	affected, err := testEngine.Context(euCtx).ID(3).Update(&PolicyTicket{Title: "x"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+	affected, err = testEngine.Context(euCtx).ID(3).Delete(new(PolicyTicket))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+	affected, err = testEngine.Context(euCtx).ID(1).Update(&PolicyTicket{Title: "x"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+
+	// nothing could be read without the region
+	cnt, err = testEngine.Count(new(PolicyTicket))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	// the bypass is audited
+	audits = nil
+	tickets = nil
+	assert.NoError(t, testEngine.Context(WithoutPolicy(context.Background(), "report")).Find(&tickets))
+	assert.EqualValues(t, 4, len(tickets))
+	assert.EqualValues(t, []string{"policy_ticket:report"}, audits)
+
+	has, err = testEngine.Context(jobCtx).ID(3).Get(&ticket)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "c", ticket.Title)
+}
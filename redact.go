@@ -0,0 +1,368 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"regexp"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// RedactedArg replaces the masked arguments in the SQL logs
+const RedactedArg = "***"
+
+// SQLRedactor returns the arguments of a SQL which will be logged. The secret
+// flags tell which arguments are bound to the columns tagged secret or
+// sensitive, or match the patterns given by SetRedactPatterns. The args
+// should not be modified since they are still used to execute the SQL.
+type SQLRedactor func(sqlStr string, args []interface{}, secret []bool) []interface{}
+
+// SetSQLRedactor sets the redactor of the SQL logs, the secret arguments are
+// replaced by RedactedArg by default, a nil redactor restores the default.
+func (engine *Engine) SetSQLRedactor(redactor SQLRedactor) {
+	engine.sqlRedactor = redactor
+}
+
+// SetRedactPatterns masks the string arguments matching any of the patterns
+// in the SQL logs, whichever column they are bound to. The columns tagged
+// secret are only known after their beans are mapped, so the patterns are
+// the only guard for the raw SQL executed before that.
+//
+//	engine.SetRedactPatterns(regexp.MustCompile(`^Bearer `), regexp.MustCompile(`^\d{16}$`))
+func (engine *Engine) SetRedactPatterns(patterns ...*regexp.Regexp) {
+	engine.redactPatterns = patterns
+}
+
+// redactArgs returns the arguments of the SQL which could be logged
+func (engine *Engine) redactArgs(sqlStr string, args []interface{}) []interface{} {
+	if len(args) == 0 {
+		return args
+	}
+
+	secret := engine.secretArgs(sqlStr, args)
+	if engine.sqlRedactor != nil {
+		return engine.sqlRedactor(sqlStr, args, secret)
+	}
+
+	var redacted []interface{}
+	for i, arg := range args {
+		if !secret[i] {
+			continue
+		}
+		if redacted == nil {
+			redacted = make([]interface{}, len(args))
+			copy(redacted, args)
+		}
+		if arg != nil {
+			redacted[i] = RedactedArg
+		}
+	}
+	if redacted == nil {
+		return args
+	}
+	return redacted
+}
+
+// secretArgs tells which arguments of the SQL are secret
+func (engine *Engine) secretArgs(sqlStr string, args []interface{}) []bool {
+	secret := make([]bool, len(args))
+	engine.secretLock.RLock()
+	if len(engine.secretColumns) > 0 {
+		columns, tables := placeholderColumns(sqlStr, len(args))
+		for i, col := range columns {
+			secret[i] = secretInTables(engine.secretColumns[strings.ToLower(col)], tables)
+		}
+	}
+	engine.secretLock.RUnlock()
+	for i, arg := range args {
+		if !secret[i] && engine.matchRedactPatterns(arg) {
+			secret[i] = true
+		}
+	}
+	return secret
+}
+
+// secretInTables returns true if the column is secret in any of the tables
+// of the SQL, or in any table if the tables of the SQL are unknown
+func secretInTables(secretTables map[string]bool, tables []string) bool {
+	if len(secretTables) == 0 {
+		return false
+	}
+	if len(tables) == 0 {
+		return true
+	}
+	for _, table := range tables {
+		if secretTables[table] {
+			return true
+		}
+	}
+	return false
+}
+
+// addSecretColumns records the names of the columns tagged secret or
+// sensitive of the table, so the logs don't need to look up the columns
+func (engine *Engine) addSecretColumns(table *core.Table) {
+	for _, col := range table.Columns() {
+		if extra := engine.columnExtra(col); extra == nil || !extra.secret {
+			continue
+		}
+		engine.secretLock.Lock()
+		if engine.secretColumns == nil {
+			engine.secretColumns = make(map[string]map[string]bool)
+		}
+		name := strings.ToLower(col.Name)
+		if engine.secretColumns[name] == nil {
+			engine.secretColumns[name] = make(map[string]bool)
+		}
+		engine.secretColumns[name][strings.ToLower(table.Name)] = true
+		engine.secretLock.Unlock()
+	}
+}
+
+// redactedError hides the secret arguments in the message of an error
+// returned by the driver, the original error is kept by Unwrap
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the error returned by the driver
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError masks the secret string arguments of the SQL which appear in
+// the message of the error
+func (engine *Engine) redactError(sqlStr string, args []interface{}, err error) error {
+	if err == nil || len(args) == 0 {
+		return err
+	}
+
+	msg := err.Error()
+	redacted := msg
+	for i, secret := range engine.secretArgs(sqlStr, args) {
+		if !secret {
+			continue
+		}
+		var s string
+		switch v := args[i].(type) {
+		case string:
+			s = v
+		case *string:
+			if v != nil {
+				s = *v
+			}
+		case []byte:
+			s = string(v)
+		}
+		if s != "" {
+			redacted = strings.Replace(redacted, s, RedactedArg, -1)
+		}
+	}
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{err: err, msg: redacted}
+}
+
+func (engine *Engine) matchRedactPatterns(arg interface{}) bool {
+	if len(engine.redactPatterns) == 0 {
+		return false
+	}
+
+	var s string
+	switch v := arg.(type) {
+	case string:
+		s = v
+	case *string:
+		if v == nil {
+			return false
+		}
+		s = *v
+	case []byte:
+		s = string(v)
+	default:
+		return false
+	}
+	for _, pattern := range engine.redactPatterns {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// the words which don't end the comparison of a column, like password IN (?, ?)
+var redactOperators = map[string]bool{
+	"IN": true, "NOT": true, "LIKE": true, "ILIKE": true, "IS": true, "NULL": true,
+	"BETWEEN": true, "ESCAPE": true, "ANY": true, "ALL": true, "SOME": true,
+}
+
+// the words which end the comparison of a column, like LIMIT ?
+var redactKeywords = map[string]bool{
+	"AND": true, "OR": true, "SELECT": true, "FROM": true, "WHERE": true,
+	"SET": true, "VALUES": true, "LIMIT": true, "OFFSET": true, "TOP": true,
+	"FETCH": true, "ROWS": true, "ONLY": true, "JOIN": true, "ON": true,
+	"GROUP": true, "ORDER": true, "BY": true, "HAVING": true, "ASC": true,
+	"DESC": true, "UNION": true, "CASE": true, "WHEN": true, "THEN": true,
+	"ELSE": true, "END": true, "INSERT": true, "REPLACE": true, "INTO": true,
+	"UPDATE": true, "DELETE": true, "RETURNING": true, "OUTPUT": true,
+	"DUPLICATE": true, "KEY": true, "CONFLICT": true, "DO": true, "AS": true,
+}
+
+// placeholderColumns returns the column which every argument of the SQL is
+// bound to, or an empty string if it's unknown, and the lower case names of
+// the tables after FROM, JOIN, UPDATE and INTO. The arguments are matched to
+// the column list of an INSERT by the position, otherwise to the last column
+// before the placeholder, e.g. `password` = ? or `token` IN (?,?).
+func placeholderColumns(sqlStr string, n int) ([]string, []string) {
+	var (
+		columns = make([]string, n)
+		seq     int
+		depth   int
+		ident   string
+		between bool
+
+		tables    []string
+		tablePos  bool // the next identifier is a table
+		lastTable bool // the last identifier is a table, which may be a schema
+		fromDepth = -1 // the depth of the FROM or UPDATE list, whose tables are separated by commas
+
+		insert     int // 1 after INSERT, 2 in the column list, 3 after the column list
+		insertCols []string
+		values     bool
+		valuePos   int
+	)
+
+	addTable := func(name string) {
+		lastTable = tablePos
+		if tablePos {
+			tables = append(tables, strings.ToLower(name))
+			tablePos = false
+		}
+	}
+
+	bind := func(idx int) {
+		if idx < 0 || idx >= n {
+			return
+		}
+		if values && depth == 1 {
+			if valuePos < len(insertCols) {
+				columns[idx] = insertCols[valuePos]
+			}
+			return
+		}
+		columns[idx] = ident
+	}
+
+	for i := 0; i < len(sqlStr); i++ {
+		c := sqlStr[i]
+		switch {
+		case c == '\'':
+			for i++; i < len(sqlStr) && sqlStr[i] != '\''; i++ {
+			}
+		case c == '`' || c == '"' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			j := strings.IndexByte(sqlStr[i+1:], end)
+			if j < 0 {
+				return columns, tables
+			}
+			ident = sqlStr[i+1 : i+1+j]
+			if insert == 2 && depth == 1 {
+				insertCols = append(insertCols, ident)
+			}
+			addTable(ident)
+			i += j + 1
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(sqlStr) && (sqlStr[j] == '_' || sqlStr[j] >= 'a' && sqlStr[j] <= 'z' ||
+				sqlStr[j] >= 'A' && sqlStr[j] <= 'Z' || sqlStr[j] >= '0' && sqlStr[j] <= '9') {
+				j++
+			}
+			word := sqlStr[i:j]
+			i = j - 1
+
+			upper := strings.ToUpper(word)
+			switch {
+			case upper == "BETWEEN":
+				between = true
+			case upper == "AND" && between:
+				between = false
+			case redactOperators[upper]:
+			case redactKeywords[upper]:
+				ident = ""
+				lastTable = false
+				tablePos = upper == "FROM" || upper == "JOIN" || upper == "UPDATE" || upper == "INTO"
+				if upper == "FROM" || upper == "UPDATE" {
+					fromDepth = depth
+				} else if upper != "AS" {
+					fromDepth = -1
+				}
+				switch {
+				case upper == "INSERT" || upper == "REPLACE":
+					insert = 1
+				case upper == "VALUES" && insert > 0:
+					insert = 3
+					values = true
+				case upper == "SELECT" && insert == 1:
+					insert = 3
+				case depth == 0 && values:
+					values = false
+				}
+			default:
+				ident = word
+				if insert == 2 && depth == 1 {
+					insertCols = append(insertCols, ident)
+				}
+				addTable(ident)
+			}
+		case c >= '0' && c <= '9':
+			for i+1 < len(sqlStr) && (sqlStr[i+1] >= '0' && sqlStr[i+1] <= '9' || sqlStr[i+1] == '.') {
+				i++
+			}
+		case c == '?':
+			bind(seq)
+			seq++
+		case (c == '$' || c == ':') && i+1 < len(sqlStr) && sqlStr[i+1] >= '0' && sqlStr[i+1] <= '9':
+			var idx int
+			for i+1 < len(sqlStr) && sqlStr[i+1] >= '0' && sqlStr[i+1] <= '9' {
+				i++
+				idx = idx*10 + int(sqlStr[i]-'0')
+			}
+			bind(idx - 1)
+		case c == '(':
+			depth++
+			if depth == 1 {
+				if insert == 1 {
+					insert = 2
+				}
+				valuePos = 0
+			}
+		case c == ')':
+			depth--
+			if depth == 0 && insert == 2 {
+				insert = 3
+			}
+		case c == ',':
+			if depth == 1 && values {
+				valuePos++
+			}
+			tablePos = depth == fromDepth
+		case c == '.' && lastTable:
+			// the schema is followed by the table
+			tables = tables[:len(tables)-1]
+			tablePos, lastTable = true, false
+		}
+	}
+	return columns, tables
+}
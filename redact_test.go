@@ -0,0 +1,127 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func TestPlaceholderColumns(t *testing.T) {
+	var kases = []struct {
+		sql     string
+		columns []string
+		tables  []string
+	}{
+		{"INSERT INTO `user` (`name`,`password`) VALUES (?,?),(?,?)", []string{"name", "password", "name", "password"}, []string{"user"}},
+		{`INSERT INTO "public"."user" ("name","password") VALUES ($1,$2) RETURNING "id"`, []string{"name", "password"}, []string{"user"}},
+		{"UPDATE `user` SET `password` = ?, `version` = `version` + 1 WHERE `id`=? AND `version`=?", []string{"password", "id", "version"}, []string{"user"}},
+		{"SELECT * FROM [user] WHERE [token] IN (?,?) AND lower(email) LIKE ? LIMIT ?", []string{"token", "token", "email", ""}, []string{"user"}},
+		{"SELECT * FROM user WHERE name = 'a ? b' AND created BETWEEN ? AND ? OFFSET ?", []string{"created", "created", ""}, []string{"user"}},
+		{"SELECT u.name FROM User u, account AS a JOIN token t ON t.id = a.id WHERE u.password = ?", []string{"password"}, []string{"user", "account", "token"}},
+		{"SELECT name, email FROM user WHERE id IN (SELECT user_id FROM session WHERE token = ?)", []string{"token"}, []string{"user", "session"}},
+	}
+	for _, kase := range kases {
+		columns, tables := placeholderColumns(kase.sql, len(kase.columns))
+		assert.EqualValues(t, kase.columns, columns, kase.sql)
+		assert.EqualValues(t, kase.tables, tables, kase.sql)
+	}
+}
+
+type RedactUser struct {
+	Id       int64
+	Name     string
+	Password string `xorm:"secret"`
+	ApiToken string `xorm:"sensitive"`
+}
+
+func TestRedactSQLLogs(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(RedactUser))
+
+	var buf bytes.Buffer
+	testEngine.SetLogger(NewSimpleLogger(&buf))
+	testEngine.SetLogLevel(core.LOG_DEBUG)
+	testEngine.ShowSQL(true)
+	defer func() {
+		testEngine.SetLogger(NewSimpleLogger(os.Stdout))
+		testEngine.SetLogLevel(core.LOG_DEBUG)
+		testEngine.ShowSQL(*showSQL)
+	}()
+
+	_, err := testEngine.Insert(&RedactUser{Name: "lunny", Password: "hunter2", ApiToken: "tok-1"})
+	assert.NoError(t, err)
+	_, err = testEngine.Where("name = ?", "lunny").Update(&RedactUser{Password: "hunter3"})
+	assert.NoError(t, err)
+	var user RedactUser
+	has, err := testEngine.Where("password = ?", "hunter3").Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	_, err = testEngine.Exec("UPDATE redact_user SET api_token = ? WHERE id = ?", "tok-2", user.Id)
+	assert.NoError(t, err)
+
+	logs := buf.String()
+	assert.Contains(t, logs, `"lunny"`)
+	assert.Contains(t, logs, `"`+RedactedArg+`"`)
+	for _, secret := range []string{"hunter2", "hunter3", "tok-1", "tok-2"} {
+		assert.False(t, strings.Contains(logs, secret), secret)
+	}
+
+	// the args matching the patterns are masked, and the redactor is pluggable
+	buf.Reset()
+	testEngine.SetRedactPatterns(regexp.MustCompile(`^lun`))
+	defer testEngine.SetRedactPatterns()
+	var secrets []bool
+	testEngine.SetSQLRedactor(func(sqlStr string, args []interface{}, secret []bool) []interface{} {
+		secrets = secret
+		return nil
+	})
+	defer testEngine.SetSQLRedactor(nil)
+
+	has, err = testEngine.Where("name = ? AND id = ?", "lunny", user.Id).Get(new(RedactUser))
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, []bool{true, false}, secrets)
+	assert.False(t, strings.Contains(buf.String(), "lunny"))
+}
+
+func TestRedactLastSQLAndErrors(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(RedactUser))
+
+	sess := testEngine.NewSession()
+	defer sess.Close()
+	_, err := sess.Get(&RedactUser{Name: "lunny", Password: "hunter2"})
+	assert.NoError(t, err)
+	sql, args := sess.LastSQL()
+	assert.Contains(t, sql, "password")
+	assert.EqualValues(t, []interface{}{"lunny", RedactedArg}, args)
+
+	engine := testEngine.(*Engine)
+	assert.EqualValues(t, map[string]map[string]bool{
+		"password":  {"redact_user": true},
+		"api_token": {"redact_user": true},
+	}, engine.secretColumns)
+
+	// the columns are only secret in their own tables
+	assert.EqualValues(t, []bool{true}, engine.secretArgs("UPDATE redact_user SET password = ?", []interface{}{"hunter2"}))
+	assert.EqualValues(t, []bool{false}, engine.secretArgs("UPDATE other_user SET password = ?", []interface{}{"hunter2"}))
+	assert.EqualValues(t, []bool{true}, engine.secretArgs("UPDATE other_user, redact_user SET password = ?", []interface{}{"hunter2"}))
+
+	driverErr := errors.New(`duplicate value "hunter2" for user lunny`)
+	err = engine.redactError("UPDATE redact_user SET password = ? WHERE name = ?", []interface{}{"hunter2", "lunny"}, driverErr)
+	assert.EqualValues(t, `duplicate value "`+RedactedArg+`" for user lunny`, err.Error())
+	assert.True(t, driverErr == err.(*redactedError).Unwrap())
+
+	err = engine.redactError("UPDATE redact_user SET name = ?", []interface{}{"lunny"}, driverErr)
+	assert.True(t, driverErr == err)
+}
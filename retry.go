@@ -0,0 +1,261 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrCircuitOpen is returned without touching the database when the circuit
+// breaker of the retry policy is open
+var ErrCircuitOpen = errors.New("circuit breaker is open since the database keeps failing")
+
+// RetryPolicy retries the transient failures like broken connections and
+// failovers of the SQLs executed out of transactions. Only the reads and the
+// writes of the sessions marked by Idempotent are retried, since a write
+// which failed may have been applied already.
+type RetryPolicy struct {
+	MaxRetries int           // the max times an execution is retried
+	Backoff    time.Duration // the delay before the first retry, doubled for every retry
+	MaxBackoff time.Duration // the max delay between the retries, no limit if zero
+
+	// IsTransient tells whether the error is worth retrying, IsTransientError is used if nil
+	IsTransient func(err error) bool
+	// OnRetry is called before every retry, e.g. to export the metrics
+	OnRetry func(err error, attempt int, delay time.Duration)
+
+	// the circuit is opened after BreakerThreshold transient failures in a row,
+	// and all the executions fail with ErrCircuitOpen in BreakerCooldown. The
+	// breaker is disabled if the threshold is zero.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// RetryStats is the statistics of the retry policy
+type RetryStats struct {
+	Retries   int64 // the retries of all the executions
+	Recovered int64 // the executions which succeeded after retried
+	Exhausted int64 // the executions which still failed after all the retries
+	Rejected  int64 // the executions rejected by the open circuit
+}
+
+type retrier struct {
+	stats  RetryStats
+	policy RetryPolicy
+
+	mutex    sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+// SetRetryPolicy sets the policy of retrying the transient failures, a nil
+// policy disables retrying. The statistics are reset.
+//
+//	engine.SetRetryPolicy(&xorm.RetryPolicy{
+//		MaxRetries:       3,
+//		Backoff:          100 * time.Millisecond,
+//		BreakerThreshold: 10,
+//		BreakerCooldown:  5 * time.Second,
+//	})
+func (engine *Engine) SetRetryPolicy(policy *RetryPolicy) {
+	if policy == nil {
+		engine.retrier = nil
+		return
+	}
+	engine.retrier = &retrier{policy: *policy}
+}
+
+// RetryStats returns the statistics of the retry policy
+func (engine *Engine) RetryStats() RetryStats {
+	r := engine.retrier
+	if r == nil {
+		return RetryStats{}
+	}
+	return RetryStats{
+		Retries:   atomic.LoadInt64(&r.stats.Retries),
+		Recovered: atomic.LoadInt64(&r.stats.Recovered),
+		Exhausted: atomic.LoadInt64(&r.stats.Exhausted),
+		Rejected:  atomic.LoadInt64(&r.stats.Rejected),
+	}
+}
+
+// Idempotent marks the writes of the session could be retried safely when
+// they fail transiently out of transactions, e.g. an update setting columns
+// to fixed values.
+func (session *Session) Idempotent() *Session {
+	session.idempotent = true
+	return session
+}
+
+// retry executes fn and retries it by the retry policy if it's idempotent
+func (engine *Engine) retry(ctx context.Context, idempotent bool, fn func() error) error {
+	r := engine.retrier
+	if r == nil {
+		return fn()
+	}
+	if !r.allow() {
+		atomic.AddInt64(&r.stats.Rejected, 1)
+		return ErrCircuitOpen
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	delay := r.policy.Backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !r.isTransient(err) {
+			r.succeed()
+			if attempt > 0 {
+				atomic.AddInt64(&r.stats.Recovered, 1)
+			}
+			return err
+		}
+		if r.fail() {
+			return err
+		}
+		if !idempotent {
+			return err
+		}
+		if attempt >= r.policy.MaxRetries {
+			if attempt > 0 {
+				atomic.AddInt64(&r.stats.Exhausted, 1)
+			}
+			return err
+		}
+
+		atomic.AddInt64(&r.stats.Retries, 1)
+		if r.policy.OnRetry != nil {
+			r.policy.OnRetry(err, attempt+1, delay)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if r.policy.MaxBackoff > 0 && delay > r.policy.MaxBackoff {
+			delay = r.policy.MaxBackoff
+		}
+	}
+}
+
+func (r *retrier) isTransient(err error) bool {
+	if r.policy.IsTransient != nil {
+		return r.policy.IsTransient(err)
+	}
+	return IsTransientError(err)
+}
+
+// allow returns false if the circuit is open, it's half open after the
+// cooldown and the next failure opens it again.
+func (r *retrier) allow() bool {
+	if r.policy.BreakerThreshold <= 0 {
+		return true
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.failures < r.policy.BreakerThreshold ||
+		time.Since(r.openedAt) >= r.policy.BreakerCooldown
+}
+
+func (r *retrier) succeed() {
+	if r.policy.BreakerThreshold <= 0 {
+		return
+	}
+	r.mutex.Lock()
+	r.failures = 0
+	r.mutex.Unlock()
+}
+
+// fail counts a transient failure and returns true if the circuit is opened
+func (r *retrier) fail() bool {
+	if r.policy.BreakerThreshold <= 0 {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failures++
+	if r.failures >= r.policy.BreakerThreshold {
+		r.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// the error codes of mssql for connection failures, failovers and shutdowns
+var mssqlTransientErrors = map[int32]bool{
+	233: true, 976: true, 978: true, 983: true, 3906: true, 4060: true,
+	6005: true, 10053: true, 10054: true, 10060: true, 40197: true,
+	40501: true, 40613: true, 49918: true, 49919: true, 49920: true,
+}
+
+var transientErrorMessages = []string{
+	"bad connection",
+	"invalid connection",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"read-only transaction",
+	"read only transaction",
+	"--read-only option",
+	"terminating connection due to administrator command",
+	"the database system is shutting down",
+	"the database system is starting up",
+}
+
+// IsTransientError returns true if the error is caused by a broken
+// connection, a failover or a shutdown of the database, which may succeed
+// if it's retried later.
+func IsTransientError(err error) bool {
+	switch err {
+	case nil, context.Canceled, context.DeadlineExceeded:
+		return false
+	case driver.ErrBadConn, io.EOF, io.ErrUnexpectedEOF:
+		return true
+	}
+
+	switch e := err.(type) {
+	case net.Error:
+		return true
+	case syscall.Errno:
+		return e == syscall.ECONNRESET || e == syscall.ECONNREFUSED ||
+			e == syscall.ECONNABORTED || e == syscall.EPIPE
+	case interface{ SQLErrorNumber() int32 }:
+		if mssqlTransientErrors[e.SQLErrorNumber()] {
+			return true
+		}
+	case interface{ Get(byte) string }:
+		// the SQLSTATE of postgres, connection exceptions, read only
+		// transactions after switchovers and shutdowns
+		code := e.Get('C')
+		if strings.HasPrefix(code, "08") || code == "25006" || strings.HasPrefix(code, "57P") {
+			return true
+		}
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, s := range transientErrorMessages {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isReadSQL returns true if the sql is a SELECT which could be retried
+func isReadSQL(sqlStr string) bool {
+	sqlStr = strings.TrimLeft(sqlStr, " \t\r\n(")
+	return len(sqlStr) >= 6 && strings.EqualFold(sqlStr[:6], "SELECT")
+}
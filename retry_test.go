@@ -0,0 +1,134 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, IsTransientError(driver.ErrBadConn))
+	assert.True(t, IsTransientError(syscall.ECONNRESET))
+	assert.True(t, IsTransientError(errors.New("pq: cannot execute UPDATE in a read-only transaction")))
+	assert.True(t, IsTransientError(errors.New("pq: terminating connection due to administrator command")))
+	assert.False(t, IsTransientError(nil))
+	assert.False(t, IsTransientError(context.Canceled))
+	assert.False(t, IsTransientError(errors.New("UNIQUE constraint failed: user.name")))
+}
+
+func TestRetryCircuitBreaker(t *testing.T) {
+	engine := &Engine{}
+	engine.SetRetryPolicy(&RetryPolicy{
+		MaxRetries:       2,
+		Backoff:          time.Millisecond,
+		BreakerThreshold: 4,
+		BreakerCooldown:  50 * time.Millisecond,
+	})
+
+	// the reads are retried until they succeed
+	var calls int
+	err := engine.retry(context.Background(), true, func() error {
+		calls++
+		if calls < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, calls)
+
+	// the writes are not retried
+	calls = 0
+	err = engine.retry(context.Background(), false, func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+	assert.EqualValues(t, driver.ErrBadConn, err)
+	assert.EqualValues(t, 1, calls)
+
+	// the circuit is opened after 4 failures in a row
+	calls = 0
+	err = engine.retry(context.Background(), true, func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+	assert.EqualValues(t, driver.ErrBadConn, err)
+	assert.EqualValues(t, 3, calls)
+	err = engine.retry(context.Background(), true, func() error {
+		t.Fatal("the circuit should be open")
+		return nil
+	})
+	assert.EqualValues(t, ErrCircuitOpen, err)
+
+	// it's half open after the cooldown
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, engine.retry(context.Background(), true, func() error { return nil }))
+	assert.NoError(t, engine.retry(context.Background(), false, func() error { return nil }))
+
+	assert.EqualValues(t, RetryStats{Retries: 4, Recovered: 1, Rejected: 1}, engine.RetryStats())
+}
+
+type RetryRecord struct {
+	Id   int64
+	Name string
+}
+
+func TestRetryPolicy(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.DropTables(new(RetryRecord)))
+
+	// the missing table is taken as a transient failure which is recovered before retrying
+	var retried []int
+	testEngine.SetRetryPolicy(&RetryPolicy{
+		MaxRetries: 1,
+		IsTransient: func(err error) bool {
+			return strings.Contains(err.Error(), "no such table") ||
+				strings.Contains(err.Error(), "doesn't exist") ||
+				strings.Contains(err.Error(), "does not exist") ||
+				strings.Contains(err.Error(), "Invalid object name")
+		},
+		OnRetry: func(err error, attempt int, delay time.Duration) {
+			retried = append(retried, attempt)
+			assert.NoError(t, testEngine.Sync2(new(RetryRecord)))
+		},
+	})
+	defer testEngine.SetRetryPolicy(nil)
+
+	var records []RetryRecord
+	assert.NoError(t, testEngine.Find(&records))
+	assert.EqualValues(t, []int{1}, retried)
+
+	// the writes are retried only if they are marked idempotent
+	retried = nil
+	assert.NoError(t, testEngine.DropTables(new(RetryRecord)))
+	_, err := testEngine.Insert(&RetryRecord{Name: "a"})
+	assert.Error(t, err)
+	assert.Nil(t, retried)
+
+	_, err = testEngine.Idempotent().Insert(&RetryRecord{Name: "a"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, []int{1}, retried)
+
+	// nothing is retried in transactions
+	retried = nil
+	assert.NoError(t, testEngine.DropTables(new(RetryRecord)))
+	session := testEngine.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.Begin())
+	_, err = session.Idempotent().Count(new(RetryRecord))
+	assert.Error(t, err)
+	assert.Nil(t, retried)
+	assert.NoError(t, session.Rollback())
+
+	assert.EqualValues(t, 2, testEngine.RetryStats().Recovered)
+}
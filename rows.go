@@ -114,7 +114,7 @@ func (rows *Rows) Close() error {
 	}
 
 	if rows.rows != nil {
-		return rows.rows.Close()
+		return rows.session.closeRows(rows.rows)
 	}
 
 	return rows.lastError
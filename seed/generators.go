@@ -0,0 +1,185 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Generator generates the value of a field for the row with the index idx.
+type Generator func(r *rand.Rand, idx int) interface{}
+
+var (
+	firstNames = []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer",
+		"Michael", "Linda", "William", "Elizabeth", "David", "Barbara", "Richard",
+		"Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Charles", "Karen"}
+	lastNames = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia",
+		"Miller", "Davis", "Rodriguez", "Martinez", "Hernandez", "Lopez", "Wilson",
+		"Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin", "Lee"}
+	cities = []string{"London", "Paris", "Berlin", "Madrid", "Rome", "Tokyo",
+		"Beijing", "Sydney", "Toronto", "Chicago", "Boston", "Seattle", "Austin"}
+	domains = []string{"example.com", "example.org", "example.net"}
+	words   = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur",
+		"adipiscing", "elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut",
+		"labore", "et", "dolore", "magna", "aliqua", "enim", "minim", "veniam"}
+)
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.Intn(len(values))]
+}
+
+// FirstName generates first names
+func FirstName() Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return pick(r, firstNames)
+	}
+}
+
+// LastName generates last names
+func LastName() Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return pick(r, lastNames)
+	}
+}
+
+// Name generates full names
+func Name() Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return pick(r, firstNames) + " " + pick(r, lastNames)
+	}
+}
+
+// Email generates email addresses, the index is a part of the address so
+// they are unique.
+func Email() Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return fmt.Sprintf("%s.%s%d@%s", strings.ToLower(pick(r, firstNames)),
+			strings.ToLower(pick(r, lastNames)), idx, pick(r, domains))
+	}
+}
+
+// Phone generates phone numbers
+func Phone() Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return fmt.Sprintf("+1-%03d-%03d-%04d", 200+r.Intn(800), r.Intn(1000), r.Intn(10000))
+	}
+}
+
+// City generates city names
+func City() Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return pick(r, cities)
+	}
+}
+
+// URL generates urls
+func URL() Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return fmt.Sprintf("https://%s/%s/%d", pick(r, domains), pick(r, words), idx)
+	}
+}
+
+// Words generates n words separated by spaces
+func Words(n int) Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		var ws = make([]string, n)
+		for i := range ws {
+			ws[i] = pick(r, words)
+		}
+		return strings.Join(ws, " ")
+	}
+}
+
+// Sentence generates a sentence with 4 to 12 words
+func Sentence() Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		s := Words(4+r.Intn(9))(r, idx).(string)
+		return strings.ToUpper(s[:1]) + s[1:] + "."
+	}
+}
+
+// IntBetween generates integers in [min, max]
+func IntBetween(min, max int64) Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return min + r.Int63n(max-min+1)
+	}
+}
+
+// FloatBetween generates floats in [min, max)
+func FloatBetween(min, max float64) Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return min + r.Float64()*(max-min)
+	}
+}
+
+// TimeBetween generates times in [from, to)
+func TimeBetween(from, to time.Time) Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		d := to.Sub(from)
+		if d <= 0 {
+			return from
+		}
+		return from.Add(time.Duration(r.Int63n(int64(d)))).Truncate(time.Second)
+	}
+}
+
+// OneOf picks one of the values
+func OneOf(values ...interface{}) Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return values[r.Intn(len(values))]
+	}
+}
+
+// Sequence generates the values by formatting the index with the format,
+// i.e. Sequence("user%d") generates user0, user1 ...
+func Sequence(format string) Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return fmt.Sprintf(format, idx)
+	}
+}
+
+// Value always generates the value
+func Value(v interface{}) Generator {
+	return func(r *rand.Rand, idx int) interface{} {
+		return v
+	}
+}
+
+// nameGenerators are used for string columns by the name of the column
+var nameGenerators = []struct {
+	keyword   string
+	generator Generator
+}{
+	{"email", Email()},
+	{"mail", Email()},
+	{"first_name", FirstName()},
+	{"firstname", FirstName()},
+	{"last_name", LastName()},
+	{"lastname", LastName()},
+	{"phone", Phone()},
+	{"mobile", Phone()},
+	{"city", City()},
+	{"url", URL()},
+	{"website", URL()},
+	{"name", Name()},
+	{"title", Words(3)},
+	{"desc", Sentence()},
+	{"content", Sentence()},
+	{"comment", Sentence()},
+}
+
+// generatorByName returns the generator for the string column name
+func generatorByName(name string) Generator {
+	name = strings.ToLower(name)
+	for _, g := range nameGenerators {
+		if strings.Contains(name, g.keyword) {
+			return g.generator
+		}
+	}
+	return nil
+}
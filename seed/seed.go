@@ -0,0 +1,613 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package seed generates plausible rows from the xorm metadata of the beans
+// for load tests and demo environments.
+//
+//	// insert 1000 users
+//	_, err := seed.Generate(engine, &User{}, 1000)
+//
+//	// override fields and reference other tables
+//	_, err = seed.GenerateAll(engine,
+//		seed.Spec{Bean: &User{}, Count: 100},
+//		seed.Spec{Bean: &Order{}, Count: 1000, Options: &seed.Options{
+//			Fields: map[string]seed.Generator{"Status": seed.OneOf("new", "paid")},
+//			Refs:   map[string]seed.Ref{"UserId": {Bean: &User{}, Column: "Id"}},
+//		}},
+//	)
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marlonfan/xorm"
+	"xorm.io/core"
+)
+
+// DefaultBatchSize is the number of rows inserted by one InsertMulti
+const DefaultBatchSize = 100
+
+var (
+	// ErrNotStruct is returned when the bean is not a pointer to a struct
+	ErrNotStruct = errors.New("seed: needs a pointer to a struct")
+
+	// ErrCyclicRefs is returned when the specs reference each other
+	ErrCyclicRefs = errors.New("seed: cyclic references between specs")
+)
+
+// Ref declares that a field references the column of another table, the
+// values are picked from the existing rows of that table.
+type Ref struct {
+	Bean   interface{}
+	Column string
+}
+
+// Options define the options of generating
+type Options struct {
+	// Fields overrides the generators, the keys are field names or column names.
+	Fields map[string]Generator
+	// Refs declares the relations, the keys are field names or column names.
+	Refs map[string]Ref
+	// BatchSize is the number of rows inserted by one InsertMulti.
+	BatchSize int
+	// NullRate is the rate of nil values of the nullable pointer fields.
+	NullRate float64
+	// Seed is the seed of the random source, the current time is used if it's 0.
+	Seed int64
+}
+
+// Spec is a bean and the number of rows to be generated, used by GenerateAll
+type Spec struct {
+	Bean    interface{}
+	Count   int
+	Options *Options
+}
+
+// Generate generates n rows of the bean and inserts them
+func Generate(engine *xorm.Engine, bean interface{}, n int, opts ...*Options) (int64, error) {
+	var options = &Options{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	beanValue := reflect.ValueOf(bean)
+	if beanValue.Kind() != reflect.Ptr || beanValue.Elem().Kind() != reflect.Struct {
+		return 0, ErrNotStruct
+	}
+	beanType := beanValue.Elem().Type()
+
+	table := engine.TableInfo(bean)
+	if !table.IsValid() {
+		return 0, fmt.Errorf("seed: unknown table of %v", beanType)
+	}
+
+	g, err := newGenerator(engine, table, options)
+	if err != nil {
+		return 0, err
+	}
+
+	// the indexes continue from the existing rows so unique values won't conflict
+	offset, err := engine.Table(table.Name).Count()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		if err := g.checkUniques(beanType, int(offset)+n-1); err != nil {
+			return 0, err
+		}
+	}
+
+	var batchSize = options.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var total int64
+	for start := 0; start < n; start += batchSize {
+		end := start + batchSize
+		if end > n {
+			end = n
+		}
+
+		batch := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(beanType)), 0, end-start)
+		for i := start; i < end; i++ {
+			row := reflect.New(beanType)
+			if err := g.fill(row.Interface(), int(offset)+i); err != nil {
+				return total, err
+			}
+			batch = reflect.Append(batch, row)
+		}
+
+		batchPtr := reflect.New(batch.Type())
+		batchPtr.Elem().Set(batch)
+		cnt, err := insertMulti(engine, table.Name, batchPtr.Interface())
+		total += cnt
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func insertMulti(engine *xorm.Engine, tableName string, rows interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.Table(tableName).InsertMulti(rows)
+}
+
+// GenerateAll generates the rows of all the specs, the specs referenced by
+// others are generated first.
+func GenerateAll(engine *xorm.Engine, specs ...Spec) (int64, error) {
+	sorted, err := sortSpecs(specs)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, spec := range sorted {
+		cnt, err := Generate(engine, spec.Bean, spec.Count, spec.Options)
+		total += cnt
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func beanType(bean interface{}) reflect.Type {
+	return reflect.Indirect(reflect.ValueOf(bean)).Type()
+}
+
+// sortSpecs sorts the specs so the referenced ones come first
+func sortSpecs(specs []Spec) ([]Spec, error) {
+	var index = make(map[reflect.Type]int, len(specs))
+	for i, spec := range specs {
+		index[beanType(spec.Bean)] = i
+	}
+
+	// 0: not visited, 1: visiting, 2: visited
+	var states = make([]int, len(specs))
+	var sorted = make([]Spec, 0, len(specs))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case 1:
+			return ErrCyclicRefs
+		case 2:
+			return nil
+		}
+		states[i] = 1
+
+		if specs[i].Options != nil {
+			var keys = make([]string, 0, len(specs[i].Options.Refs))
+			for key := range specs[i].Options.Refs {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				ref := specs[i].Options.Refs[key]
+				j, ok := index[beanType(ref.Bean)]
+				if !ok || j == i {
+					continue
+				}
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+
+		states[i] = 2
+		sorted = append(sorted, specs[i])
+		return nil
+	}
+
+	for i := range specs {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// generator fills the fields of the beans of a table
+type generator struct {
+	table    *xorm.Table
+	options  *Options
+	rand     *rand.Rand
+	now      time.Time
+	uniques  map[string]bool
+	fields   map[*core.Column]Generator
+	refs     map[*core.Column][]interface{}
+	nullable map[*core.Column]bool
+}
+
+func newGenerator(engine *xorm.Engine, table *xorm.Table, options *Options) (*generator, error) {
+	var seed = options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	g := &generator{
+		table:    table,
+		options:  options,
+		rand:     rand.New(rand.NewSource(seed)),
+		now:      time.Now(),
+		uniques:  make(map[string]bool),
+		fields:   make(map[*core.Column]Generator),
+		refs:     make(map[*core.Column][]interface{}),
+		nullable: make(map[*core.Column]bool),
+	}
+
+	for _, name := range table.PrimaryKeys {
+		g.uniques[name] = true
+	}
+	for _, index := range table.Indexes {
+		if index.Type == core.UniqueType {
+			for _, name := range index.Cols {
+				g.uniques[name] = true
+			}
+		}
+	}
+
+	for key, gen := range options.Fields {
+		col, err := findColumn(table.Table, key)
+		if err != nil {
+			return nil, err
+		}
+		g.fields[col] = gen
+	}
+
+	for key, ref := range options.Refs {
+		col, err := findColumn(table.Table, key)
+		if err != nil {
+			return nil, err
+		}
+		values, err := refValues(engine, ref)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("seed: no rows of %s to be referenced by %s", engine.TableName(ref.Bean, true), key)
+		}
+		g.refs[col] = values
+	}
+	return g, nil
+}
+
+// findColumn finds the column by the field name or the column name
+func findColumn(table *core.Table, name string) (*core.Column, error) {
+	for _, col := range table.Columns() {
+		if col.FieldName == name || strings.EqualFold(col.Name, name) {
+			return col, nil
+		}
+	}
+	return nil, fmt.Errorf("seed: field %s is not exist on table %s", name, table.Name)
+}
+
+// refValues returns all the values of the referenced column
+func refValues(engine *xorm.Engine, ref Ref) ([]interface{}, error) {
+	refTable := engine.TableInfo(ref.Bean)
+	col, err := findColumn(refTable.Table, ref.Column)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := engine.QueryInterface(fmt.Sprintf("SELECT %s FROM %s",
+		engine.Quote(col.Name), engine.Quote(engine.TableName(ref.Bean, true))))
+	if err != nil {
+		return nil, err
+	}
+	var values = make([]interface{}, 0, len(results))
+	for _, result := range results {
+		for _, v := range result {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// skip returns true if the column should not be generated
+func skip(col *core.Column) bool {
+	return col.IsAutoIncrement || col.MapType == core.ONLYFROMDB ||
+		col.IsCreated || col.IsUpdated || col.IsDeleted || col.IsVersion ||
+		col.IsJSON
+}
+
+// fill fills the fields of the bean with the generated values
+func (g *generator) fill(bean interface{}, idx int) error {
+	for _, col := range g.table.Columns() {
+		gen, hasGen := g.fields[col]
+		if skip(col) && !hasGen {
+			continue
+		}
+
+		fieldValue, err := col.ValueOf(bean)
+		if err != nil {
+			return err
+		}
+		if !fieldValue.CanSet() {
+			continue
+		}
+
+		var value interface{}
+		if hasGen {
+			value = gen(g.rand, idx)
+		} else if values, ok := g.refs[col]; ok {
+			value = values[g.rand.Intn(len(values))]
+		} else {
+			if fieldValue.Kind() == reflect.Ptr && col.Nullable && !g.uniques[col.Name] &&
+				g.rand.Float64() < g.options.NullRate {
+				continue
+			}
+			value = g.generate(col, fieldValue.Type(), idx)
+			if value == nil {
+				continue
+			}
+		}
+
+		if err := setValue(*fieldValue, value); err != nil {
+			return fmt.Errorf("seed: set field %s: %v", col.FieldName, err)
+		}
+	}
+	return nil
+}
+
+var tpTime = reflect.TypeOf(time.Time{})
+
+// generate generates the value of the column by its type
+func (g *generator) generate(col *core.Column, tp reflect.Type, idx int) interface{} {
+	for tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+
+	var unique = g.uniques[col.Name]
+	if len(col.EnumOptions) > 0 {
+		return pickOption(g.rand, col.EnumOptions)
+	}
+	if len(col.SetOptions) > 0 {
+		return pickOption(g.rand, col.SetOptions)
+	}
+
+	if tp == tpTime {
+		if unique {
+			return g.now.Add(-time.Duration(idx) * time.Second).Truncate(time.Second)
+		}
+		return TimeBetween(g.now.AddDate(-1, 0, 0), g.now)(g.rand, idx)
+	}
+
+	switch tp.Kind() {
+	case reflect.String:
+		return g.generateString(col, unique, idx)
+	case reflect.Bool:
+		if unique {
+			// checkUniques makes sure there are at most two values
+			return idx == 1
+		}
+		return g.rand.Intn(2) == 1
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		max := maxInt(col, tp)
+		if unique {
+			// checkUniques makes sure idx+1 is not larger than max
+			return int64(idx + 1)
+		}
+		return g.rand.Int63n(max + 1)
+	case reflect.Float32, reflect.Float64:
+		max, scale := floatRange(col)
+		if unique {
+			// checkUniques makes sure idx+1 is not larger than max
+			return float64(idx + 1)
+		}
+		if max == 0 || max > 1000 {
+			max = 1000
+		}
+		f := g.rand.Float64() * max
+		p := math.Pow10(scale)
+		return math.Round(f*p) / p
+	case reflect.Slice:
+		if tp.Elem().Kind() == reflect.Uint8 {
+			var n = 16
+			if col.Length > 0 && col.Length < n {
+				n = col.Length
+			}
+			var bs = make([]byte, n)
+			g.rand.Read(bs)
+			return bs
+		}
+	}
+	return nil
+}
+
+func (g *generator) generateString(col *core.Column, unique bool, idx int) string {
+	var s string
+	if gen := generatorByName(col.Name); gen != nil {
+		s = gen(g.rand, idx).(string)
+	} else {
+		s = Words(2)(g.rand, idx).(string)
+	}
+
+	var length = stringLength(col)
+	if unique {
+		id := strconv.Itoa(idx)
+		if strings.Contains(s, id) && (length == 0 || len(s) <= length) {
+			return s
+		}
+		// checkUniques makes sure the index fits in the column
+		if length > 0 && len(id)+1 > length {
+			return id
+		}
+		return fitLength(s, "-"+id, length)
+	}
+	return fitLength(s, "", length)
+}
+
+// stringLength returns the max length of the string column, 0 if there is no limit
+func stringLength(col *core.Column) int {
+	if !col.SQLType.IsText() || col.SQLType.Name == core.Text ||
+		strings.Contains(col.SQLType.Name, "TEXT") {
+		return 0
+	}
+	return col.Length
+}
+
+// checkUniques returns an error if any unique column could not hold the
+// unique values generated for the indexes up to maxIdx
+func (g *generator) checkUniques(beanType reflect.Type, maxIdx int) error {
+	bean := reflect.New(beanType).Interface()
+	for _, col := range g.table.Columns() {
+		if !g.uniques[col.Name] || skip(col) || len(col.EnumOptions) > 0 || len(col.SetOptions) > 0 {
+			continue
+		}
+		if _, ok := g.fields[col]; ok {
+			continue
+		}
+		if _, ok := g.refs[col]; ok {
+			continue
+		}
+		fieldValue, err := col.ValueOf(bean)
+		if err != nil {
+			return err
+		}
+		tp := fieldValue.Type()
+		for tp.Kind() == reflect.Ptr {
+			tp = tp.Elem()
+		}
+
+		var fits = true
+		switch tp.Kind() {
+		case reflect.String:
+			length := stringLength(col)
+			fits = length == 0 || len(strconv.Itoa(maxIdx)) <= length
+		case reflect.Bool:
+			fits = maxIdx < 2
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fits = int64(maxIdx)+1 <= maxInt(col, tp)
+		case reflect.Float32, reflect.Float64:
+			max, _ := floatRange(col)
+			fits = max == 0 || float64(maxIdx)+1 <= max
+		}
+		if !fits {
+			return fmt.Errorf("seed: unique column %s could not hold %d unique values", col.Name, maxIdx+1)
+		}
+	}
+	return nil
+}
+
+// fitLength truncates s so that s with the suffix is not longer than length
+func fitLength(s, suffix string, length int) string {
+	if length > 0 && len(s)+len(suffix) > length {
+		n := length - len(suffix)
+		if n < 0 {
+			n = 0
+		}
+		s = s[:n]
+	}
+	return s + suffix
+}
+
+// floatRange returns the max value and the scale of the float column, the
+// max is 0 if the column has no precision
+func floatRange(col *core.Column) (float64, int) {
+	if col.SQLType.IsNumeric() && col.Length > 0 && col.Length > col.Length2 {
+		return math.Pow10(col.Length-col.Length2) - 1, col.Length2
+	}
+	return 0, 2
+}
+
+// maxInt returns the max value of the integer column
+func maxInt(col *core.Column, tp reflect.Type) int64 {
+	var max int64 = 1000000
+	switch col.SQLType.Name {
+	case core.Bool, core.Boolean, core.Bit:
+		max = 1
+	case core.TinyInt:
+		max = math.MaxInt8
+	case core.SmallInt:
+		max = math.MaxInt16
+	}
+
+	if bits := tp.Bits(); bits < 64 {
+		var kindMax int64 = 1<<uint(bits-1) - 1
+		switch tp.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			kindMax = 1<<uint(bits) - 1
+		}
+		if kindMax < max {
+			max = kindMax
+		}
+	}
+	return max
+}
+
+// pickOption picks one of the enum or set options
+func pickOption(r *rand.Rand, options map[string]int) string {
+	var keys = make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys[r.Intn(len(keys))]
+}
+
+// setValue sets the generated value to the field with the conversions
+func setValue(field reflect.Value, value interface{}) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if bs, ok := value.([]byte); ok && field.Kind() != reflect.Slice {
+		v = reflect.ValueOf(string(bs))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		if v.Kind() != reflect.String {
+			field.SetString(fmt.Sprint(v.Interface()))
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if v.Kind() == reflect.String {
+			f, err := strconv.ParseFloat(v.String(), 64)
+			if err != nil {
+				return err
+			}
+			v = reflect.ValueOf(f)
+		}
+	case reflect.Bool:
+		if v.Kind() != reflect.Bool {
+			b, err := strconv.ParseBool(fmt.Sprint(v.Interface()))
+			if err != nil {
+				return err
+			}
+			v = reflect.ValueOf(b)
+		}
+	}
+
+	if !v.Type().ConvertibleTo(field.Type()) {
+		return fmt.Errorf("cannot convert %v to %v", v.Type(), field.Type())
+	}
+	field.Set(v.Convert(field.Type()))
+	return nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package seed
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/marlonfan/xorm"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+const dbName = "seeddb.sqlite3"
+
+type SeedUser struct {
+	Id       int64
+	Name     string `xorm:"varchar(10)"`
+	Email    string `xorm:"varchar(50) unique"`
+	Age      int8
+	Score    float64 `xorm:"decimal(5,2)"`
+	Status   string  `xorm:"enum('active','banned')"`
+	Nickname *string `xorm:"null"`
+	Birthday time.Time
+	Created  time.Time `xorm:"created"`
+}
+
+type SeedOrder struct {
+	Id     int64
+	UserId int64 `xorm:"index"`
+	Amount int
+	Note   string `xorm:"varchar(4)"`
+}
+
+func prepareEngine(t *testing.T) *xorm.Engine {
+	os.Remove(dbName)
+	engine, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Sync2(new(SeedUser), new(SeedOrder)))
+	return engine
+}
+
+func TestGenerate(t *testing.T) {
+	engine := prepareEngine(t)
+	defer func() {
+		engine.Close()
+		os.Remove(dbName)
+	}()
+
+	cnt, err := Generate(engine, &SeedUser{}, 250, &Options{BatchSize: 40, Seed: 1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 250, cnt)
+
+	// unique values continue from the existing rows
+	cnt, err = Generate(engine, &SeedUser{}, 10, &Options{
+		Seed:   2,
+		Fields: map[string]Generator{"Status": Value("banned")},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, cnt)
+
+	var users []SeedUser
+	assert.NoError(t, engine.Asc("id").Find(&users))
+	assert.EqualValues(t, 260, len(users))
+
+	var emails = make(map[string]bool)
+	for i, user := range users {
+		assert.True(t, len(user.Name) <= 10)
+		assert.True(t, user.Age >= 0)
+		assert.True(t, user.Score < 1000)
+		assert.Contains(t, []string{"active", "banned"}, user.Status)
+		assert.False(t, user.Birthday.IsZero())
+		assert.False(t, user.Created.IsZero())
+		assert.False(t, emails[user.Email])
+		emails[user.Email] = true
+		if i >= 250 {
+			assert.EqualValues(t, "banned", user.Status)
+		}
+	}
+}
+
+func TestGenerateAll(t *testing.T) {
+	engine := prepareEngine(t)
+	defer func() {
+		engine.Close()
+		os.Remove(dbName)
+	}()
+
+	cnt, err := GenerateAll(engine,
+		Spec{Bean: &SeedOrder{}, Count: 50, Options: &Options{
+			Refs: map[string]Ref{"UserId": {Bean: &SeedUser{}, Column: "Id"}},
+		}},
+		Spec{Bean: &SeedUser{}, Count: 5},
+	)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 55, cnt)
+
+	var orders []SeedOrder
+	assert.NoError(t, engine.Find(&orders))
+	assert.EqualValues(t, 50, len(orders))
+	for _, order := range orders {
+		assert.True(t, order.UserId >= 1 && order.UserId <= 5)
+		assert.True(t, len(order.Note) <= 4)
+	}
+
+	_, err = GenerateAll(engine,
+		Spec{Bean: &SeedOrder{}, Options: &Options{
+			Refs: map[string]Ref{"UserId": {Bean: &SeedUser{}, Column: "Id"}},
+		}},
+		Spec{Bean: &SeedUser{}, Options: &Options{
+			Refs: map[string]Ref{"Age": {Bean: &SeedOrder{}, Column: "Id"}},
+		}},
+	)
+	assert.EqualValues(t, ErrCyclicRefs, err)
+}
+
+type SeedCode struct {
+	Id    int64
+	Code  string `xorm:"varchar(3) unique"`
+	Level int8   `xorm:"unique"`
+}
+
+func TestGenerateUniqueDomain(t *testing.T) {
+	engine := prepareEngine(t)
+	defer func() {
+		engine.Close()
+		os.Remove(dbName)
+	}()
+	assert.NoError(t, engine.Sync2(new(SeedCode)))
+
+	cnt, err := Generate(engine, &SeedCode{}, 120, &Options{Seed: 1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 120, cnt)
+
+	var codes []SeedCode
+	assert.NoError(t, engine.Find(&codes))
+	var seen = make(map[string]bool)
+	for _, code := range codes {
+		assert.True(t, len(code.Code) <= 3)
+		assert.False(t, seen[code.Code])
+		seen[code.Code] = true
+	}
+
+	// int8 could not hold more than 127 unique values
+	_, err = Generate(engine, &SeedCode{}, 10, &Options{Seed: 2})
+	assert.Error(t, err)
+	cnt, err = engine.Count(new(SeedCode))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 120, cnt)
+
+	// varchar(3) could not hold 1000 unique values
+	_, err = Generate(engine, &SeedCode{}, 1000, &Options{
+		Seed:   3,
+		Fields: map[string]Generator{"Level": Value(nil)},
+	})
+	assert.Error(t, err)
+}
+
+type SeedFlag struct {
+	Id     int64
+	Active bool    `xorm:"unique"`
+	Rate   float64 `xorm:"decimal(3,1) unique"`
+}
+
+func TestGenerateUniqueFloatAndBool(t *testing.T) {
+	engine := prepareEngine(t)
+	defer func() {
+		engine.Close()
+		os.Remove(dbName)
+	}()
+	assert.NoError(t, engine.Sync2(new(SeedFlag)))
+
+	cnt, err := Generate(engine, &SeedFlag{}, 2, &Options{Seed: 1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	var flags []SeedFlag
+	assert.NoError(t, engine.Asc("id").Find(&flags))
+	assert.EqualValues(t, 2, len(flags))
+	assert.NotEqual(t, flags[0].Active, flags[1].Active)
+	assert.NotEqual(t, flags[0].Rate, flags[1].Rate)
+
+	// bool could not hold more than 2 unique values
+	_, err = Generate(engine, &SeedFlag{}, 1, &Options{
+		Seed:   2,
+		Fields: map[string]Generator{"Rate": Value(nil)},
+	})
+	assert.Error(t, err)
+
+	// decimal(3,1) could not hold more than 99 unique values
+	_, err = Generate(engine, &SeedFlag{}, 100, &Options{
+		Seed:   3,
+		Fields: map[string]Generator{"Active": Value(nil)},
+	})
+	assert.Error(t, err)
+}
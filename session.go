@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
@@ -53,6 +54,13 @@ type Session struct {
 	prepareStmt bool
 	stmtCache   map[uint32]*core.Stmt //key: hash.Hash32 of (queryStr, len(queryStr))
 
+	// the writes could be retried by the retry policy
+	idempotent bool
+
+	// the concurrency class and the slot held by the session
+	class string
+	slot  *bulkhead
+
 	// !evalphobia! stored the last executed query on this session
 	//beforeSQLExec func(string, ...interface{})
 	lastSQL     string
@@ -79,6 +87,8 @@ func (session *Session) Init() {
 	session.isAutoClose = false
 	session.autoResetStatement = true
 	session.prepareStmt = false
+	session.idempotent = false
+	session.class = ""
 
 	// !nashtsai! is lazy init better?
 	session.afterInsertBeans = make(map[interface{}]*[]func(interface{}), 0)
@@ -94,6 +104,7 @@ func (session *Session) Init() {
 	session.lastSQLArgs = []interface{}{}
 
 	session.ctx = session.engine.defaultContext
+	session.statement.ctx = session.ctx
 }
 
 // Close release the connection from pool
@@ -112,6 +123,7 @@ func (session *Session) Close() {
 		session.stmtCache = nil
 		session.db = nil
 	}
+	session.releaseSlot()
 }
 
 // ContextCache enable context cache or not
@@ -288,6 +300,10 @@ func (session *Session) canCache() bool {
 		len(session.statement.selectStr) > 0 {
 		return false
 	}
+	// computed columns may depend on other rows or tables so they are never cached
+	if session.engine.hasExprColumn(session.statement.RefTable) {
+		return false
+	}
 	return true
 }
 
@@ -450,6 +466,19 @@ func (session *Session) slice2Bean(scanResults []interface{}, fields []string, b
 			continue
 		}
 
+		if col := table.GetColumnIdx(key, idx); col != nil && isSpatialType(col.SQLType) {
+			data, err := value2Bytes(&rawValue)
+			if err != nil {
+				return nil, err
+			}
+			if ok, err := session.engine.setGeometryField(*fieldValue, data); ok {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
 		if fieldValue.CanAddr() {
 			if structConvert, ok := fieldValue.Addr().Interface().(core.Conversion); ok {
 				if data, err := value2Bytes(&rawValue); err == nil {
@@ -481,6 +510,14 @@ func (session *Session) slice2Bean(scanResults []interface{}, fields []string, b
 		if col.IsPrimaryKey {
 			pk = append(pk, rawValue.Interface())
 		}
+		if session.engine.isStreamColumn(col) {
+			data, err := value2Bytes(&rawValue)
+			if err != nil {
+				return nil, err
+			}
+			fieldValue.Set(reflect.ValueOf(bytes.NewReader(data)))
+			continue
+		}
 		fieldType := fieldValue.Type()
 		hasAssigned := false
 
@@ -860,16 +897,17 @@ func (session *Session) saveLastSQL(sql string, args ...interface{}) {
 func (session *Session) logSQL(sqlStr string, sqlArgs ...interface{}) {
 	if session.showSQL && !session.engine.showExecTime {
 		if len(sqlArgs) > 0 {
-			session.engine.logger.Infof("[SQL] %v %#v", sqlStr, sqlArgs)
+			session.engine.logger.Infof("[SQL] %v %#v", sqlStr, session.engine.redactArgs(sqlStr, sqlArgs))
 		} else {
 			session.engine.logger.Infof("[SQL] %v", sqlStr)
 		}
 	}
 }
 
-// LastSQL returns last query information
+// LastSQL returns last query information, the secret arguments are masked
+// like the SQL logs
 func (session *Session) LastSQL() (string, []interface{}) {
-	return session.lastSQL, session.lastSQLArgs
+	return session.lastSQL, session.engine.redactArgs(session.lastSQL, session.lastSQLArgs)
 }
 
 // Unscoped always disable struct tag "deleted"
@@ -0,0 +1,281 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// defaultBlobChunkSize is the default size of one chunk when streaming blobs
+const defaultBlobChunkSize = 1 << 20
+
+var tpReader = reflect.TypeOf((*io.Reader)(nil)).Elem()
+
+// blobStream is an io.Reader field which will be written to its column chunk by chunk
+type blobStream struct {
+	col    *core.Column
+	reader io.Reader
+}
+
+// SetBlobChunkSize sets the size of one chunk when writing io.Reader fields or
+// reading blobs via OpenBlob. The chunks could not be appended on Oracle, so
+// the content of the io.Reader fields should fit in one chunk there.
+func (engine *Engine) SetBlobChunkSize(size int) {
+	engine.blobChunkSize = size
+}
+
+func (engine *Engine) getBlobChunkSize() int {
+	if engine.blobChunkSize <= 0 {
+		return defaultBlobChunkSize
+	}
+	return engine.blobChunkSize
+}
+
+// blobAppendExpr returns the expression to append a chunk to the blob column.
+// Oracle appends to a LOB only by DBMS_LOB.APPEND in PL/SQL, which is not
+// an expression, so ErrNotImplemented is returned.
+func (engine *Engine) blobAppendExpr(colName string) (string, error) {
+	switch engine.dialect.DBType() {
+	case core.MYSQL:
+		return fmt.Sprintf("CONCAT(%s, ?)", colName), nil
+	case core.POSTGRES:
+		return fmt.Sprintf("%s || ?", colName), nil
+	case core.SQLITE:
+		return fmt.Sprintf("CAST(%s || ? AS BLOB)", colName), nil
+	case core.MSSQL:
+		return fmt.Sprintf("%s + ?", colName), nil
+	}
+	return "", ErrNotImplemented
+}
+
+// blobSubstrExpr returns the expression to read a chunk from the blob column,
+// the first placeholder is the 1-based offset and the second one is the length
+func (engine *Engine) blobSubstrExpr(colName string) string {
+	switch engine.dialect.DBType() {
+	case core.MSSQL:
+		return fmt.Sprintf("SUBSTRING(%s, ?, ?)", colName)
+	case core.ORACLE:
+		return fmt.Sprintf("DBMS_LOB.SUBSTR(%s, ?, ?)", colName)
+	}
+	return fmt.Sprintf("SUBSTR(%s, ?, ?)", colName)
+}
+
+// blobStreams returns all the non-nil io.Reader fields of the bean
+func (session *Session) blobStreams(table *core.Table, bean interface{}) ([]blobStream, error) {
+	var streams []blobStream
+	for _, col := range table.Columns() {
+		if !session.engine.isStreamColumn(col) {
+			continue
+		}
+		if session.statement.omitColumnMap.contain(col.Name) {
+			continue
+		}
+		if len(session.statement.columnMap) > 0 && !session.statement.columnMap.contain(col.Name) {
+			continue
+		}
+
+		fieldValue, err := col.ValueOf(bean)
+		if err != nil {
+			return nil, err
+		}
+		if fieldValue.IsNil() {
+			continue
+		}
+		streams = append(streams, blobStream{col, fieldValue.Interface().(io.Reader)})
+	}
+	return streams, nil
+}
+
+// writeBlobStreams writes the content of the readers to the columns of the
+// record identified by pk chunk by chunk, so that only one chunk is kept in
+// memory. The content fitting in one chunk is written at once, otherwise the
+// chunks are appended to the column, which makes the database rewrite the
+// value every time, so the chunk size should be large enough for the common
+// content. All the chunks are written in the transaction of the session, or
+// a new transaction if there is none, so a failure never leaves a truncated
+// value behind.
+func (session *Session) writeBlobStreams(tableName string, table *core.Table, pk core.PK, streams []blobStream) error {
+	if len(streams) == 0 {
+		return nil
+	}
+	if len(pk) != len(table.PrimaryKeys) || isPKZero(pk) {
+		return errors.New("streaming io.Reader fields needs the primary key")
+	}
+
+	var cond = builder.Eq{}
+	for i, col := range table.PKColumns() {
+		cond[session.engine.Quote(col.Name)] = pk[i]
+	}
+	condSQL, condArgs, err := builder.ToSQL(cond)
+	if err != nil {
+		return err
+	}
+
+	var buf = make([]byte, session.engine.getBlobChunkSize())
+	return session.inTx(func() error {
+		for _, stream := range streams {
+			colName := session.engine.Quote(stream.col.Name)
+			// the error is only returned when the content needs appending
+			expr, appendErr := session.engine.blobAppendExpr(colName)
+			setSQL := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s",
+				session.engine.Quote(tableName), colName, condSQL)
+			appendSQL := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s",
+				session.engine.Quote(tableName), colName, expr, condSQL)
+
+			for sqlStr := setSQL; ; sqlStr = appendSQL {
+				n, err := io.ReadFull(stream.reader, buf)
+				if n > 0 {
+					if sqlStr == appendSQL && appendErr != nil {
+						return appendErr
+					}
+					if _, err := session.exec(sqlStr, append([]interface{}{buf[:n]}, condArgs...)...); err != nil {
+						return err
+					}
+				}
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					break
+				} else if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// OpenBlob returns a reader of the blob column of the record which has the
+// same primary keys as the bean. The content is read chunk by chunk so
+// a large blob will never be loaded into memory at once.
+func (session *Session) OpenBlob(bean interface{}, colName string) (io.ReadCloser, error) {
+	if session.statement.lastError != nil {
+		return nil, session.statement.lastError
+	}
+
+	v := rValue(bean)
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("needs a pointer to a struct")
+	}
+	table, err := session.engine.autoMapType(v)
+	if err != nil {
+		return nil, err
+	}
+	cols, err := session.engine.loadColumns(table, []string{colName})
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, ErrFieldIsNotValid{colName, table.Name}
+	}
+	// the offsets of the text columns are counted in characters
+	if !cols[0].SQLType.IsBlob() {
+		return nil, fmt.Errorf("column %s of table %s is not a blob", cols[0].Name, table.Name)
+	}
+
+	pk, err := session.engine.idOfV(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(pk) == 0 || isPKZero(pk) {
+		return nil, errors.New("open blob needs the primary key")
+	}
+
+	var tableName = session.statement.TableName()
+	if tableName == "" {
+		tableName = session.engine.TableName(bean, true)
+	}
+
+	var cond = builder.Eq{}
+	for i, col := range table.PKColumns() {
+		cond[session.engine.Quote(col.Name)] = pk[i]
+	}
+	condSQL, condArgs, err := builder.ToSQL(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blobReader{
+		session: session,
+		sqlStr: fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+			session.engine.blobSubstrExpr(session.engine.Quote(cols[0].Name)),
+			session.engine.Quote(tableName), condSQL),
+		condArgs:  condArgs,
+		chunkSize: session.engine.getBlobChunkSize(),
+	}, nil
+}
+
+// blobReader reads a blob column chunk by chunk
+type blobReader struct {
+	session   *Session
+	sqlStr    string
+	condArgs  []interface{}
+	chunkSize int
+	offset    int
+	buf       []byte
+	eof       bool
+}
+
+func (r *blobReader) nextChunk() error {
+	var args = []interface{}{r.offset + 1, r.chunkSize}
+	if r.session.engine.dialect.DBType() == core.ORACLE {
+		args = []interface{}{r.chunkSize, r.offset + 1}
+	}
+
+	rows, err := r.session.queryRows(r.sqlStr, append(args, r.condArgs...)...)
+	if err != nil {
+		return err
+	}
+	defer r.session.closeRows(rows)
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrNotExist
+	}
+
+	var chunk []byte
+	if err := rows.Scan(&chunk); err != nil {
+		return err
+	}
+	r.buf = chunk
+	r.offset += len(chunk)
+	if len(chunk) < r.chunkSize {
+		r.eof = true
+	}
+	return nil
+}
+
+// Read implements io.Reader
+func (r *blobReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.nextChunk(); err != nil {
+			return 0, err
+		}
+		if len(r.buf) == 0 {
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Close implements io.Closer, the session will be closed if it's created by engine
+func (r *blobReader) Close() error {
+	if r.session.isAutoClose {
+		r.session.Close()
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import "context"
 // Context sets the context on this session
 func (session *Session) Context(ctx context.Context) *Session {
 	session.ctx = ctx
+	session.statement.ctx = ctx
 	return session
 }
 
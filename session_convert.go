@@ -90,6 +90,12 @@ var (
 
 // convert a db data([]byte) to a field value
 func (session *Session) bytes2Value(col *core.Column, fieldValue *reflect.Value, data []byte) error {
+	if isSpatialType(col.SQLType) {
+		if ok, err := session.engine.setGeometryField(*fieldValue, data); ok {
+			return err
+		}
+	}
+
 	if structConvert, ok := fieldValue.Addr().Interface().(core.Conversion); ok {
 		return structConvert.FromDB(data)
 	}
@@ -537,6 +543,23 @@ func (session *Session) bytes2Value(col *core.Column, fieldValue *reflect.Value,
 
 // convert a field value of a struct to interface for put into db
 func (session *Session) value2Interface(col *core.Column, fieldValue reflect.Value) (interface{}, error) {
+	if session.engine.isStreamColumn(col) {
+		// the content of io.Reader will be written after the record saved
+		if fieldValue.IsNil() {
+			return nil, nil
+		}
+		return []byte{}, nil
+	}
+
+	if isSpatialType(col.SQLType) {
+		if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+			return nil, nil
+		}
+		if g, ok := fieldValue.Interface().(GeometryValue); ok {
+			return session.engine.geometryToDB(col, g), nil
+		}
+	}
+
 	if fieldValue.CanAddr() {
 		if fieldConvert, ok := fieldValue.Addr().Interface().(core.Conversion); ok {
 			data, err := fieldConvert.ToDB()
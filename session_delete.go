@@ -124,26 +124,39 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 	}
 
 	if len(orderSQL) > 0 {
-		switch session.engine.dialect.DBType() {
-		case core.POSTGRES:
-			inSQL := fmt.Sprintf("ctid IN (SELECT ctid FROM %s%s)", tableName, orderSQL)
-			if len(condSQL) > 0 {
-				deleteSQL += " AND " + inSQL
-			} else {
-				deleteSQL += " WHERE " + inSQL
+		limitSQL, err := session.engine.limitWriteSQL(tableName, condSQL, orderSQL)
+		if err != nil {
+			return 0, err
+		}
+		deleteSQL += limitSQL
+	}
+
+	// the deleted nodes are removed from the tree in the same transaction
+	// when they are not soft deleted
+	var tree *treeInfo
+	var treeIDs []interface{}
+	var inTx bool
+	if session.statement.unscoped || table.DeletedColumn() == nil {
+		if tree = session.engine.treeInfo(table); tree != nil {
+			if session.isAutoCommit {
+				if err := session.Begin(); err != nil {
+					return 0, err
+				}
+				inTx = true
+				defer session.Rollback()
 			}
-		case core.SQLITE:
-			inSQL := fmt.Sprintf("rowid IN (SELECT rowid FROM %s%s)", tableName, orderSQL)
+
+			selectSQL := fmt.Sprintf("SELECT %s FROM %s", session.engine.Quote(tree.pkCol.Name), tableName)
 			if len(condSQL) > 0 {
-				deleteSQL += " AND " + inSQL
-			} else {
-				deleteSQL += " WHERE " + inSQL
+				selectSQL += " WHERE " + condSQL
+			}
+			results, err := session.treeQuery(selectSQL+orderSQL, condArgs...)
+			if err != nil {
+				return 0, err
+			}
+			for _, result := range results {
+				treeIDs = append(treeIDs, result[0])
 			}
-		// TODO: how to handle delete limit on mssql?
-		case core.MSSQL:
-			return 0, ErrNotImplemented
-		default:
-			deleteSQL += orderSQL
 		}
 	}
 
@@ -165,27 +178,11 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 			condSQL)
 
 		if len(orderSQL) > 0 {
-			switch session.engine.dialect.DBType() {
-			case core.POSTGRES:
-				inSQL := fmt.Sprintf("ctid IN (SELECT ctid FROM %s%s)", tableName, orderSQL)
-				if len(condSQL) > 0 {
-					realSQL += " AND " + inSQL
-				} else {
-					realSQL += " WHERE " + inSQL
-				}
-			case core.SQLITE:
-				inSQL := fmt.Sprintf("rowid IN (SELECT rowid FROM %s%s)", tableName, orderSQL)
-				if len(condSQL) > 0 {
-					realSQL += " AND " + inSQL
-				} else {
-					realSQL += " WHERE " + inSQL
-				}
-			// TODO: how to handle delete limit on mssql?
-			case core.MSSQL:
-				return 0, ErrNotImplemented
-			default:
-				realSQL += orderSQL
+			limitSQL, err := session.engine.limitWriteSQL(tableName, condSQL, orderSQL)
+			if err != nil {
+				return 0, err
 			}
+			realSQL += limitSQL
 		}
 
 		// !oinume! Insert nowTime to the head of session.statement.Params
@@ -213,6 +210,17 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 		return 0, err
 	}
 
+	if len(treeIDs) > 0 {
+		if err = session.treeAfterDelete(tree, tableNameNoQuote, treeIDs); err != nil {
+			return 0, err
+		}
+	}
+	if inTx {
+		if err = session.Commit(); err != nil {
+			return 0, err
+		}
+	}
+
 	// handle after delete processors
 	if session.isAutoCommit {
 		for _, closure := range session.afterClosures {
@@ -242,3 +250,23 @@ func (session *Session) Delete(bean interface{}) (int64, error) {
 
 	return res.RowsAffected()
 }
+
+// limitWriteSQL returns the sql appended to DELETE or the soft deleting
+// UPDATE to apply the order and the limit, the rows are limited by a sub
+// query of their row ids if the dialect couldn't limit the writes.
+func (engine *Engine) limitWriteSQL(tableName, condSQL, orderSQL string) (string, error) {
+	caps := engine.Capabilities()
+	if caps.WriteLimit {
+		return orderSQL, nil
+	}
+	// TODO: how to handle delete limit on mssql?
+	if caps.RowID == "" {
+		return "", ErrNotImplemented
+	}
+
+	inSQL := fmt.Sprintf("%s IN (SELECT %s FROM %s%s)", caps.RowID, caps.RowID, tableName, orderSQL)
+	if len(condSQL) > 0 {
+		return " AND " + inSQL, nil
+	}
+	return " WHERE " + inSQL, nil
+}
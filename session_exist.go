@@ -10,7 +10,6 @@ import (
 	"reflect"
 
 	"xorm.io/builder"
-	"xorm.io/core"
 )
 
 // Exist returns true if the record exist otherwise return false
@@ -36,26 +35,29 @@ func (session *Session) Exist(bean ...interface{}) (bool, error) {
 
 			tableName = session.statement.Engine.Quote(tableName)
 
+			session.statement.mergePolicyCond()
 			if session.statement.cond.IsValid() {
 				condSQL, condArgs, err := builder.ToSQL(session.statement.cond)
 				if err != nil {
 					return false, err
 				}
 
-				if session.engine.dialect.DBType() == core.MSSQL {
+				switch session.engine.Capabilities().Limit {
+				case LimitTop:
 					sqlStr = fmt.Sprintf("SELECT TOP 1 * FROM %s WHERE %s", tableName, condSQL)
-				} else if session.engine.dialect.DBType() == core.ORACLE {
+				case LimitRowNum:
 					sqlStr = fmt.Sprintf("SELECT * FROM %s WHERE (%s) AND ROWNUM=1", tableName, condSQL)
-				} else {
+				default:
 					sqlStr = fmt.Sprintf("SELECT * FROM %s WHERE %s LIMIT 1", tableName, condSQL)
 				}
 				args = condArgs
 			} else {
-				if session.engine.dialect.DBType() == core.MSSQL {
+				switch session.engine.Capabilities().Limit {
+				case LimitTop:
 					sqlStr = fmt.Sprintf("SELECT TOP 1 * FROM %s", tableName)
-				} else if session.engine.dialect.DBType() == core.ORACLE {
+				case LimitRowNum:
 					sqlStr = fmt.Sprintf("SELECT * FROM  %s WHERE ROWNUM=1", tableName)
-				} else {
+				default:
 					sqlStr = fmt.Sprintf("SELECT * FROM %s LIMIT 1", tableName)
 				}
 				args = []interface{}{}
@@ -90,7 +92,7 @@ func (session *Session) Exist(bean ...interface{}) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	defer rows.Close()
+	defer session.closeRows(rows)
 
 	return rows.Next(), nil
 }
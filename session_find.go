@@ -161,6 +161,7 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 		}
 
 		session.statement.cond = session.statement.cond.And(autoCond)
+		session.statement.mergePolicyCond()
 		condSQL, condArgs, err := builder.ToSQL(session.statement.cond)
 		if err != nil {
 			return err
@@ -202,7 +203,7 @@ func (session *Session) noCacheFind(table *core.Table, containerValue reflect.Va
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
+	defer session.closeRows(rows)
 
 	fields, err := rows.Columns()
 	if err != nil {
@@ -349,7 +350,7 @@ func (session *Session) cacheFind(t reflect.Type, sqlStr string, rowsSlicePtr in
 		if err != nil {
 			return err
 		}
-		defer rows.Close()
+		defer session.closeRows(rows)
 
 		var i int
 		ids = make([]core.PK, 0)
@@ -375,13 +376,13 @@ func (session *Session) cacheFind(t reflect.Type, sqlStr string, rowsSlicePtr in
 			ids = append(ids, pk)
 		}
 
-		session.engine.logger.Debug("[cacheFind] cache sql:", ids, tableName, sqlStr, newsql, args)
+		session.engine.logger.Debug("[cacheFind] cache sql:", ids, tableName, sqlStr, newsql, session.engine.redactArgs(sqlStr, args))
 		err = core.PutCacheSql(cacher, ids, tableName, newsql, args)
 		if err != nil {
 			return err
 		}
 	} else {
-		session.engine.logger.Debug("[cacheFind] cache hit sql:", tableName, sqlStr, newsql, args)
+		session.engine.logger.Debug("[cacheFind] cache hit sql:", tableName, sqlStr, newsql, session.engine.redactArgs(sqlStr, args))
 	}
 
 	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
@@ -801,3 +801,53 @@ func TestFindJoin(t *testing.T) {
 		Where("scene_item.type=?", 3).Or("device_user_privrels.user_id=?", 339).Find(&scenes)
 	assert.NoError(t, err)
 }
+
+func TestFindLazyColumns(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type LazyArticle struct {
+		Id    int64
+		Title string
+		Body  string `xorm:"text lazy"`
+	}
+
+	assertSync(t, new(LazyArticle))
+
+	var articles = []LazyArticle{
+		{Title: "a", Body: "body a"},
+		{Title: "b", Body: "body b"},
+		{Title: "c", Body: "body c"},
+	}
+	cnt, err := testEngine.Insert(&articles)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+
+	var results []*LazyArticle
+	assert.NoError(t, testEngine.Asc("id").Find(&results))
+	assert.EqualValues(t, 3, len(results))
+	for _, article := range results {
+		assert.EqualValues(t, "", article.Body)
+	}
+
+	assert.NoError(t, testEngine.Load(&results, "Body"))
+	for _, article := range results {
+		assert.EqualValues(t, "body "+article.Title, article.Body)
+	}
+
+	var article LazyArticle
+	has, err := testEngine.Where("title = ?", "b").Get(&article)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "", article.Body)
+
+	assert.NoError(t, testEngine.Load(&article))
+	assert.EqualValues(t, "body b", article.Body)
+
+	var article2 LazyArticle
+	has, err = testEngine.Cols("id", "body").Where("title = ?", "c").Get(&article2)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "body c", article2.Body)
+
+	assert.Error(t, testEngine.Load(&article, "NotExist"))
+}
@@ -104,7 +104,7 @@ func (session *Session) nocacheGet(beanKind reflect.Kind, table *core.Table, bea
 	if err != nil {
 		return false, err
 	}
-	defer rows.Close()
+	defer session.closeRows(rows)
 
 	if !rows.Next() {
 		if rows.Err() != nil {
@@ -281,7 +281,7 @@ func (session *Session) cacheGet(bean interface{}, sqlStr string, args ...interf
 	tableName := session.statement.TableName()
 	cacher := session.engine.getCacher(tableName)
 
-	session.engine.logger.Debug("[cacheGet] find sql:", newsql, args)
+	session.engine.logger.Debug("[cacheGet] find sql:", newsql, session.engine.redactArgs(newsql, args))
 	table := session.statement.RefTable
 	ids, err := core.GetCacheSql(cacher, tableName, newsql, args)
 	if err != nil {
@@ -290,7 +290,7 @@ func (session *Session) cacheGet(bean interface{}, sqlStr string, args ...interf
 		if err != nil {
 			return false, err
 		}
-		defer rows.Close()
+		defer session.closeRows(rows)
 
 		if rows.Next() {
 			err = rows.ScanSlice(&res)
@@ -0,0 +1,309 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"xorm.io/core"
+)
+
+var (
+	// ErrGraphNeedsSinglePK the parent of the children should have a single primary key
+	ErrGraphNeedsSinglePK = errors.New("object graph needs a single primary key on the parent")
+)
+
+// graphDBCascade is the option of the children tag when the foreign key of
+// the children is declared with ON DELETE CASCADE
+const graphDBCascade = "dbcascade"
+
+// ChildrenTagHandler describes children tag handler, children(order_id)
+// marks the field as the children whose order_id column refers to the
+// primary key of the bean, the field is not a column. The children are
+// saved by InsertGraph and deleted by DeleteGraph, children(order_id,dbcascade)
+// means the database deletes them by the foreign key.
+func ChildrenTagHandler(ctx *tagContext) error {
+	if len(ctx.params) == 0 || len(ctx.params) > 2 {
+		return fmt.Errorf("field %s tag children needs the foreign key column", ctx.col.FieldName)
+	}
+	if len(ctx.params) == 2 && !strings.EqualFold(strings.TrimSpace(ctx.params[1]), graphDBCascade) {
+		return fmt.Errorf("field %s tag children has unknown option %s", ctx.col.FieldName, ctx.params[1])
+	}
+
+	elemType := ctx.fieldValue.Type()
+	if elemType.Kind() == reflect.Slice {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("field %s tag children needs a struct or a slice of structs", ctx.col.FieldName)
+	}
+
+	ctx.children = &graphRelation{
+		elemType:  elemType,
+		fkColumn:  strings.TrimSpace(ctx.params[0]),
+		dbCascade: len(ctx.params) == 2,
+	}
+	return nil
+}
+
+// graphRelation is a field of the children of a struct
+type graphRelation struct {
+	fieldIndex int
+	elemType   reflect.Type // the struct type of the children
+	fkColumn   string
+	dbCascade  bool
+}
+
+// graphRelations returns the children fields of the struct type, they are
+// recorded by ChildrenTagHandler when the type is mapped
+func (engine *Engine) graphRelations(t reflect.Type) ([]*graphRelation, error) {
+	if _, err := engine.autoMapType(reflect.New(t).Elem()); err != nil {
+		return nil, err
+	}
+	relations, ok := engine.graphs.Load(t)
+	if !ok {
+		return nil, nil
+	}
+	return relations.([]*graphRelation), nil
+}
+
+// graphChildren returns the addressable children structs of the field
+func graphChildren(field reflect.Value) []reflect.Value {
+	var children []reflect.Value
+	var add = func(v reflect.Value) {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		children = append(children, v)
+	}
+
+	if field.Kind() == reflect.Slice {
+		for i := 0; i < field.Len(); i++ {
+			add(field.Index(i))
+		}
+	} else {
+		add(field)
+	}
+	return children
+}
+
+// graphPK returns the single primary key of the struct
+func (engine *Engine) graphPK(v reflect.Value) (interface{}, error) {
+	table, err := engine.autoMapType(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.PrimaryKeys) != 1 {
+		return nil, ErrGraphNeedsSinglePK
+	}
+	pk, err := engine.idOfV(v)
+	if err != nil {
+		return nil, err
+	}
+	return pk[0], nil
+}
+
+// setForeignKey sets the foreign key field of the child to the parent's primary key
+func (engine *Engine) setForeignKey(child reflect.Value, fkColumn string, pk interface{}) error {
+	table, err := engine.autoMapType(child)
+	if err != nil {
+		return err
+	}
+	col := table.GetColumn(fkColumn)
+	if col == nil {
+		return ErrFieldIsNotExist{fkColumn, table.Name}
+	}
+	field, err := col.ValueOfV(&child)
+	if err != nil {
+		return err
+	}
+
+	var fieldType = field.Type()
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	pkValue := reflect.ValueOf(pk)
+	if !pkValue.Type().ConvertibleTo(fieldType) {
+		return fmt.Errorf("foreign key %s of %s could not be set to %T", fkColumn, table.Name, pk)
+	}
+	pkValue = pkValue.Convert(fieldType)
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(fieldType)
+		ptr.Elem().Set(pkValue)
+		pkValue = ptr
+	}
+	field.Set(pkValue)
+	return nil
+}
+
+// graphBean returns the addressable struct of the bean
+func graphBean(bean interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(bean)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("needs a pointer to a struct")
+	}
+	return v.Elem(), nil
+}
+
+// InsertGraph inserts the bean and its children tagged with children in a
+// transaction. The generated primary key of the parent is set to the foreign
+// key of the children before they are inserted, the children without their
+// own children are inserted by InsertMulti.
+//
+//	type Order struct {
+//		Id    int64
+//		Items []OrderItem `xorm:"children(order_id)"`
+//	}
+//	affected, err := engine.InsertGraph(&order)
+func (session *Session) InsertGraph(bean interface{}) (int64, error) {
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+
+	v, err := graphBean(bean)
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int64
+	err = session.inTx(func() error {
+		affected, err = session.insertGraph(v)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+func (session *Session) insertGraph(v reflect.Value) (int64, error) {
+	affected, err := session.Insert(v.Addr().Interface())
+	if err != nil {
+		return 0, err
+	}
+
+	relations, err := session.engine.graphRelations(v.Type())
+	if err != nil || len(relations) == 0 {
+		return affected, err
+	}
+	pk, err := session.engine.graphPK(v)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, relation := range relations {
+		children := graphChildren(v.Field(relation.fieldIndex))
+		if len(children) == 0 {
+			continue
+		}
+		for _, child := range children {
+			if err := session.engine.setForeignKey(child, relation.fkColumn, pk); err != nil {
+				return 0, err
+			}
+		}
+
+		grandchildren, err := session.engine.graphRelations(relation.elemType)
+		if err != nil {
+			return 0, err
+		}
+		if len(grandchildren) > 0 {
+			// the generated primary keys are needed by the grandchildren
+			for _, child := range children {
+				cnt, err := session.insertGraph(child)
+				if err != nil {
+					return 0, err
+				}
+				affected += cnt
+			}
+			continue
+		}
+
+		var rows = reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(relation.elemType)), 0, len(children))
+		for _, child := range children {
+			rows = reflect.Append(rows, child.Addr())
+		}
+		cnt, err := session.InsertMulti(rows.Interface())
+		if err != nil {
+			return 0, err
+		}
+		affected += cnt
+	}
+	return affected, nil
+}
+
+// DeleteGraph deletes the bean and its children tagged with children in a
+// transaction. The children are loaded and deleted one by one before their
+// parent so the delete processors are called, except the ones tagged with
+// children(fk,dbcascade) which are deleted by the database.
+func (session *Session) DeleteGraph(bean interface{}) (int64, error) {
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+
+	v, err := graphBean(bean)
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int64
+	err = session.inTx(func() error {
+		affected, err = session.deleteGraph(v)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+func (session *Session) deleteGraph(v reflect.Value) (int64, error) {
+	pk, err := session.engine.graphPK(v)
+	if err != nil {
+		return 0, err
+	}
+
+	relations, err := session.engine.graphRelations(v.Type())
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int64
+	for _, relation := range relations {
+		if relation.dbCascade {
+			continue
+		}
+
+		children := reflect.New(reflect.SliceOf(reflect.PtrTo(relation.elemType)))
+		err := session.Where(session.engine.Quote(relation.fkColumn)+" = ?", pk).Find(children.Interface())
+		if err != nil {
+			return 0, err
+		}
+		children = children.Elem()
+		for i := 0; i < children.Len(); i++ {
+			cnt, err := session.deleteGraph(children.Index(i).Elem())
+			if err != nil {
+				return 0, err
+			}
+			affected += cnt
+		}
+	}
+
+	cnt, err := session.ID(core.PK{pk}).NoAutoCondition().Delete(v.Addr().Interface())
+	if err != nil {
+		return 0, err
+	}
+	return affected + cnt, nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type GraphOrder struct {
+	Id       int64
+	Customer string
+	Items    []GraphOrderItem `xorm:"children(order_id)"`
+	Shipment *GraphShipment   `xorm:"children(order_id)"`
+}
+
+type GraphOrderItem struct {
+	Id      int64
+	OrderId int64 `xorm:"index"`
+	Name    string
+	Notes   []*GraphItemNote `xorm:"children(item_id)"`
+}
+
+type GraphItemNote struct {
+	Id     int64
+	ItemId *int64 `xorm:"index"`
+	Note   string
+}
+
+var graphDeleted []string
+
+func (note *GraphItemNote) BeforeDelete() {
+	graphDeleted = append(graphDeleted, note.Note)
+}
+
+type GraphShipment struct {
+	Id       int64
+	OrderId  int32
+	Address  string
+	Inserted bool `xorm:"-"`
+}
+
+func (shipment *GraphShipment) BeforeInsert() {
+	shipment.Inserted = true
+}
+
+func TestInsertGraph(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(GraphOrder), new(GraphOrderItem), new(GraphItemNote), new(GraphShipment))
+
+	colSeq, _, err := testEngine.Dialect().GetColumns(testEngine.TableName(new(GraphOrder), true))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(colSeq))
+
+	var order = GraphOrder{
+		Customer: "a",
+		Items: []GraphOrderItem{
+			{Name: "apple", Notes: []*GraphItemNote{{Note: "red"}, {Note: "fresh"}}},
+			{Name: "pear"},
+		},
+		Shipment: &GraphShipment{Address: "street"},
+	}
+	affected, err := testEngine.InsertGraph(&order)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, affected)
+	assert.True(t, order.Id > 0)
+	assert.True(t, order.Items[0].Id > 0)
+	assert.EqualValues(t, order.Id, order.Items[0].OrderId)
+	assert.EqualValues(t, order.Id, order.Items[1].OrderId)
+	assert.EqualValues(t, order.Items[0].Id, *order.Items[0].Notes[1].ItemId)
+	assert.EqualValues(t, order.Id, order.Shipment.OrderId)
+	assert.True(t, order.Shipment.Inserted)
+
+	var items []GraphOrderItem
+	assert.NoError(t, testEngine.Where("order_id = ?", order.Id).Asc("id").Find(&items))
+	assert.EqualValues(t, 2, len(items))
+	assert.EqualValues(t, "pear", items[1].Name)
+
+	cnt, err := testEngine.Where("item_id = ?", order.Items[0].Id).Count(new(GraphItemNote))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// the graph is rolled back if any child fails
+	assert.NoError(t, testEngine.DropTables(new(GraphItemNote)))
+	var failed = GraphOrder{
+		Customer: "b",
+		Items:    []GraphOrderItem{{Name: "plum", Notes: []*GraphItemNote{{Note: "sweet"}}}},
+	}
+	_, err = testEngine.InsertGraph(&failed)
+	assert.Error(t, err)
+	cnt, err = testEngine.Where("customer = ?", "b").Count(new(GraphOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+	cnt, err = testEngine.Where("name = ?", "plum").Count(new(GraphOrderItem))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	_, err = testEngine.InsertGraph(order)
+	assert.Error(t, err)
+}
+
+func TestDeleteGraph(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(GraphOrder), new(GraphOrderItem), new(GraphItemNote), new(GraphShipment))
+
+	var orders = []GraphOrder{
+		{
+			Customer: "a",
+			Items: []GraphOrderItem{
+				{Name: "apple", Notes: []*GraphItemNote{{Note: "red"}, {Note: "fresh"}}},
+				{Name: "pear"},
+			},
+			Shipment: &GraphShipment{Address: "street"},
+		},
+		{
+			Customer: "b",
+			Items:    []GraphOrderItem{{Name: "plum", Notes: []*GraphItemNote{{Note: "sweet"}}}},
+		},
+	}
+	for i := range orders {
+		_, err := testEngine.InsertGraph(&orders[i])
+		assert.NoError(t, err)
+	}
+
+	graphDeleted = nil
+	var order = GraphOrder{Id: orders[0].Id}
+	affected, err := testEngine.DeleteGraph(&order)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, affected)
+	assert.EqualValues(t, []string{"red", "fresh"}, graphDeleted)
+
+	for _, c := range []struct {
+		bean     interface{}
+		expected int64
+	}{
+		{new(GraphOrder), 1},
+		{new(GraphOrderItem), 1},
+		{new(GraphItemNote), 1},
+		{new(GraphShipment), 0},
+	} {
+		cnt, err := testEngine.Count(c.bean)
+		assert.NoError(t, err)
+		assert.EqualValues(t, c.expected, cnt)
+	}
+}
+
+type GraphCascadeParent struct {
+	Id       int64
+	Children []GraphCascadeChild `xorm:"children(parent_id,dbcascade)"`
+}
+
+type GraphCascadeChild struct {
+	Id       int64
+	ParentId int64
+}
+
+func TestDeleteGraphDBCascade(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(GraphCascadeParent), new(GraphCascadeChild))
+
+	var parent = GraphCascadeParent{Children: []GraphCascadeChild{{}, {}}}
+	_, err := testEngine.InsertGraph(&parent)
+	assert.NoError(t, err)
+
+	// the children are left to the foreign key of the database
+	affected, err := testEngine.DeleteGraph(&parent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	cnt, err := testEngine.Count(new(GraphCascadeChild))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+}
+
+func TestGraphRelations(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	engine := testEngine.(*Engine)
+
+	relations, err := engine.graphRelations(reflect.TypeOf(GraphOrder{}))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(relations))
+	assert.EqualValues(t, "order_id", relations[0].fkColumn)
+	assert.EqualValues(t, reflect.TypeOf(GraphOrderItem{}), relations[0].elemType)
+	assert.EqualValues(t, reflect.TypeOf(GraphShipment{}), relations[1].elemType)
+
+	relations, err = engine.graphRelations(reflect.TypeOf(GraphShipment{}))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(relations))
+
+	type GraphBadChildren struct {
+		Id       int64
+		Children []int64 `xorm:"children(parent_id)"`
+	}
+	_, err = engine.graphRelations(reflect.TypeOf(GraphBadChildren{}))
+	assert.Error(t, err)
+}
@@ -68,7 +68,7 @@ func (session *Session) Insert(beans ...interface{}) (int64, error) {
 			if sliceValue.Kind() == reflect.Slice {
 				size := sliceValue.Len()
 				if size > 0 {
-					if session.engine.SupportInsertMany() {
+					if session.engine.SupportInsertMany() && !session.needInsertOneByOne(bean) {
 						cnt, err := session.innerInsertMulti(bean)
 						if err != nil {
 							return affected, err
@@ -76,7 +76,7 @@ func (session *Session) Insert(beans ...interface{}) (int64, error) {
 						affected += cnt
 					} else {
 						for i := 0; i < size; i++ {
-							cnt, err := session.innerInsert(sliceValue.Index(i).Interface())
+							cnt, err := session.insertOne(sliceValue.Index(i).Interface())
 							if err != nil {
 								return affected, err
 							}
@@ -85,7 +85,7 @@ func (session *Session) Insert(beans ...interface{}) (int64, error) {
 					}
 				}
 			} else {
-				cnt, err := session.innerInsert(bean)
+				cnt, err := session.insertOne(bean)
 				if err != nil {
 					return affected, err
 				}
@@ -118,6 +118,7 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 
 	table := session.statement.RefTable
 	size := sliceValue.Len()
+	writeCheck := session.statement.writePolicy()
 
 	var colNames []string
 	var colMultiPlaces []string
@@ -141,6 +142,12 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 		}
 		// --
 
+		if writeCheck != nil {
+			if err := writeCheck(elemValue); err != nil {
+				return 0, err
+			}
+		}
+
 		if i == 0 {
 			for _, col := range table.Columns() {
 				ptrFieldValue, err := col.ValueOfV(&vv)
@@ -246,7 +253,7 @@ func (session *Session) innerInsertMulti(rowsSlicePtr interface{}) (int64, error
 	cleanupProcessorsClosures(&session.beforeClosures)
 
 	var sql string
-	if session.engine.dialect.DBType() == core.ORACLE {
+	if session.engine.Capabilities().MultiInsert == MultiInsertAll {
 		temp := fmt.Sprintf(") INTO %s (%v) VALUES (",
 			session.engine.Quote(tableName),
 			quoteColumns(colNames, session.engine.Quote, ","))
@@ -317,6 +324,10 @@ func (session *Session) InsertMulti(rowsSlicePtr interface{}) (int64, error) {
 		return 0, nil
 	}
 
+	if session.needInsertOneByOne(rowsSlicePtr) {
+		return session.Insert(rowsSlicePtr)
+	}
+
 	return session.innerInsertMulti(rowsSlicePtr)
 }
 
@@ -340,6 +351,12 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 		processor.BeforeInsert()
 	}
 
+	if writeCheck := session.statement.writePolicy(); writeCheck != nil {
+		if err := writeCheck(bean); err != nil {
+			return 0, err
+		}
+	}
+
 	colNames, args, err := session.genInsertColumns(bean)
 	if err != nil {
 		return 0, err
@@ -352,8 +369,9 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 	}
 
 	var tableName = session.statement.TableName()
+	var identity = session.engine.Capabilities().Identity
 	var output string
-	if session.engine.dialect.DBType() == core.MSSQL && len(table.AutoIncrement) > 0 {
+	if identity == IdentityOutput && len(table.AutoIncrement) > 0 {
 		output = fmt.Sprintf(" OUTPUT Inserted.%s", table.AutoIncrement)
 	}
 
@@ -363,12 +381,12 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 	}
 
 	if len(colPlaces) <= 0 {
-		if session.engine.dialect.DBType() == core.MYSQL {
-			if _, err := buf.WriteString(" VALUES ()"); err != nil {
+		if session.engine.Capabilities().DefaultValues {
+			if _, err := buf.WriteString(fmt.Sprintf("%s DEFAULT VALUES", output)); err != nil {
 				return 0, err
 			}
 		} else {
-			if _, err := buf.WriteString(fmt.Sprintf("%s DEFAULT VALUES", output)); err != nil {
+			if _, err := buf.WriteString(" VALUES ()"); err != nil {
 				return 0, err
 			}
 		}
@@ -425,7 +443,7 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 		}
 	}
 
-	if len(table.AutoIncrement) > 0 && session.engine.dialect.DBType() == core.POSTGRES {
+	if len(table.AutoIncrement) > 0 && identity == IdentityReturning {
 		if _, err := buf.WriteString(" RETURNING " + session.engine.Quote(table.AutoIncrement)); err != nil {
 			return 0, err
 		}
@@ -464,9 +482,8 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 
 	// for postgres, many of them didn't implement lastInsertId, so we should
 	// implemented it ourself.
-	if session.engine.dialect.DBType() == core.ORACLE && len(table.AutoIncrement) > 0 {
-		res, err := session.queryBytes("select seq_atable.currval from dual", args...)
-		if err != nil {
+	if identity == IdentitySequence && len(table.AutoIncrement) > 0 {
+		if _, err := session.exec(sqlStr, args...); err != nil {
 			return 0, err
 		}
 
@@ -483,12 +500,7 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 			}
 		}
 
-		if len(res) < 1 {
-			return 0, errors.New("insert no error but not returned id")
-		}
-
-		idByte := res[0][table.AutoIncrement]
-		id, err := strconv.ParseInt(string(idByte), 10, 64)
+		id, err := session.sequenceID(tableName, table.AutoIncrement)
 		if err != nil || id <= 0 {
 			return 1, err
 		}
@@ -505,7 +517,7 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 		aiValue.Set(int64ToIntValue(id, aiValue.Type()))
 
 		return 1, nil
-	} else if len(table.AutoIncrement) > 0 && (session.engine.dialect.DBType() == core.POSTGRES || session.engine.dialect.DBType() == core.MSSQL) {
+	} else if len(table.AutoIncrement) > 0 && (identity == IdentityReturning || identity == IdentityOutput) {
 		res, err := session.queryBytes(sqlStr, args...)
 
 		if err != nil {
@@ -590,6 +602,73 @@ func (session *Session) innerInsert(bean interface{}) (int64, error) {
 	}
 }
 
+// needInsertOneByOne returns true if the beans have io.Reader fields or are
+// tree nodes, which need the primary keys after inserted
+func (session *Session) needInsertOneByOne(beans interface{}) bool {
+	sliceValue := reflect.Indirect(reflect.ValueOf(beans))
+	return session.needAfterInsert(sliceValue.Type().Elem())
+}
+
+// needAfterInsert returns true if the beans of the type have io.Reader fields
+// or are tree nodes, which are written after the record inserted
+func (session *Session) needAfterInsert(beanType reflect.Type) bool {
+	if beanType.Kind() == reflect.Ptr {
+		beanType = beanType.Elem()
+	}
+	if beanType.Kind() != reflect.Struct {
+		return false
+	}
+	table, err := session.engine.autoMapType(reflect.New(beanType).Elem())
+	if err != nil {
+		return false
+	}
+	return session.engine.hasStreamColumn(table) || session.engine.treeInfo(table) != nil
+}
+
+// insertOne inserts the bean, its io.Reader fields and tree are written in
+// the same transaction as the record so that a failure leaves nothing behind
+func (session *Session) insertOne(bean interface{}) (int64, error) {
+	if !session.needAfterInsert(reflect.TypeOf(bean)) {
+		return session.innerInsert(bean)
+	}
+
+	var cnt int64
+	err := session.inTx(func() error {
+		var err error
+		if cnt, err = session.innerInsert(bean); err != nil {
+			return err
+		}
+		return session.afterInnerInsert(bean)
+	})
+	return cnt, err
+}
+
+// afterInnerInsert writes the io.Reader fields and maintains the tree of
+// the inserted bean
+func (session *Session) afterInnerInsert(bean interface{}) error {
+	if err := session.insertBlobStreams(bean); err != nil {
+		return err
+	}
+	return session.treeAfterInsert(bean)
+}
+
+// insertBlobStreams writes the io.Reader fields of the inserted bean
+func (session *Session) insertBlobStreams(bean interface{}) error {
+	table := session.statement.RefTable
+	if table == nil || !session.engine.hasStreamColumn(table) {
+		return nil
+	}
+	streams, err := session.blobStreams(table, bean)
+	if err != nil || len(streams) == 0 {
+		return err
+	}
+	pk, err := session.engine.idOfV(reflect.ValueOf(bean))
+	if err != nil {
+		return err
+	}
+	return session.writeBlobStreams(session.statement.TableName(), table, pk, streams)
+}
+
 // InsertOne insert only one struct into database as a record.
 // The in parameter bean must a struct or a point to struct. The return
 // parameter is inserted and error
@@ -598,7 +677,13 @@ func (session *Session) InsertOne(bean interface{}) (int64, error) {
 		defer session.Close()
 	}
 
-	return session.innerInsert(bean)
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement = true
+		session.resetStatement()
+	}()
+
+	return session.insertOne(bean)
 }
 
 func (session *Session) cacheInsert(table string) error {
@@ -715,6 +800,12 @@ func (session *Session) insertMapInterface(m map[string]interface{}) (int64, err
 		return 0, ErrTableNotFound
 	}
 
+	if writeCheck := session.statement.writePolicy(); writeCheck != nil {
+		if err := writeCheck(m); err != nil {
+			return 0, err
+		}
+	}
+
 	var columns = make([]string, 0, len(m))
 	exprs := session.statement.exprColumns
 	for k := range m {
@@ -801,6 +892,12 @@ func (session *Session) insertMapString(m map[string]string) (int64, error) {
 		return 0, ErrTableNotFound
 	}
 
+	if writeCheck := session.statement.writePolicy(); writeCheck != nil {
+		if err := writeCheck(m); err != nil {
+			return 0, err
+		}
+	}
+
 	var columns = make([]string, 0, len(m))
 	exprs := session.statement.exprColumns
 	for k := range m {
@@ -5,8 +5,11 @@
 package xorm
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"reflect"
 	"testing"
 	"time"
@@ -1009,3 +1012,87 @@ func TestInsertMultiWithOmit(t *testing.T) {
 	assert.EqualValues(t, 3, num)
 	check()
 }
+
+func TestInsertBlobStream(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type BlobStream struct {
+		Id   int64
+		Name string
+		Data io.Reader
+	}
+
+	assertSync(t, new(BlobStream))
+
+	testEngine.SetBlobChunkSize(7)
+	defer testEngine.SetBlobChunkSize(0)
+
+	var content = []byte("a large attachment\x00with binary content\xff")
+	var bs = BlobStream{
+		Name: "attachment",
+		Data: bytes.NewReader(content),
+	}
+	cnt, err := testEngine.Insert(&bs)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.True(t, bs.Id > 0)
+
+	var bs2 BlobStream
+	has, err := testEngine.ID(bs.Id).Get(&bs2)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.Nil(t, bs2.Data)
+
+	r, err := testEngine.OpenBlob(&bs2, "Data")
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.EqualValues(t, content, data)
+
+	// the text columns could not be read as blobs
+	_, err = testEngine.OpenBlob(&bs2, "Name")
+	assert.Error(t, err)
+
+	content = bytes.Repeat([]byte("0123456789"), 3)
+	cnt, err = testEngine.ID(bs.Id).Update(&BlobStream{Data: bytes.NewReader(content)})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	assert.NoError(t, testEngine.Load(&bs2, "Data"))
+	assert.NotNil(t, bs2.Data)
+	data, err = ioutil.ReadAll(bs2.Data)
+	assert.NoError(t, err)
+	assert.EqualValues(t, content, data)
+
+	_, err = testEngine.Update(&BlobStream{Data: bytes.NewReader(content)})
+	assert.Error(t, err)
+
+	// nothing is written if the reader fails after some chunks are written
+	var errRead = errors.New("read failed")
+	_, err = testEngine.ID(bs.Id).Update(&BlobStream{
+		Data: io.MultiReader(bytes.NewReader(content[:20]), &blobFailingReader{errRead}),
+	})
+	assert.EqualValues(t, errRead, err)
+	assert.NoError(t, testEngine.Load(&bs2, "Data"))
+	data, err = ioutil.ReadAll(bs2.Data)
+	assert.NoError(t, err)
+	assert.EqualValues(t, content, data)
+
+	_, err = testEngine.Insert(&BlobStream{
+		Name: "broken",
+		Data: io.MultiReader(bytes.NewReader(content), &blobFailingReader{errRead}),
+	})
+	assert.EqualValues(t, errRead, err)
+	has, err = testEngine.Exist(&BlobStream{Name: "broken"})
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
+
+type blobFailingReader struct {
+	err error
+}
+
+func (r *blobFailingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -4,7 +4,11 @@
 
 package xorm
 
-import "reflect"
+import (
+	"context"
+	"reflect"
+	"sync"
+)
 
 // IterFunc only use by Iterate
 type IterFunc func(idx int, bean interface{}) error
@@ -64,6 +68,21 @@ func (session *Session) bufferIterate(bean interface{}, fun IterFunc) error {
 		defer session.Close()
 	}
 
+	var idx = 0
+	return session.iteratePages(bean, func(page reflect.Value) error {
+		for i := 0; i < page.Len(); i++ {
+			if err := fun(idx, page.Index(i).Addr().Interface()); err != nil {
+				return err
+			}
+			idx++
+		}
+		return nil
+	})
+}
+
+// iteratePages fetches the records page by page with the buffer size and
+// calls fun with every page which is a slice value of bean's type
+func (session *Session) iteratePages(bean interface{}, fun func(page reflect.Value) error) error {
 	var bufferSize = session.statement.bufferSize
 	var limit = session.statement.LimitN
 	if limit > 0 && bufferSize > limit {
@@ -79,12 +98,10 @@ func (session *Session) bufferIterate(bean interface{}, fun IterFunc) error {
 			return err
 		}
 
-		for i := 0; i < slice.Elem().Len(); i++ {
-			if err := fun(idx, slice.Elem().Index(i).Addr().Interface()); err != nil {
-				return err
-			}
-			idx++
+		if err := fun(slice.Elem()); err != nil {
+			return err
 		}
+		idx += slice.Elem().Len()
 
 		start = start + slice.Elem().Len()
 		if limit > 0 && idx+bufferSize > limit {
@@ -98,3 +115,243 @@ func (session *Session) bufferIterate(bean interface{}, fun IterFunc) error {
 
 	return nil
 }
+
+// defaultParallelBufferSize is the buffer size of IterateParallel if BufferSize is not set
+const defaultParallelBufferSize = 100
+
+// IterateParallel iterates the records like Iterate but calls fun from
+// workers goroutines. The next buffer is fetched while the workers are
+// handling the current one, so at most about two buffers of beans are kept
+// in memory. The iteration stops on the first error returned by fun or emit
+// and the context of the session is canceled to stop the fetching. fun must
+// be safe for concurrent use.
+//
+// If emit is given, the beans are delivered in order: the buffers are split
+// into batches with sequence numbers, the workers call fun for the beans of
+// a batch, then emit is called for them in the order of the records from a
+// single goroutine once the previous batches are emitted.
+//
+//	err := engine.BufferSize(100).IterateParallel(new(Order), 8, func(i int, bean interface{}) error {
+//		return render(bean.(*Order)) // concurrently
+//	}, func(i int, bean interface{}) error {
+//		return write(w, bean.(*Order)) // in order
+//	})
+func (session *Session) IterateParallel(bean interface{}, workers int, fun IterFunc, emit ...IterFunc) error {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	if session.statement.lastError != nil {
+		return session.statement.lastError
+	}
+
+	if workers <= 0 {
+		workers = 1
+	}
+	if session.statement.bufferSize <= 0 {
+		session.statement.bufferSize = defaultParallelBufferSize
+	}
+	// the statement is reset by the fetching goroutine
+	var bufferSize = session.statement.bufferSize
+
+	var parent = session.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	session.ctx = ctx
+	defer func() {
+		session.ctx = parent
+	}()
+
+	var (
+		errOnce  sync.Once
+		firstErr error
+	)
+	var setErr = func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	// the fetching goroutine fetches the next page while the current one is
+	// dispatched, an invalid value is sent at the end
+	var pages = make(chan reflect.Value)
+	var fetchErr error
+	go func() {
+		fetchErr = session.iteratePages(bean, func(page reflect.Value) error {
+			select {
+			case pages <- page:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		pages <- reflect.Value{}
+	}()
+
+	var dispatch func(page reflect.Value, idx int)
+	var wait func()
+	if len(emit) > 0 && emit[0] != nil {
+		dispatch, wait = orderedIterate(ctx, workers, bufferSize, fun, emit[0], setErr)
+	} else {
+		dispatch, wait = parallelIterate(ctx, workers, fun, setErr)
+	}
+
+	var idx = 0
+	for {
+		page := <-pages
+		if !page.IsValid() {
+			break
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+		dispatch(page, idx)
+		idx += page.Len()
+	}
+	wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return fetchErr
+}
+
+// parallelIterate starts the workers calling fun for the beans of the
+// dispatched pages in any order, wait stops the workers. An item with nil
+// bean stops a worker.
+func parallelIterate(ctx context.Context, workers int, fun IterFunc, setErr func(error)) (dispatch func(reflect.Value, int), wait func()) {
+	type iterItem struct {
+		idx  int
+		bean interface{}
+	}
+	var items = make(chan iterItem)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				if item.bean == nil {
+					return
+				}
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := fun(item.idx, item.bean); err != nil {
+					setErr(err)
+				}
+			}
+		}()
+	}
+
+	dispatch = func(page reflect.Value, idx int) {
+		for i := 0; i < page.Len() && ctx.Err() == nil; i++ {
+			select {
+			case items <- iterItem{idx + i, page.Index(i).Addr().Interface()}:
+			case <-ctx.Done():
+			}
+		}
+	}
+	wait = func() {
+		for i := 0; i < workers; i++ {
+			items <- iterItem{}
+		}
+		wg.Wait()
+	}
+	return dispatch, wait
+}
+
+// orderedIterate starts the workers calling fun for the batches of the
+// dispatched pages and the emitter calling emit for the handled batches by
+// their sequence numbers, wait stops them. At most two batches per worker
+// are dispatched but not emitted. A batch with a negative sequence number
+// stops a worker or the emitter.
+func orderedIterate(ctx context.Context, workers, bufferSize int, fun, emit IterFunc, setErr func(error)) (dispatch func(reflect.Value, int), wait func()) {
+	type iterBatch struct {
+		seq   int
+		idx   int
+		beans reflect.Value
+	}
+	var batchSize = (bufferSize + workers - 1) / workers
+	var inflight = make(chan struct{}, 2*workers)
+	var batches = make(chan iterBatch)
+	var handled = make(chan iterBatch, 2*workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if batch.seq < 0 {
+					return
+				}
+				for j := 0; j < batch.beans.Len() && ctx.Err() == nil; j++ {
+					if err := fun(batch.idx+j, batch.beans.Index(j).Addr().Interface()); err != nil {
+						setErr(err)
+					}
+				}
+				handled <- batch
+			}
+		}()
+	}
+
+	// the emitter keeps the batches handled before their previous ones
+	var emitter sync.WaitGroup
+	emitter.Add(1)
+	go func() {
+		defer emitter.Done()
+		var pending = make(map[int]iterBatch)
+		var next = 0
+		for batch := range handled {
+			if batch.seq < 0 {
+				return
+			}
+			pending[batch.seq] = batch
+			for {
+				batch, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				for j := 0; j < batch.beans.Len() && ctx.Err() == nil; j++ {
+					if err := emit(batch.idx+j, batch.beans.Index(j).Addr().Interface()); err != nil {
+						setErr(err)
+					}
+				}
+				next++
+				<-inflight
+			}
+		}
+	}()
+
+	var seq = 0
+	dispatch = func(page reflect.Value, idx int) {
+		for start := 0; start < page.Len(); start += batchSize {
+			select {
+			case inflight <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			end := start + batchSize
+			if end > page.Len() {
+				end = page.Len()
+			}
+			batches <- iterBatch{seq, idx + start, page.Slice(start, end)}
+			seq++
+		}
+	}
+	wait = func() {
+		for i := 0; i < workers; i++ {
+			batches <- iterBatch{seq: -1}
+		}
+		wg.Wait()
+		handled <- iterBatch{seq: -1}
+		emitter.Wait()
+	}
+	return dispatch, wait
+}
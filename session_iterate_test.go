@@ -5,7 +5,11 @@
 package xorm
 
 import (
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -90,3 +94,87 @@ func TestBufferIterate(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 7, cnt)
 }
+
+func TestIterateParallel(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type UserIterateParallel struct {
+		Id    int64
+		IsMan bool
+	}
+
+	assert.NoError(t, testEngine.Sync2(new(UserIterateParallel)))
+
+	var size = 50
+	for i := 0; i < size; i++ {
+		cnt, err := testEngine.Insert(&UserIterateParallel{
+			IsMan: true,
+		})
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, cnt)
+	}
+
+	var lock sync.Mutex
+	var ids = make(map[int64]int)
+	err := testEngine.BufferSize(7).IterateParallel(new(UserIterateParallel), 4, func(i int, bean interface{}) error {
+		user := bean.(*UserIterateParallel)
+		lock.Lock()
+		ids[user.Id] = i
+		lock.Unlock()
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, size, len(ids))
+	for id, i := range ids {
+		assert.EqualValues(t, id, i+1)
+	}
+
+	// the handled beans are emitted in the order of the records
+	var handled = make(map[int64]bool)
+	var emitted []int64
+	err = testEngine.BufferSize(7).Asc("id").IterateParallel(new(UserIterateParallel), 3, func(i int, bean interface{}) error {
+		user := bean.(*UserIterateParallel)
+		// the later beans are handled faster
+		time.Sleep(time.Duration(size-i) * 20 * time.Microsecond)
+		lock.Lock()
+		handled[user.Id] = true
+		lock.Unlock()
+		return nil
+	}, func(i int, bean interface{}) error {
+		user := bean.(*UserIterateParallel)
+		lock.Lock()
+		assert.True(t, handled[user.Id])
+		lock.Unlock()
+		assert.EqualValues(t, i+1, user.Id)
+		emitted = append(emitted, user.Id)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, size, len(emitted))
+
+	// the error of emit stops the iteration
+	emitted = nil
+	err = testEngine.BufferSize(5).Asc("id").IterateParallel(new(UserIterateParallel), 2, func(i int, bean interface{}) error {
+		return nil
+	}, func(i int, bean interface{}) error {
+		emitted = append(emitted, bean.(*UserIterateParallel).Id)
+		if i == 12 {
+			return errors.New("emit")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "emit")
+	assert.EqualValues(t, 13, len(emitted))
+
+	var errStop = errors.New("stop")
+	var called int32
+	err = testEngine.BufferSize(5).IterateParallel(new(UserIterateParallel), 2, func(i int, bean interface{}) error {
+		atomic.AddInt32(&called, 1)
+		if i == 3 {
+			return errStop
+		}
+		return nil
+	})
+	assert.EqualValues(t, errStop, err)
+	assert.True(t, int(atomic.LoadInt32(&called)) < size)
+}
@@ -0,0 +1,190 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// loadBatchSize is the max number of beans which will be loaded by one query
+const loadBatchSize = 500
+
+// Load loads the columns of a bean or a slice of beans by their primary keys.
+// The columns could be the field names or the column names, if no column is
+// given all the columns tagged with lazy will be loaded.
+//
+//	var articles []Article
+//	err := engine.Find(&articles) // Body is lazy so it's not selected
+//	err = engine.Load(&articles, "Body")
+func (session *Session) Load(beans interface{}, cols ...string) error {
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+
+	if session.statement.lastError != nil {
+		return session.statement.lastError
+	}
+
+	var elems []reflect.Value
+	beansValue := reflect.Indirect(reflect.ValueOf(beans))
+	switch beansValue.Kind() {
+	case reflect.Struct:
+		if !beansValue.CanAddr() {
+			return errors.New("needs a pointer to a struct or a slice")
+		}
+		elems = append(elems, beansValue)
+	case reflect.Slice:
+		for i := 0; i < beansValue.Len(); i++ {
+			elem := beansValue.Index(i)
+			if elem.Kind() == reflect.Ptr {
+				if elem.IsNil() {
+					continue
+				}
+				elem = elem.Elem()
+			}
+			if elem.Kind() != reflect.Struct {
+				return errors.New("needs a pointer to a struct or a slice")
+			}
+			elems = append(elems, elem)
+		}
+	default:
+		return errors.New("needs a pointer to a struct or a slice")
+	}
+	if len(elems) == 0 {
+		return nil
+	}
+
+	table, err := session.engine.autoMapType(elems[0])
+	if err != nil {
+		return err
+	}
+	if len(table.PrimaryKeys) == 0 {
+		return errors.New("load needs primary keys")
+	}
+
+	loadCols, err := session.engine.loadColumns(table, cols)
+	if err != nil {
+		return err
+	}
+	if len(loadCols) == 0 {
+		return nil
+	}
+
+	var colNames = make([]string, 0, len(table.PrimaryKeys)+len(loadCols))
+	colNames = append(colNames, table.PrimaryKeys...)
+	for _, col := range loadCols {
+		colNames = append(colNames, col.Name)
+	}
+
+	var tableName = session.statement.AltTableName
+	for start := 0; start < len(elems); start += loadBatchSize {
+		end := start + loadBatchSize
+		if end > len(elems) {
+			end = len(elems)
+		}
+
+		var pks = make(map[string][]reflect.Value, end-start)
+		var ids []interface{}
+		var conds []builder.Cond
+		for _, elem := range elems[start:end] {
+			pk, err := session.engine.idOfV(elem)
+			if err != nil {
+				return err
+			}
+			key, err := pk.ToString()
+			if err != nil {
+				return err
+			}
+			if _, ok := pks[key]; !ok {
+				ids = append(ids, pk[0])
+				var eq = builder.Eq{}
+				for i, col := range table.PKColumns() {
+					eq[session.engine.Quote(col.Name)] = pk[i]
+				}
+				conds = append(conds, eq)
+			}
+			pks[key] = append(pks[key], elem)
+		}
+
+		var cond = builder.Or(conds...)
+		if len(table.PrimaryKeys) == 1 {
+			cond = builder.In(session.engine.Quote(table.PrimaryKeys[0]), ids...)
+		}
+
+		loaded := reflect.New(reflect.SliceOf(reflect.PtrTo(elems[0].Type())))
+		if tableName != "" {
+			session.Table(tableName)
+		}
+		err := session.NoCache().Unscoped().NoAutoCondition().Cols(colNames...).
+			And(cond).find(loaded.Interface())
+		if err != nil {
+			return err
+		}
+
+		loaded = loaded.Elem()
+		for i := 0; i < loaded.Len(); i++ {
+			src := loaded.Index(i).Elem()
+			pk, err := session.engine.idOfV(src)
+			if err != nil {
+				return err
+			}
+			key, err := pk.ToString()
+			if err != nil {
+				return err
+			}
+			for _, dst := range pks[key] {
+				for _, col := range loadCols {
+					srcField, err := col.ValueOfV(&src)
+					if err != nil {
+						return err
+					}
+					dstField, err := col.ValueOfV(&dst)
+					if err != nil {
+						return err
+					}
+					dstField.Set(*srcField)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// loadColumns returns the columns which should be loaded by Load
+func (engine *Engine) loadColumns(table *core.Table, names []string) ([]*core.Column, error) {
+	var cols []*core.Column
+	if len(names) == 0 {
+		for _, col := range table.Columns() {
+			if engine.isLazyColumn(col) {
+				cols = append(cols, col)
+			}
+		}
+		return cols, nil
+	}
+
+	for _, name := range names {
+		var found *core.Column
+		for _, col := range table.Columns() {
+			if col.FieldName == name || strings.EqualFold(col.Name, name) {
+				found = col
+				break
+			}
+		}
+		if found == nil {
+			return nil, ErrFieldIsNotExist{name, table.Name}
+		}
+		if found.MapType == core.ONLYTODB {
+			continue
+		}
+		cols = append(cols, found)
+	}
+	return cols, nil
+}
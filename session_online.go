@@ -0,0 +1,128 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// ErrOnlineDDLInTransaction is returned when building indexes concurrently
+// in a transaction, which is not allowed by postgres
+var ErrOnlineDDLInTransaction = errors.New("concurrent index builds could not run in a transaction")
+
+// OnlineDDL builds the indexes without blocking the writes of the table when
+// creating indexes or syncing tables. It's CREATE INDEX CONCURRENTLY on
+// postgres which could not run in a transaction, ALGORITHM=INPLACE LOCK=NONE on
+// mysql and ONLINE = ON on mssql. The invalid indexes left by the failed
+// concurrent builds on postgres will be dropped before creating indexes.
+func (session *Session) OnlineDDL(online ...bool) *Session {
+	session.statement.onlineDDL = len(online) == 0 || online[0]
+	return session
+}
+
+// onlineIndexSQL returns the sql to create the index online
+func (engine *Engine) onlineIndexSQL(sqlStr string, index *core.Index, online bool) string {
+	if !online {
+		return sqlStr
+	}
+
+	caps := engine.Capabilities()
+	switch {
+	case caps.ConcurrentIndex:
+		return strings.Replace(sqlStr, " INDEX ", " INDEX CONCURRENTLY ", 1)
+	case caps.InplaceAlter:
+		// the spatial indexes could only be built with a shared lock
+		if index.Type == SpatialIndexType {
+			return sqlStr + " ALGORITHM=INPLACE LOCK=SHARED"
+		}
+		return sqlStr + " ALGORITHM=INPLACE LOCK=NONE"
+	case caps.OnlineIndex:
+		if index.Type != SpatialIndexType {
+			return sqlStr + " WITH (ONLINE = ON)"
+		}
+	}
+	return sqlStr
+}
+
+// prepareOnlineIndexes checks the transaction and drops the invalid indexes
+// before building indexes concurrently
+func (session *Session) prepareOnlineIndexes(tableName string) error {
+	if !session.statement.onlineDDL || !session.engine.Capabilities().ConcurrentIndex {
+		return nil
+	}
+	if !session.isAutoCommit {
+		return ErrOnlineDDLInTransaction
+	}
+	_, err := session.dropInvalidIndexes(tableName)
+	return err
+}
+
+// InvalidIndexes returns the names of the invalid indexes of the table which
+// are left by the failed concurrent builds, it's always empty except postgres.
+func (session *Session) InvalidIndexes(beanOrTableName interface{}) ([]string, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+	return session.invalidIndexes(session.engine.TableName(beanOrTableName))
+}
+
+func (session *Session) invalidIndexes(tableName string) ([]string, error) {
+	if session.engine.dialect.DBType() != core.POSTGRES {
+		return nil, nil
+	}
+
+	var args = []interface{}{tableName}
+	var sqlStr = "SELECT c.relname AS name FROM pg_index i " +
+		"JOIN pg_class c ON c.oid = i.indexrelid " +
+		"JOIN pg_class t ON t.oid = i.indrelid " +
+		"JOIN pg_namespace n ON n.oid = t.relnamespace " +
+		"WHERE NOT i.indisvalid AND t.relname = ?"
+	if schema := session.engine.dialect.URI().Schema; schema != "" {
+		sqlStr += " AND n.nspname = ?"
+		args = append(args, schema)
+	}
+
+	res, err := session.queryBytes(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	var names = make([]string, 0, len(res))
+	for _, row := range res {
+		names = append(names, string(row["name"]))
+	}
+	return names, nil
+}
+
+// DropInvalidIndexes drops the invalid indexes of the table which are left by
+// the failed concurrent builds and returns their names.
+func (session *Session) DropInvalidIndexes(beanOrTableName interface{}) ([]string, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+	return session.dropInvalidIndexes(session.engine.TableName(beanOrTableName))
+}
+
+func (session *Session) dropInvalidIndexes(tableName string) ([]string, error) {
+	names, err := session.invalidIndexes(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		session.engine.logger.Warnf("Table %s drops invalid index %s", tableName, name)
+		var sqlStr = "DROP INDEX "
+		if session.isAutoCommit {
+			sqlStr += "CONCURRENTLY "
+		}
+		sqlStr += "IF EXISTS " + session.engine.Quote(session.engine.TableName(name, true))
+		if _, err := session.exec(sqlStr); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func TestOnlineIndexSQL(t *testing.T) {
+	var index = core.NewIndex("name", core.IndexType)
+	index.AddColumn("name")
+	var spatial = core.NewIndex("location", SpatialIndexType)
+	spatial.AddColumn("location")
+
+	var cases = []struct {
+		dbType  core.DbType
+		index   *core.Index
+		sqlStr  string
+		expects string
+	}{
+		{core.POSTGRES, index, `CREATE INDEX "IDX_user_name" ON "user" ("name")`, `CREATE INDEX CONCURRENTLY "IDX_user_name" ON "user" ("name")`},
+		{core.POSTGRES, index, `CREATE UNIQUE INDEX "UQE_user_name" ON "user" ("name")`, `CREATE UNIQUE INDEX CONCURRENTLY "UQE_user_name" ON "user" ("name")`},
+		{core.MYSQL, index, "CREATE INDEX `IDX_user_name` ON `user` (`name`)", "CREATE INDEX `IDX_user_name` ON `user` (`name`) ALGORITHM=INPLACE LOCK=NONE"},
+		{core.MYSQL, spatial, "CREATE SPATIAL INDEX `IDX_user_location` ON `user` (`location`)", "CREATE SPATIAL INDEX `IDX_user_location` ON `user` (`location`) ALGORITHM=INPLACE LOCK=SHARED"},
+		{core.MSSQL, index, `CREATE INDEX "IDX_user_name" ON "user" ("name")`, `CREATE INDEX "IDX_user_name" ON "user" ("name") WITH (ONLINE = ON)`},
+		{core.MSSQL, spatial, `CREATE SPATIAL INDEX "IDX_user_location" ON "user" ("location")`, `CREATE SPATIAL INDEX "IDX_user_location" ON "user" ("location")`},
+		{core.SQLITE, index, "CREATE INDEX `IDX_user_name` ON `user` (`name`)", "CREATE INDEX `IDX_user_name` ON `user` (`name`)"},
+	}
+
+	for _, c := range cases {
+		dialect := core.QueryDialect(c.dbType)
+		assert.NoError(t, dialect.Init(nil, &core.Uri{DbType: c.dbType}, "", ""))
+		engine := &Engine{dialect: dialect}
+		assert.EqualValues(t, c.expects, engine.onlineIndexSQL(c.sqlStr, c.index, true))
+		assert.EqualValues(t, c.sqlStr, engine.onlineIndexSQL(c.sqlStr, c.index, false))
+	}
+}
+
+type OnlineIndexStruct struct {
+	Id    int64
+	Name  string `xorm:"index"`
+	Email string `xorm:"unique"`
+}
+
+func TestOnlineDDL(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.DropTables(new(OnlineIndexStruct)))
+	assert.NoError(t, testEngine.CreateTables(new(OnlineIndexStruct)))
+
+	assert.NoError(t, testEngine.OnlineDDL().CreateIndexes(new(OnlineIndexStruct)))
+	assert.NoError(t, testEngine.OnlineDDL().CreateUniques(new(OnlineIndexStruct)))
+	assert.NoError(t, testEngine.OnlineDDL().Sync2(new(OnlineIndexStruct)))
+
+	names, err := testEngine.InvalidIndexes(new(OnlineIndexStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(names))
+
+	names, err = testEngine.DropInvalidIndexes(new(OnlineIndexStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(names))
+
+	if testEngine.Dialect().DBType() == core.POSTGRES {
+		session := testEngine.NewSession()
+		defer session.Close()
+		assert.NoError(t, session.Begin())
+		assert.EqualValues(t, ErrOnlineDDLInTransaction, session.OnlineDDL().CreateIndexes(new(OnlineIndexStruct)))
+	}
+}
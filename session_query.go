@@ -20,6 +20,10 @@ func (session *Session) genQuerySQL(sqlOrArgs ...interface{}) (string, []interfa
 		return convertSQLOrArgs(sqlOrArgs...)
 	}
 
+	if session.statement.lastError != nil {
+		return "", nil, session.statement.lastError
+	}
+
 	if session.statement.RawSQL != "" {
 		return session.statement.RawSQL, session.statement.RawParams, nil
 	}
@@ -54,6 +58,7 @@ func (session *Session) genQuerySQL(sqlOrArgs ...interface{}) (string, []interfa
 		}
 	}
 
+	session.statement.mergePolicyCond()
 	if err := session.statement.processIDParam(); err != nil {
 		return "", nil, err
 	}
@@ -241,7 +246,7 @@ func (session *Session) QueryString(sqlOrArgs ...interface{}) ([]map[string]stri
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer session.closeRows(rows)
 
 	return rows2Strings(rows)
 }
@@ -261,7 +266,7 @@ func (session *Session) QuerySliceString(sqlOrArgs ...interface{}) ([][]string,
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer session.closeRows(rows)
 
 	return rows2SliceString(rows)
 }
@@ -314,7 +319,7 @@ func (session *Session) QueryInterface(sqlOrArgs ...interface{}) ([]map[string]i
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer session.closeRows(rows)
 
 	return rows2Interfaces(rows)
 }
@@ -35,14 +35,14 @@ func (session *Session) queryRows(sqlStr string, args ...interface{}) (*core.Row
 			defer func() {
 				execDuration := time.Since(b4ExecTime)
 				if len(args) > 0 {
-					session.engine.logger.Infof("[SQL] %s %#v - took: %v", sqlStr, args, execDuration)
+					session.engine.logger.Infof("[SQL] %s %#v - took: %v", sqlStr, session.engine.redactArgs(sqlStr, args), execDuration)
 				} else {
 					session.engine.logger.Infof("[SQL] %s - took: %v", sqlStr, execDuration)
 				}
 			}()
 		} else {
 			if len(args) > 0 {
-				session.engine.logger.Infof("[SQL] %v %#v", sqlStr, args)
+				session.engine.logger.Infof("[SQL] %v %#v", sqlStr, session.engine.redactArgs(sqlStr, args))
 			} else {
 				session.engine.logger.Infof("[SQL] %v", sqlStr)
 			}
@@ -50,43 +50,81 @@ func (session *Session) queryRows(sqlStr string, args ...interface{}) (*core.Row
 	}
 
 	if session.isAutoCommit {
-		var db *core.DB
-		if session.sessionType == groupSession {
-			db = session.engine.engineGroup.Slave().DB()
-		} else {
-			db = session.DB()
-		}
-
-		if session.prepareStmt {
-			// don't clear stmt since session will cache them
-			stmt, err := session.doPrepare(db, sqlStr)
-			if err != nil {
-				return nil, err
+		var rows *core.Rows
+		err := session.engine.retry(session.ctx, session.idempotent || isReadSQL(sqlStr), func() error {
+			// the slot is held until the rows are closed, it's not held
+			// while waiting for the next attempt
+			if err := session.acquireSlot(); err != nil {
+				return err
 			}
 
-			rows, err := stmt.QueryContext(session.ctx, args...)
+			var err error
+			rows, err = session.queryDB(sqlStr, args...)
 			if err != nil {
-				return nil, err
+				session.releaseSlot()
 			}
-			return rows, nil
-		}
-
-		rows, err := db.QueryContext(session.ctx, sqlStr, args...)
+			return err
+		})
 		if err != nil {
-			return nil, err
+			return nil, session.engine.redactError(sqlStr, args, err)
 		}
 		return rows, nil
 	}
 
 	rows, err := session.tx.QueryContext(session.ctx, sqlStr, args...)
 	if err != nil {
-		return nil, err
+		return nil, session.engine.redactError(sqlStr, args, err)
 	}
 	return rows, nil
 }
 
-func (session *Session) queryRow(sqlStr string, args ...interface{}) *core.Row {
-	return core.NewRow(session.queryRows(sqlStr, args...))
+func (session *Session) queryDB(sqlStr string, args ...interface{}) (*core.Rows, error) {
+	var db *core.DB
+	if session.sessionType == groupSession {
+		db = session.engine.engineGroup.Slave().DB()
+	} else {
+		db = session.DB()
+	}
+
+	if session.prepareStmt {
+		// don't clear stmt since session will cache them
+		stmt, err := session.doPrepare(db, sqlStr)
+		if err != nil {
+			return nil, err
+		}
+
+		return stmt.QueryContext(session.ctx, args...)
+	}
+
+	return db.QueryContext(session.ctx, sqlStr, args...)
+}
+
+// closeRows closes the rows returned by queryRows and releases the slot of
+// the query
+func (session *Session) closeRows(rows *core.Rows) error {
+	err := rows.Close()
+	session.releaseQuerySlot()
+	return err
+}
+
+// slotRow releases the slot of the query once the row is scanned
+type slotRow struct {
+	*core.Row
+	session *Session
+}
+
+func (row *slotRow) Scan(dest ...interface{}) error {
+	defer row.session.releaseQuerySlot()
+	return row.Row.Scan(dest...)
+}
+
+func (row *slotRow) ScanSlice(dest interface{}) error {
+	defer row.session.releaseQuerySlot()
+	return row.Row.ScanSlice(dest)
+}
+
+func (session *Session) queryRow(sqlStr string, args ...interface{}) *slotRow {
+	return &slotRow{core.NewRow(session.queryRows(sqlStr, args...)), session}
 }
 
 func value2Bytes(rawValue *reflect.Value) ([]byte, error) {
@@ -146,7 +184,7 @@ func (session *Session) queryBytes(sqlStr string, args ...interface{}) ([]map[st
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer session.closeRows(rows)
 
 	return rows2maps(rows)
 }
@@ -162,14 +200,14 @@ func (session *Session) exec(sqlStr string, args ...interface{}) (sql.Result, er
 			defer func() {
 				execDuration := time.Since(b4ExecTime)
 				if len(args) > 0 {
-					session.engine.logger.Infof("[SQL] %s %#v - took: %v", sqlStr, args, execDuration)
+					session.engine.logger.Infof("[SQL] %s %#v - took: %v", sqlStr, session.engine.redactArgs(sqlStr, args), execDuration)
 				} else {
 					session.engine.logger.Infof("[SQL] %s - took: %v", sqlStr, execDuration)
 				}
 			}()
 		} else {
 			if len(args) > 0 {
-				session.engine.logger.Infof("[SQL] %v %#v", sqlStr, args)
+				session.engine.logger.Infof("[SQL] %v %#v", sqlStr, session.engine.redactArgs(sqlStr, args))
 			} else {
 				session.engine.logger.Infof("[SQL] %v", sqlStr)
 			}
@@ -177,23 +215,38 @@ func (session *Session) exec(sqlStr string, args ...interface{}) (sql.Result, er
 	}
 
 	if !session.isAutoCommit {
-		return session.tx.ExecContext(session.ctx, sqlStr, args...)
+		res, err := session.tx.ExecContext(session.ctx, sqlStr, args...)
+		if err != nil {
+			return nil, session.engine.redactError(sqlStr, args, err)
+		}
+		return res, nil
 	}
 
-	if session.prepareStmt {
-		stmt, err := session.doPrepare(session.DB(), sqlStr)
-		if err != nil {
-			return nil, err
+	var res sql.Result
+	err := session.engine.retry(session.ctx, session.idempotent, func() error {
+		if err := session.acquireSlot(); err != nil {
+			return err
 		}
+		defer session.releaseSlot()
 
-		res, err := stmt.ExecContext(session.ctx, args...)
-		if err != nil {
-			return nil, err
+		if session.prepareStmt {
+			stmt, err := session.doPrepare(session.DB(), sqlStr)
+			if err != nil {
+				return err
+			}
+
+			res, err = stmt.ExecContext(session.ctx, args...)
+			return err
 		}
-		return res, nil
-	}
 
-	return session.DB().ExecContext(session.ctx, sqlStr, args...)
+		var err error
+		res, err = session.DB().ExecContext(session.ctx, sqlStr, args...)
+		return err
+	})
+	if err != nil {
+		return nil, session.engine.redactError(sqlStr, args, err)
+	}
+	return res, nil
 }
 
 func convertSQLOrArgs(sqlOrArgs ...interface{}) (string, []interface{}, error) {
@@ -0,0 +1,249 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"xorm.io/core"
+)
+
+// TableRenamedFrom is the interface to declare the previous names of the
+// table, Sync2 renames the table if one of them exists and the table doesn't.
+type TableRenamedFrom interface {
+	TableRenamedFrom() []string
+}
+
+var tpTableRenamedFrom = reflect.TypeOf((*TableRenamedFrom)(nil)).Elem()
+
+// RenamedFromTagHandler describes renamedfrom tag handler, Sync2 renames the
+// column from the old name if it exists and the column doesn't.
+func RenamedFromTagHandler(ctx *tagContext) error {
+	if len(ctx.params) == 0 {
+		return fmt.Errorf("field %s tag renamedfrom needs the old column name", ctx.col.FieldName)
+	}
+	ctx.engine.setColumnExtra(ctx.col).renamedFrom = strings.Trim(ctx.params[0], "'`\" ")
+	return nil
+}
+
+// columnRenamedFrom returns the old name of the column if it's renamed
+func (engine *Engine) columnRenamedFrom(col *core.Column) string {
+	if extra := engine.columnExtra(col); extra != nil {
+		return extra.renamedFrom
+	}
+	return ""
+}
+
+// tableRenamedFrom returns the previous names of the table of the bean
+func tableRenamedFrom(bean interface{}) []string {
+	v := rValue(bean)
+	if v.Type().Implements(tpTableRenamedFrom) {
+		return v.Interface().(TableRenamedFrom).TableRenamedFrom()
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(tpTableRenamedFrom) {
+		return v.Addr().Interface().(TableRenamedFrom).TableRenamedFrom()
+	}
+	return nil
+}
+
+// RenameTableSQL returns the sql and its args to rename the table
+func (engine *Engine) RenameTableSQL(oldName, newName string) (string, []interface{}) {
+	switch engine.dialect.DBType() {
+	case core.MSSQL:
+		return "EXEC sp_rename ?, ?", []interface{}{oldName, newName}
+	case core.MYSQL:
+		return fmt.Sprintf("RENAME TABLE %s TO %s", engine.Quote(oldName), engine.Quote(newName)), nil
+	}
+	return fmt.Sprintf("ALTER TABLE %s RENAME TO %s", engine.Quote(oldName), engine.Quote(newName)), nil
+}
+
+// RebuildTableSQL returns the sqls to rebuild the table whose columns could
+// not be altered in place, which is needed by old SQLite. A new table is
+// created with the columns of colSeq, renamed by renames and without the
+// ones whose lower case names are in dropped, the records are copied and the
+// new table replaces the old one. The indexes are dropped with the old table.
+func (engine *Engine) RebuildTableSQL(tableName string, colSeq []string, cols map[string]*core.Column, renames map[string]string, dropped map[string]bool) []string {
+	var newTable = core.NewEmptyTable()
+	var oldCols = make([]string, 0, len(colSeq))
+	var newCols = make([]string, 0, len(colSeq))
+	for _, name := range colSeq {
+		if dropped[strings.ToLower(name)] {
+			continue
+		}
+		col := *cols[name]
+		if newName, ok := renames[name]; ok {
+			col.Name = newName
+		}
+		col.Indexes = make(map[string]int)
+		newTable.AddColumn(&col)
+
+		oldCols = append(oldCols, engine.Quote(name))
+		newCols = append(newCols, engine.Quote(col.Name))
+	}
+
+	var tmpName = "xorm_rebuild_" + tableName
+	renameSQL, _ := engine.RenameTableSQL(tmpName, tableName)
+	return []string{
+		engine.dialect.CreateTableSql(newTable, tmpName, "", ""),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", engine.Quote(tmpName),
+			strings.Join(newCols, ", "), strings.Join(oldCols, ", "), engine.Quote(tableName)),
+		fmt.Sprintf("DROP TABLE %s", engine.Quote(tableName)),
+		renameSQL,
+	}
+}
+
+// renameColumnSQL returns the sql to rename the column to the name of col
+func (engine *Engine) renameColumnSQL(tableName, oldName string, col *core.Column) (string, []interface{}) {
+	switch engine.dialect.DBType() {
+	case core.MSSQL:
+		return "EXEC sp_rename ?, ?, 'COLUMN'", []interface{}{tableName + "." + oldName, col.Name}
+	case core.MYSQL:
+		// RENAME COLUMN is only supported since mysql 8.0
+		def := col.StringNoPk(engine.dialect)
+		if col.IsAutoIncrement {
+			def += engine.dialect.AutoIncrStr()
+		}
+		return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s", engine.Quote(tableName), engine.Quote(oldName), def), nil
+	}
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", engine.Quote(tableName),
+		engine.Quote(oldName), engine.Quote(col.Name)), nil
+}
+
+// renameTable renames the table from one of its previous names, it returns
+// the renamed table or nil if there is none.
+func (session *Session) renameTable(bean interface{}, tbName string, tables []*core.Table) (*core.Table, error) {
+	for _, oldName := range tableRenamedFrom(bean) {
+		for _, tb := range tables {
+			if !strings.EqualFold(session.engine.tbNameWithSchema(tb.Name), session.engine.tbNameWithSchema(oldName)) {
+				continue
+			}
+
+			session.engine.logger.Infof("Table %s renamed to %s", tb.Name, tbName)
+			sqlStr, args := session.engine.RenameTableSQL(tb.Name, tbName)
+			if _, err := session.exec(sqlStr, args...); err != nil {
+				return nil, err
+			}
+			tb.Name = tbName
+			return tb, nil
+		}
+	}
+	return nil, nil
+}
+
+// renameColumns renames the columns which have the renamedfrom tag when the
+// old column exists on the table and the new one doesn't.
+func (session *Session) renameColumns(table *core.Table, tbName string) error {
+	engine := session.engine
+	var renames = make(map[string]*core.Column)
+	for _, col := range table.Columns() {
+		if engine.columnRenamedFrom(col) != "" {
+			renames[col.Name] = col
+		}
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+
+	colSeq, cols, err := engine.dialect.GetColumns(tbName)
+	if err != nil {
+		return err
+	}
+	var findColumn = func(name string) string {
+		for _, colName := range colSeq {
+			if strings.EqualFold(colName, name) {
+				return colName
+			}
+		}
+		return ""
+	}
+
+	// the renames from the old column names to the new ones
+	var oldNames = make(map[string]string)
+	for _, col := range renames {
+		oldName := findColumn(engine.columnRenamedFrom(col))
+		if oldName == "" || findColumn(col.Name) != "" {
+			continue
+		}
+		oldNames[oldName] = col.Name
+	}
+	if len(oldNames) == 0 {
+		return nil
+	}
+
+	if engine.dialect.DBType() == core.SQLITE {
+		supported, err := session.sqliteSupportsRenameColumn()
+		if err != nil {
+			return err
+		}
+		if !supported {
+			return session.rebuildSQLiteTable(tbName, colSeq, cols, oldNames)
+		}
+
+		// sqlite quotes the renamed columns of the indexes which could not be
+		// parsed, so the indexes are dropped and will be created again by Sync2
+		indexes, err := engine.dialect.GetIndexes(tbName)
+		if err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			for _, colName := range index.Cols {
+				if _, ok := oldNames[colName]; ok {
+					if _, err := session.exec(engine.dialect.DropIndexSql(tbName, index)); err != nil {
+						return err
+					}
+					break
+				}
+			}
+		}
+	}
+
+	for oldName, newName := range oldNames {
+		engine.logger.Infof("Table %s column %s renamed to %s", tbName, oldName, newName)
+		sqlStr, args := engine.renameColumnSQL(tbName, oldName, renames[newName])
+		if _, err := session.exec(sqlStr, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteSupportsRenameColumn returns true if the sqlite version is 3.25.0 or
+// later which supports ALTER TABLE ... RENAME COLUMN
+func (session *Session) sqliteSupportsRenameColumn() (bool, error) {
+	res, err := session.queryBytes("SELECT sqlite_version() AS version")
+	if err != nil {
+		return false, err
+	}
+	if len(res) == 0 {
+		return false, nil
+	}
+
+	var version [3]int
+	for i, s := range strings.SplitN(string(res[0]["version"]), ".", 3) {
+		version[i], _ = strconv.Atoi(s)
+	}
+	return version[0] > 3 || (version[0] == 3 && version[1] >= 25), nil
+}
+
+// rebuildSQLiteTable renames the columns by creating a new table, copying the
+// records and replacing the old table. The indexes are dropped with the old
+// table and will be created again by Sync2.
+func (session *Session) rebuildSQLiteTable(tbName string, colSeq []string, cols map[string]*core.Column, oldNames map[string]string) error {
+	engine := session.engine
+	engine.logger.Infof("Table %s is rebuilt to rename columns", tbName)
+
+	sqls := engine.RebuildTableSQL(tbName, colSeq, cols, oldNames, nil)
+	return session.inTx(func() error {
+		for _, sqlStr := range sqls {
+			if _, err := session.exec(sqlStr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
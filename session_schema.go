@@ -54,6 +54,9 @@ func (session *Session) createIndexes(bean interface{}) error {
 	if err := session.statement.setRefBean(bean); err != nil {
 		return err
 	}
+	if err := session.prepareOnlineIndexes(session.statement.TableName()); err != nil {
+		return err
+	}
 
 	sqls := session.statement.genIndexSQL()
 	for _, sqlStr := range sqls {
@@ -77,6 +80,9 @@ func (session *Session) createUniques(bean interface{}) error {
 	if err := session.statement.setRefBean(bean); err != nil {
 		return err
 	}
+	if err := session.prepareOnlineIndexes(session.statement.TableName()); err != nil {
+		return err
+	}
 
 	sqls := session.statement.genUniqueSQL()
 	for _, sqlStr := range sqls {
@@ -207,14 +213,14 @@ func (session *Session) addColumn(colName string) error {
 
 func (session *Session) addIndex(tableName, idxName string) error {
 	index := session.statement.RefTable.Indexes[idxName]
-	sqlStr := session.engine.dialect.CreateIndexSql(tableName, index)
+	sqlStr := session.engine.createIndexSQL(tableName, index, session.statement.onlineDDL)
 	_, err := session.exec(sqlStr)
 	return err
 }
 
 func (session *Session) addUnique(tableName, uqeName string) error {
 	index := session.statement.RefTable.Indexes[uqeName]
-	sqlStr := session.engine.dialect.CreateIndexSql(tableName, index)
+	sqlStr := session.engine.createIndexSQL(tableName, index, session.statement.onlineDDL)
 	_, err := session.exec(sqlStr)
 	return err
 }
@@ -240,10 +246,12 @@ func (session *Session) Sync2(beans ...interface{}) error {
 	}()
 
 	for _, bean := range beans {
-		v := rValue(bean)
-		table, err := engine.mapType(v)
-		if err != nil {
-			return err
+		// the name of a table registered by RegisterTable could be synced
+		table := engine.registeredTableOf(bean)
+		if table == nil {
+			if table, err = engine.mapType(rValue(bean)); err != nil {
+				return err
+			}
 		}
 		var tbName string
 		if len(session.statement.AltTableName) > 0 {
@@ -253,6 +261,10 @@ func (session *Session) Sync2(beans ...interface{}) error {
 		}
 		tbNameWithSchema := engine.tbNameWithSchema(tbName)
 
+		if err = session.syncTreeClosure(table, tbName); err != nil {
+			return err
+		}
+
 		var oriTable *core.Table
 		for _, tb := range tables {
 			if strings.EqualFold(engine.tbNameWithSchema(tb.Name), engine.tbNameWithSchema(tbName)) {
@@ -261,6 +273,13 @@ func (session *Session) Sync2(beans ...interface{}) error {
 			}
 		}
 
+		// the table may be renamed
+		if oriTable == nil {
+			if oriTable, err = session.renameTable(bean, tbName, tables); err != nil {
+				return err
+			}
+		}
+
 		// this is a new table
 		if oriTable == nil {
 			err = session.StoreEngine(session.statement.StoreEngine).createTable(bean)
@@ -281,12 +300,22 @@ func (session *Session) Sync2(beans ...interface{}) error {
 		}
 
 		// this will modify an old table
+		if err = session.renameColumns(table, oriTable.Name); err != nil {
+			return err
+		}
+		if err = session.prepareOnlineIndexes(oriTable.Name); err != nil {
+			return err
+		}
 		if err = engine.loadTableInfo(oriTable); err != nil {
 			return err
 		}
 
 		// check columns
 		for _, col := range table.Columns() {
+			if engine.columnExpr(col) != "" {
+				continue
+			}
+
 			var oriCol *core.Column
 			for _, col2 := range oriTable.Columns() {
 				if strings.EqualFold(col.Name, col2.Name) {
@@ -311,9 +340,7 @@ func (session *Session) Sync2(beans ...interface{}) error {
 			if expectedType != curType {
 				if expectedType == core.Text &&
 					strings.HasPrefix(curType, core.Varchar) {
-					// currently only support mysql & postgres
-					if engine.dialect.DBType() == core.MYSQL ||
-						engine.dialect.DBType() == core.POSTGRES {
+					if engine.Capabilities().ModifyColumn {
 						engine.logger.Infof("Table %s column %s change type from %s to %s\n",
 							tbNameWithSchema, col.Name, curType, expectedType)
 						_, err = session.exec(engine.dialect.ModifyColumnSql(tbNameWithSchema, col))
@@ -322,7 +349,7 @@ func (session *Session) Sync2(beans ...interface{}) error {
 							tbNameWithSchema, col.Name, curType, expectedType)
 					}
 				} else if strings.HasPrefix(curType, core.Varchar) && strings.HasPrefix(expectedType, core.Varchar) {
-					if engine.dialect.DBType() == core.MYSQL {
+					if engine.Capabilities().WidenVarchar {
 						if oriCol.Length < col.Length {
 							engine.logger.Infof("Table %s column %s change type from varchar(%d) to varchar(%d)\n",
 								tbNameWithSchema, col.Name, oriCol.Length, col.Length)
@@ -336,7 +363,7 @@ func (session *Session) Sync2(beans ...interface{}) error {
 					}
 				}
 			} else if expectedType == core.Varchar {
-				if engine.dialect.DBType() == core.MYSQL {
+				if engine.Capabilities().WidenVarchar {
 					if oriCol.Length < col.Length {
 						engine.logger.Infof("Table %s column %s change type from varchar(%d) to varchar(%d)\n",
 							tbNameWithSchema, col.Name, oriCol.Length, col.Length)
@@ -367,9 +394,18 @@ func (session *Session) Sync2(beans ...interface{}) error {
 		var foundIndexNames = make(map[string]bool)
 		var addedNames = make(map[string]*core.Index)
 
+		engine.matchIndexNames(tbName, table, oriTable)
 		for name, index := range table.Indexes {
 			var oriIndex *core.Index
+			// the index with the same name is preferred
+			if index2, ok := oriTable.Indexes[name]; ok && index.Equal(index2) {
+				oriIndex = index2
+				foundIndexNames[name] = true
+			}
 			for name2, index2 := range oriTable.Indexes {
+				if oriIndex != nil {
+					break
+				}
 				if index.Equal(index2) {
 					oriIndex = index2
 					foundIndexNames[name2] = true
@@ -378,7 +414,9 @@ func (session *Session) Sync2(beans ...interface{}) error {
 			}
 
 			if oriIndex != nil {
-				if oriIndex.Type != index.Type {
+				// the spatial indexes are loaded as normal indexes
+				if oriIndex.Type != index.Type &&
+					!(index.Type == SpatialIndexType && oriIndex.Type == core.IndexType) {
 					sql := engine.dialect.DropIndexSql(tbNameWithSchema, oriIndex)
 					_, err = session.exec(sql)
 					if err != nil {
@@ -408,7 +446,7 @@ func (session *Session) Sync2(beans ...interface{}) error {
 				session.statement.RefTable = table
 				session.statement.tableName = tbNameWithSchema
 				err = session.addUnique(tbNameWithSchema, name)
-			} else if index.Type == core.IndexType {
+			} else if index.Type == core.IndexType || index.Type == SpatialIndexType {
 				session.statement.RefTable = table
 				session.statement.tableName = tbNameWithSchema
 				err = session.addIndex(tbNameWithSchema, name)
@@ -5,12 +5,17 @@
 package xorm
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"os"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
 )
 
 func TestStoreEngine(t *testing.T) {
@@ -218,6 +223,102 @@ func TestDump(t *testing.T) {
 	assert.NoError(t, testEngine.DumpAllToFile(fp))
 }
 
+func TestDumpAnonymized(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type DumpCustomer struct {
+		Id    int64
+		Name  string `xorm:"pii(name)"`
+		Email string `xorm:"varchar(100) pii(email)"`
+		Phone string
+		Note  string `xorm:"pii(fixed, N/A)"`
+		Title string
+		Level int    `xorm:"pii(fixed, 42)"`
+		Photo []byte `xorm:"pii(hash)"`
+	}
+	type DumpOrder struct {
+		Id       int64
+		Customer string `xorm:"pii(email)"`
+		Amount   int
+	}
+
+	assert.NoError(t, testEngine.Sync2(new(DumpCustomer), new(DumpOrder)))
+	_, err := testEngine.Insert(&DumpCustomer{
+		Name:  "Lunny Xiao",
+		Email: "lunny@gmail.com",
+		Phone: "+86-139-1234-5678",
+		Note:  "VIP",
+		Title: "Mr",
+		Level: 3,
+		Photo: []byte("photo"),
+	})
+	assert.NoError(t, err)
+	_, err = testEngine.Insert(&DumpOrder{Customer: "lunny@gmail.com", Amount: 10})
+	assert.NoError(t, err)
+
+	anonymizer := NewAnonymizer([]byte("secret"))
+	assert.NoError(t, anonymizer.LoadRules(strings.NewReader(`
+# phone numbers keep the format
+dump_customer.phone = format
+dump_customer.title = fixed(O'Brien')
+`)))
+	assert.Error(t, anonymizer.AddRule("dump_customer", "phone", "unknown"))
+
+	var buf bytes.Buffer
+	assert.NoError(t, testEngine.DumpAllAnonymized(&buf, anonymizer))
+	dump := buf.String()
+	assert.NotContains(t, dump, "Lunny")
+	assert.NotContains(t, dump, "lunny@gmail.com")
+	assert.NotContains(t, dump, "1234-5678")
+	assert.NotContains(t, dump, "VIP")
+	assert.Contains(t, dump, "'N/A'")
+	assert.Regexp(t, `'\+\d\d-\d{3}-\d{4}-\d{4}'`, dump)
+
+	// the same value is transformed to the same result in all the tables
+	email := anonymizer.transform(anonymizeRule{name: anonymizeEmail}, &core.Column{SQLType: core.SQLType{Name: core.Varchar}}, "lunny@gmail.com")
+	assert.EqualValues(t, 2, strings.Count(dump, "'"+email.(string)+"'"))
+
+	assert.Error(t, testEngine.DumpAllAnonymized(&buf, NewAnonymizer(nil)))
+
+	// the dumped SQL could be imported back
+	engine := testEngine.(*Engine)
+	customerTable := engine.TableInfo(new(DumpCustomer)).Table
+	buf.Reset()
+	assert.NoError(t, engine.DumpTablesAnonymized([]*core.Table{customerTable}, &buf, anonymizer))
+	assert.NoError(t, testEngine.DropTables(new(DumpCustomer)))
+	_, err = engine.Import(&buf)
+	assert.NoError(t, err)
+
+	var customer DumpCustomer
+	has, err := testEngine.Get(&customer)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "O'Brien", customer.Title)
+	assert.EqualValues(t, "N/A", customer.Note)
+	assert.EqualValues(t, 42, customer.Level)
+	assert.NotEqual(t, "photo", string(customer.Photo))
+
+	// the rules giving non-numeric values to numeric columns are rejected
+	assert.NoError(t, anonymizer.AddRule("dump_customer", "level", "fixed(N/A)"))
+	assert.Error(t, engine.DumpTablesAnonymized([]*core.Table{customerTable}, &buf, anonymizer))
+
+	// the hashes are kept in the range of the integer columns
+	for _, v := range []string{"1", "2", "lunny", "123456789"} {
+		hash := anonymizer.transform(anonymizeRule{name: anonymizeHash}, &core.Column{SQLType: core.SQLType{Name: core.Int}}, v)
+		assert.True(t, hash.(int64) >= 0 && hash.(int64) <= math.MaxInt32)
+		hash = anonymizer.transform(anonymizeRule{name: anonymizeHash}, &core.Column{SQLType: core.SQLType{Name: core.SmallInt}}, v)
+		assert.True(t, hash.(int64) >= 0 && hash.(int64) <= math.MaxInt16)
+	}
+
+	// the short text columns still get a valid email and whole runes
+	email = anonymizer.transform(anonymizeRule{name: anonymizeEmail}, &core.Column{SQLType: core.SQLType{Name: core.Varchar}, Length: 20}, "lunny@gmail.com")
+	assert.True(t, strings.HasSuffix(email.(string), "@example.com"))
+	assert.True(t, len(email.(string)) <= 20)
+	formatted := anonymizer.transform(anonymizeRule{name: anonymizeFormat}, &core.Column{SQLType: core.SQLType{Name: core.Varchar}, Length: 3}, "€€€12")
+	assert.True(t, utf8.ValidString(formatted.(string)))
+	assert.EqualValues(t, "€€€", formatted)
+}
+
 type IndexOrUnique struct {
 	Id        int64
 	Index     int `xorm:"index"`
@@ -345,3 +446,93 @@ func TestSync2_Default(t *testing.T) {
 	assertSync(t, new(TestSync2Default))
 	assert.NoError(t, testEngine.Sync2(new(TestSync2Default)))
 }
+
+type SyncRenameV1 struct {
+	Id   int64
+	Name string `xorm:"index"`
+	Age  int
+}
+
+func (SyncRenameV1) TableName() string {
+	return "sync_rename_old"
+}
+
+type SyncRenameV2 struct {
+	Id       int64
+	FullName string `xorm:"index renamedfrom(name)"`
+	Age      int
+}
+
+func (SyncRenameV2) TableName() string {
+	return "sync_rename"
+}
+
+func (SyncRenameV2) TableRenamedFrom() []string {
+	return []string{"sync_rename_old"}
+}
+
+func TestSync2_Rename(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.DropTables("sync_rename_old", "sync_rename"))
+	assertSync(t, new(SyncRenameV1))
+
+	_, err := testEngine.Insert(&SyncRenameV1{Name: "lunny", Age: 18})
+	assert.NoError(t, err)
+
+	assert.NoError(t, testEngine.Sync2(new(SyncRenameV2)))
+
+	exist, err := testEngine.IsTableExist("sync_rename_old")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	var user SyncRenameV2
+	has, err := testEngine.Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "lunny", user.FullName)
+	assert.EqualValues(t, 18, user.Age)
+
+	tables, err := testEngine.DBMetas()
+	assert.NoError(t, err)
+	for _, table := range tables {
+		if table.Name == "sync_rename" {
+			assert.EqualValues(t, []string{"id", "full_name", "age"}, table.ColumnsSeq())
+			assert.EqualValues(t, 1, len(table.Indexes))
+		}
+	}
+
+	// sync again should do nothing
+	assert.NoError(t, testEngine.Sync2(new(SyncRenameV2)))
+}
+
+func TestRebuildSQLiteTable(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	if testEngine.Dialect().DBType() != core.SQLITE {
+		t.Skip("rebuilding table is only for sqlite")
+	}
+	assert.NoError(t, testEngine.DropTables("sync_rename_old"))
+	assertSync(t, new(SyncRenameV1))
+
+	_, err := testEngine.Insert(&SyncRenameV1{Name: "lunny", Age: 18})
+	assert.NoError(t, err)
+
+	session := testEngine.NewSession()
+	defer session.Close()
+
+	colSeq, cols, err := testEngine.Dialect().GetColumns("sync_rename_old")
+	assert.NoError(t, err)
+	assert.NoError(t, session.rebuildSQLiteTable("sync_rename_old", colSeq, cols, map[string]string{"name": "full_name"}))
+
+	colSeq, _, err = testEngine.Dialect().GetColumns("sync_rename_old")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"id", "full_name", "age"}, colSeq)
+
+	results, err := testEngine.QueryString("SELECT full_name, age FROM sync_rename_old")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []map[string]string{{"full_name": "lunny", "age": "18"}}, results)
+
+	_, err = testEngine.Insert(&SyncRenameV1{Age: 20})
+	assert.Error(t, err)
+	_, err = testEngine.Table("sync_rename_old").Insert(map[string]interface{}{"full_name": "xlw", "age": 20})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,328 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// syncRowsBatchSize is the max number of rows inserted by one InsertMulti of
+// SyncRows, it's less if the args of the rows exceed the dialect's limit
+const syncRowsBatchSize = 100
+
+// SyncRowsResult is the number of the rows changed by SyncRows
+type SyncRowsResult struct {
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+	Restored int64 // the soft deleted rows which are given again
+}
+
+// SyncRows makes the rows in the scope the same as the given rows in a
+// transaction. The rows are matched with the existing ones by the key
+// columns, the primary keys if no key is given. The unmatched rows are
+// inserted, the matched rows are updated if any column is changed and the
+// existing rows which are not given are deleted, or soft deleted if the
+// bean has a deleted column. The soft deleted rows are restored if they are
+// given again.
+//
+//	var prices = []Price{{SupplierId: 3, Sku: "a", Price: 10}, ...}
+//	result, err := engine.SyncRows(&prices, builder.Eq{"supplier_id": 3}, "sku")
+//
+// The primary keys of the existing rows are set to the matched given rows.
+// The lazy columns are not compared since they are not loaded.
+func (session *Session) SyncRows(rowsSlicePtr interface{}, scope builder.Cond, keys ...string) (*SyncRowsResult, error) {
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+
+	if session.statement.lastError != nil {
+		return nil, session.statement.lastError
+	}
+
+	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
+	if sliceValue.Kind() != reflect.Slice {
+		return nil, ErrParamsType
+	}
+	elemType := sliceValue.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, errors.New("needs a pointer to a slice of structs")
+	}
+
+	var result *SyncRowsResult
+	err := session.inTx(func() error {
+		var err error
+		result, err = session.syncRows(sliceValue, elemType, scope, keys)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (session *Session) syncRows(sliceValue reflect.Value, elemType reflect.Type, scope builder.Cond, keys []string) (*SyncRowsResult, error) {
+	table, err := session.engine.autoMapType(reflect.New(elemType).Elem())
+	if err != nil {
+		return nil, err
+	}
+	if len(table.PrimaryKeys) == 0 {
+		return nil, errors.New("sync rows needs primary keys")
+	}
+
+	var keyCols []*core.Column
+	if len(keys) == 0 {
+		keyCols = table.PKColumns()
+	} else {
+		for _, key := range keys {
+			col := table.GetColumn(key)
+			if col == nil {
+				return nil, ErrFieldIsNotExist{key, table.Name}
+			}
+			keyCols = append(keyCols, col)
+		}
+	}
+
+	var tableName = session.statement.AltTableName
+	var rowKey = func(row reflect.Value) (string, error) {
+		var values = make([]interface{}, 0, len(keyCols))
+		for _, col := range keyCols {
+			fieldValue, err := col.ValueOfV(&row)
+			if err != nil {
+				return "", err
+			}
+			value, err := session.value2Interface(col, *fieldValue)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+		return fmt.Sprintf("%#v", values), nil
+	}
+
+	if scope == nil {
+		scope = builder.NewCond()
+	}
+	existing := reflect.New(reflect.SliceOf(reflect.PtrTo(elemType)))
+	if tableName != "" {
+		session.Table(tableName)
+	}
+	if err := session.NoCache().NoAutoCondition().Unscoped().And(scope).find(existing.Interface()); err != nil {
+		return nil, err
+	}
+	existing = existing.Elem()
+
+	var deletedCol = table.DeletedColumn()
+	var isDeleted = func(row reflect.Value) (bool, error) {
+		if deletedCol == nil {
+			return false, nil
+		}
+		fieldValue, err := deletedCol.ValueOfV(&row)
+		if err != nil {
+			return false, err
+		}
+		field := reflect.Indirect(*fieldValue)
+		if !field.IsValid() {
+			return false, nil
+		}
+		if field.Type().ConvertibleTo(core.TimeType) {
+			t := field.Convert(core.TimeType).Interface().(time.Time)
+			return !isTimeZero(t), nil
+		}
+		return !isZero(field.Interface()), nil
+	}
+
+	var existingRows = make(map[string]reflect.Value, existing.Len())
+	for i := 0; i < existing.Len(); i++ {
+		row := existing.Index(i).Elem()
+		key, err := rowKey(row)
+		if err != nil {
+			return nil, err
+		}
+		existingRows[key] = row
+	}
+
+	var result SyncRowsResult
+	var inserts []reflect.Value
+	var updates [][2]reflect.Value
+	var restores = make(map[string]bool)
+	var given = make(map[string]bool, sliceValue.Len())
+	for i := 0; i < sliceValue.Len(); i++ {
+		row := reflect.Indirect(sliceValue.Index(i))
+		key, err := rowKey(row)
+		if err != nil {
+			return nil, err
+		}
+		if given[key] {
+			return nil, fmt.Errorf("sync rows has duplicated key %s", key)
+		}
+		given[key] = true
+
+		old, ok := existingRows[key]
+		if !ok {
+			inserts = append(inserts, row)
+			continue
+		}
+		changed, err := session.syncRowsCopy(table, old, row)
+		if err != nil {
+			return nil, err
+		}
+		deleted, err := isDeleted(old)
+		if err != nil {
+			return nil, err
+		}
+		if deleted {
+			restores[key] = true
+		}
+		if changed || deleted {
+			updates = append(updates, [2]reflect.Value{old, row})
+		}
+	}
+
+	// the missing rows are deleted first, so the unique keys could be reused
+	for i := 0; i < existing.Len(); i++ {
+		row := existing.Index(i).Elem()
+		key, err := rowKey(row)
+		if err != nil {
+			return nil, err
+		}
+		if given[key] {
+			continue
+		}
+		if deleted, err := isDeleted(row); err != nil {
+			return nil, err
+		} else if deleted {
+			continue
+		}
+
+		pk, err := session.engine.idOfV(row)
+		if err != nil {
+			return nil, err
+		}
+		if tableName != "" {
+			session.Table(tableName)
+		}
+		cnt, err := session.ID(pk).NoAutoCondition().Delete(row.Addr().Interface())
+		if err != nil {
+			return nil, err
+		}
+		result.Deleted += cnt
+	}
+
+	for _, update := range updates {
+		pk, err := session.engine.idOfV(update[0])
+		if err != nil {
+			return nil, err
+		}
+		if tableName != "" {
+			session.Table(tableName)
+		}
+		cnt, err := session.ID(pk).AllCols().NoAutoCondition().Update(update[1].Addr().Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := rowKey(update[1])
+		if err != nil {
+			return nil, err
+		}
+		if !restores[key] {
+			result.Updated += cnt
+			continue
+		}
+
+		// the soft deleted row is restored
+		if tableName != "" {
+			session.Table(tableName)
+		} else {
+			session.Table(update[1].Addr().Interface())
+		}
+		cnt, err = session.ID(pk).Unscoped().NoAutoCondition().
+			Update(map[string]interface{}{deletedCol.Name: nil})
+		if err != nil {
+			return nil, err
+		}
+		if fieldValue, err := deletedCol.ValueOfV(&update[1]); err == nil {
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+		}
+		result.Restored += cnt
+	}
+
+	var batchSize = syncRowsBatchSize
+	if maxParams := session.engine.Capabilities().MaxParams; maxParams > 0 {
+		if n := maxParams / len(table.Columns()); n < batchSize {
+			batchSize = n
+		}
+		if batchSize < 1 {
+			batchSize = 1
+		}
+	}
+	for start := 0; start < len(inserts); start += batchSize {
+		end := start + batchSize
+		if end > len(inserts) {
+			end = len(inserts)
+		}
+		var rows = reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(elemType)), 0, end-start)
+		for _, row := range inserts[start:end] {
+			rows = reflect.Append(rows, row.Addr())
+		}
+		if tableName != "" {
+			session.Table(tableName)
+		}
+		cnt, err := session.InsertMulti(rows.Interface())
+		if err != nil {
+			return nil, err
+		}
+		result.Inserted += cnt
+	}
+	return &result, nil
+}
+
+// syncRowsCopy copies the primary keys and the columns maintained by xorm
+// from the existing row to the given row, and returns true if any other
+// column of them is different.
+func (session *Session) syncRowsCopy(table *core.Table, old, row reflect.Value) (bool, error) {
+	var changed bool
+	for _, col := range table.Columns() {
+		oldField, err := col.ValueOfV(&old)
+		if err != nil {
+			return false, err
+		}
+		field, err := col.ValueOfV(&row)
+		if err != nil {
+			return false, err
+		}
+
+		if col.IsPrimaryKey || col.IsCreated || col.IsUpdated || col.IsVersion || col.IsDeleted {
+			field.Set(*oldField)
+			continue
+		}
+		if changed || col.MapType == core.ONLYFROMDB || session.engine.columnExpr(col) != "" ||
+			session.engine.isLazyColumn(col) {
+			continue
+		}
+
+		oldValue, err := session.value2Interface(col, *oldField)
+		if err != nil {
+			return false, err
+		}
+		value, err := session.value2Interface(col, *field)
+		if err != nil {
+			return false, err
+		}
+		changed = !reflect.DeepEqual(oldValue, value)
+	}
+	return changed, nil
+}
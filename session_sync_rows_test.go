@@ -0,0 +1,158 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/builder"
+)
+
+type SyncRowsPrice struct {
+	Id         int64
+	SupplierId int64  `xorm:"unique(supplier_sku)"`
+	Sku        string `xorm:"varchar(20) unique(supplier_sku)"`
+	Price      float64
+	Note       *string
+	Version    int `xorm:"version"`
+}
+
+func TestSyncRows(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SyncRowsPrice))
+
+	_, err := testEngine.Insert([]SyncRowsPrice{
+		{SupplierId: 1, Sku: "a", Price: 1},
+		{SupplierId: 1, Sku: "b", Price: 2},
+		{SupplierId: 1, Sku: "c", Price: 3},
+		{SupplierId: 2, Sku: "a", Price: 4},
+	})
+	assert.NoError(t, err)
+
+	var note = "new"
+	var prices = []SyncRowsPrice{
+		{SupplierId: 1, Sku: "a", Price: 1},
+		{SupplierId: 1, Sku: "b", Price: 20, Note: &note},
+		{SupplierId: 1, Sku: "d", Price: 5},
+	}
+	result, err := testEngine.SyncRows(&prices, builder.Eq{"supplier_id": 1}, "supplier_id", "sku")
+	assert.NoError(t, err)
+	assert.EqualValues(t, SyncRowsResult{Inserted: 1, Updated: 1, Deleted: 1}, *result)
+
+	// the primary keys of the existing rows are set
+	assert.True(t, prices[0].Id > 0)
+	assert.True(t, prices[1].Id > 0)
+	assert.EqualValues(t, 2, prices[1].Version)
+
+	var rows []SyncRowsPrice
+	assert.NoError(t, testEngine.Asc("supplier_id", "sku").Find(&rows))
+	assert.EqualValues(t, 4, len(rows))
+	assert.EqualValues(t, "a", rows[0].Sku)
+	assert.EqualValues(t, 1, rows[0].Version)
+	assert.EqualValues(t, "b", rows[1].Sku)
+	assert.EqualValues(t, 20, rows[1].Price)
+	assert.EqualValues(t, "new", *rows[1].Note)
+	assert.EqualValues(t, "d", rows[2].Sku)
+	assert.EqualValues(t, 2, rows[3].SupplierId)
+
+	// nothing is changed by syncing the same rows again
+	result, err = testEngine.SyncRows(&prices, builder.Eq{"supplier_id": 1}, "supplier_id", "sku")
+	assert.NoError(t, err)
+	assert.EqualValues(t, SyncRowsResult{}, *result)
+
+	// the rows are matched by the primary keys by default
+	var ptrs = []*SyncRowsPrice{{Id: rows[0].Id, SupplierId: 1, Sku: "a", Price: 10}}
+	result, err = testEngine.SyncRows(&ptrs, builder.Eq{"supplier_id": 1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, SyncRowsResult{Updated: 1, Deleted: 2}, *result)
+
+	cnt, err := testEngine.Count(new(SyncRowsPrice))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	// the duplicated keys are rejected and nothing is changed
+	prices = []SyncRowsPrice{{SupplierId: 2, Sku: "x"}, {SupplierId: 2, Sku: "x"}}
+	_, err = testEngine.SyncRows(&prices, builder.Eq{"supplier_id": 2}, "supplier_id", "sku")
+	assert.Error(t, err)
+	cnt, err = testEngine.Where("supplier_id = ?", 2).Count(new(SyncRowsPrice))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+}
+
+type SyncRowsSoftDeleted struct {
+	Id      int64
+	Name    string    `xorm:"unique"`
+	Deleted time.Time `xorm:"deleted"`
+}
+
+func TestSyncRowsSoftDelete(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SyncRowsSoftDeleted))
+
+	_, err := testEngine.Insert([]SyncRowsSoftDeleted{{Name: "a"}, {Name: "b"}})
+	assert.NoError(t, err)
+
+	var rows = []SyncRowsSoftDeleted{{Name: "b"}, {Name: "c"}}
+	result, err := testEngine.SyncRows(&rows, nil, "name")
+	assert.NoError(t, err)
+	assert.EqualValues(t, SyncRowsResult{Inserted: 1, Deleted: 1}, *result)
+
+	cnt, err := testEngine.Count(new(SyncRowsSoftDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	cnt, err = testEngine.Unscoped().Count(new(SyncRowsSoftDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+
+	// the soft deleted rows given again are restored
+	rows = []SyncRowsSoftDeleted{{Name: "a"}, {Name: "b"}}
+	result, err = testEngine.SyncRows(&rows, nil, "name")
+	assert.NoError(t, err)
+	assert.EqualValues(t, SyncRowsResult{Deleted: 1, Restored: 1}, *result)
+	assert.True(t, rows[0].Id > 0)
+	assert.True(t, rows[0].Deleted.IsZero())
+
+	var names []string
+	assert.NoError(t, testEngine.Table(new(SyncRowsSoftDeleted)).Asc("name").Cols("name").Find(&names))
+	assert.EqualValues(t, []string{"a", "b"}, names)
+	cnt, err = testEngine.Unscoped().Count(new(SyncRowsSoftDeleted))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+}
+
+type SyncRowsWide struct {
+	Id  int64
+	C1  string
+	C2  string
+	C3  string
+	C4  string
+	C5  string
+	C6  string
+	C7  string
+	C8  string
+	C9  string
+	C10 string
+}
+
+func TestSyncRowsBatchSize(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SyncRowsWide))
+
+	// the rows are inserted in batches under the limit of the args
+	var rows = make([]SyncRowsWide, 250)
+	for i := range rows {
+		rows[i].C1 = fmt.Sprintf("row%d", i)
+	}
+	result, err := testEngine.SyncRows(&rows, nil, "c1")
+	assert.NoError(t, err)
+	assert.EqualValues(t, SyncRowsResult{Inserted: 250}, *result)
+
+	cnt, err := testEngine.Count(new(SyncRowsWide))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 250, cnt)
+}
@@ -0,0 +1,748 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// the modes of the tree tag
+const (
+	treeParent = "parent"
+	treePath   = "path"
+)
+
+// treeInsertBatchSize is the max number of the closure rows inserted by one statement
+const treeInsertBatchSize = 300
+
+var (
+	// ErrNotTree is returned when the bean has no tree(parent) column
+	ErrNotTree = errors.New("the bean has no tree(parent) column or a single primary key")
+
+	// ErrTreeCycle is returned when moving a node under itself or its descendants
+	ErrTreeCycle = errors.New("could not move a node under itself or its descendants")
+)
+
+// treeInfo describes a table which is a tree. The tree is maintained by a
+// closure table named as the table with a _closure suffix, or by the
+// materialized path column if there is a tree(path) column.
+type treeInfo struct {
+	pkCol     *core.Column
+	parentCol *core.Column
+	pathCol   *core.Column
+}
+
+// treeInfo returns the tree info of the table, nil if it's not a tree
+func (engine *Engine) treeInfo(table *core.Table) *treeInfo {
+	if table == nil || len(table.PrimaryKeys) != 1 {
+		return nil
+	}
+
+	var info = treeInfo{pkCol: table.PKColumns()[0]}
+	for _, col := range table.Columns() {
+		extra := engine.columnExtra(col)
+		if extra == nil {
+			continue
+		}
+		switch extra.tree {
+		case treeParent:
+			info.parentCol = col
+		case treePath:
+			info.pathCol = col
+		}
+	}
+	if info.parentCol == nil {
+		return nil
+	}
+	return &info
+}
+
+// treeClosureTable returns the closure table of the tree table
+func (engine *Engine) treeClosureTable(info *treeInfo, tableName string) *core.Table {
+	table := core.NewEmptyTable()
+	table.Name = tableName + "_closure"
+
+	for _, name := range []string{"ancestor", "descendant"} {
+		col := core.NewColumn(name, "", info.pkCol.SQLType, info.pkCol.Length, info.pkCol.Length2, false)
+		col.IsPrimaryKey = true
+		table.AddColumn(col)
+	}
+	table.AddColumn(core.NewColumn("depth", "", core.SQLType{Name: core.Int}, 0, 0, false))
+
+	index := core.NewIndex("descendant", core.IndexType)
+	index.AddColumn("descendant")
+	table.AddIndex(index)
+	return table
+}
+
+// syncTreeClosure creates the closure table of the tree table if it's not exist
+func (session *Session) syncTreeClosure(table *core.Table, tableName string) error {
+	info := session.engine.treeInfo(table)
+	if info == nil || info.pathCol != nil {
+		return nil
+	}
+
+	closure := session.engine.treeClosureTable(info, tableName)
+	exist, err := session.isTableExist(closure.Name)
+	if err != nil || exist {
+		return err
+	}
+
+	if _, err := session.exec(session.engine.dialect.CreateTableSql(closure, closure.Name, "", "")); err != nil {
+		return err
+	}
+	for _, index := range closure.Indexes {
+		if _, err := session.exec(session.engine.createIndexSQL(closure.Name, index, false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// treeQuery queries the rows as values and keeps the statement
+func (session *Session) treeQuery(sqlStr string, args ...interface{}) ([][]interface{}, error) {
+	var autoReset = session.autoResetStatement
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement = autoReset
+	}()
+
+	rows, err := session.queryRows(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer session.closeRows(rows)
+
+	var results [][]interface{}
+	for rows.Next() {
+		cols, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		var values = make([]interface{}, len(cols))
+		if err := rows.ScanSlice(&values); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if bs, ok := v.([]byte); ok {
+				values[i] = string(bs)
+			}
+		}
+		results = append(results, values)
+	}
+	return results, rows.Err()
+}
+
+// treeExec executes the sql and keeps the statement
+func (session *Session) treeExec(sqlStr string, args ...interface{}) error {
+	var autoReset = session.autoResetStatement
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement = autoReset
+	}()
+
+	_, err := session.exec(sqlStr, args...)
+	return err
+}
+
+// treeKey returns the string to compare the ids
+func treeKey(id interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(id))
+	if !v.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// isTreeRoot returns true if the parent value means no parent
+func isTreeRoot(parent interface{}) bool {
+	key := treeKey(parent)
+	return key == "" || key == "0"
+}
+
+// treeNode returns the tree info, table name and the id of the node bean
+func (session *Session) treeNode(bean interface{}) (*treeInfo, string, interface{}, error) {
+	v := rValue(bean)
+	if v.Kind() != reflect.Struct {
+		return nil, "", nil, errors.New("needs a pointer to a struct")
+	}
+	table, err := session.engine.autoMapType(v)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	info := session.engine.treeInfo(table)
+	if info == nil {
+		return nil, "", nil, ErrNotTree
+	}
+
+	pk, err := session.engine.idOfV(v)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if len(pk) != 1 || isPKZero(pk) {
+		return nil, "", nil, errors.New("tree node needs the primary key")
+	}
+
+	var tableName = session.statement.TableName()
+	if tableName == "" {
+		tableName = session.engine.TableName(bean, true)
+	}
+	return info, tableName, pk[0], nil
+}
+
+// treeParentOf returns the value of the parent column of the node in database
+func (session *Session) treeParentOf(info *treeInfo, tableName string, id interface{}) (interface{}, bool, error) {
+	results, err := session.treeQuery(fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?",
+		session.engine.Quote(info.parentCol.Name), session.engine.Quote(tableName),
+		session.engine.Quote(info.pkCol.Name)), id)
+	if err != nil || len(results) == 0 {
+		return nil, false, err
+	}
+	return results[0][0], true, nil
+}
+
+// treePathOf returns the materialized path of the node
+func (session *Session) treePathOf(info *treeInfo, tableName string, id interface{}) (string, error) {
+	results, err := session.treeQuery(fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?",
+		session.engine.Quote(info.pathCol.Name), session.engine.Quote(tableName),
+		session.engine.Quote(info.pkCol.Name)), id)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", ErrNotExist
+	}
+	return treeKey(results[0][0]), nil
+}
+
+// treePathIds returns the ids in the path like /1/4/9/
+func treePathIds(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/'
+	})
+}
+
+// treeStructureParent returns the parent of the node recorded by the closure
+// table or the path
+func (session *Session) treeStructureParent(info *treeInfo, tableName string, id interface{}) (string, error) {
+	if info.pathCol != nil {
+		path, err := session.treePathOf(info, tableName, id)
+		if err != nil {
+			return "", err
+		}
+		ids := treePathIds(path)
+		if len(ids) < 2 {
+			return "", nil
+		}
+		return ids[len(ids)-2], nil
+	}
+
+	results, err := session.treeQuery(fmt.Sprintf("SELECT ancestor FROM %s WHERE descendant = ? AND depth = 1",
+		session.engine.Quote(tableName+"_closure")), id)
+	if err != nil || len(results) == 0 {
+		return "", err
+	}
+	return treeKey(results[0][0]), nil
+}
+
+// treeAfterInsert adds the inserted node to the tree
+func (session *Session) treeAfterInsert(bean interface{}) error {
+	v := rValue(bean)
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	table, err := session.engine.autoMapType(v)
+	if err != nil {
+		return err
+	}
+	info := session.engine.treeInfo(table)
+	if info == nil {
+		return nil
+	}
+	_, tableName, id, err := session.treeNode(bean)
+	if err != nil {
+		return err
+	}
+
+	parentValue, err := info.parentCol.ValueOf(bean)
+	if err != nil {
+		return err
+	}
+	var parent interface{}
+	if !isTreeRoot(parentValue.Interface()) {
+		parent = reflect.Indirect(*parentValue).Interface()
+	}
+
+	if info.pathCol != nil {
+		path, err := session.treeNewPath(info, tableName, id, parent)
+		if err != nil {
+			return err
+		}
+		if err := session.treeExec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?",
+			session.engine.Quote(tableName), session.engine.Quote(info.pathCol.Name),
+			session.engine.Quote(info.pkCol.Name)), path, id); err != nil {
+			return err
+		}
+		pathValue, err := info.pathCol.ValueOf(bean)
+		if err != nil {
+			return err
+		}
+		if pathValue.CanSet() && pathValue.Kind() == reflect.String {
+			pathValue.SetString(path)
+		}
+		return nil
+	}
+
+	closure := session.engine.Quote(tableName + "_closure")
+	if parent != nil {
+		if err := session.treeExec(fmt.Sprintf("INSERT INTO %s (ancestor, descendant, depth) SELECT ancestor, ?, depth + 1 FROM %s WHERE descendant = ?",
+			closure, closure), id, parent); err != nil {
+			return err
+		}
+	}
+	return session.treeExec(fmt.Sprintf("INSERT INTO %s (ancestor, descendant, depth) VALUES (?, ?, 0)", closure), id, id)
+}
+
+// treeNewPath returns the path of the node under the parent
+func (session *Session) treeNewPath(info *treeInfo, tableName string, id, parent interface{}) (string, error) {
+	var parentPath = "/"
+	if parent != nil {
+		var err error
+		parentPath, err = session.treePathOf(info, tableName, parent)
+		if err != nil {
+			return "", err
+		}
+	}
+	return parentPath + treeKey(id) + "/", nil
+}
+
+// treeAfterUpdate moves the node if the parent column has been changed
+func (session *Session) treeAfterUpdate(info *treeInfo, tableName string, id interface{}) error {
+	parent, has, err := session.treeParentOf(info, tableName, id)
+	if err != nil || !has {
+		return err
+	}
+	oldParent, err := session.treeStructureParent(info, tableName, id)
+	if err != nil {
+		return err
+	}
+	if isTreeRoot(parent) {
+		parent = nil
+	}
+	if treeKey(parent) == oldParent {
+		return nil
+	}
+	return session.treeMove(info, tableName, id, parent)
+}
+
+// treeSubtree returns the ids and the depths of the node and its descendants
+func (session *Session) treeSubtree(info *treeInfo, tableName string, id interface{}) ([]interface{}, []int, error) {
+	var ids []interface{}
+	var depths []int
+	if info.pathCol != nil {
+		path, err := session.treePathOf(info, tableName, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		results, err := session.treeQuery(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s LIKE ?",
+			session.engine.Quote(info.pkCol.Name), session.engine.Quote(info.pathCol.Name),
+			session.engine.Quote(tableName), session.engine.Quote(info.pathCol.Name)), path+"%")
+		if err != nil {
+			return nil, nil, err
+		}
+		var base = len(treePathIds(path))
+		for _, result := range results {
+			ids = append(ids, result[0])
+			depths = append(depths, len(treePathIds(treeKey(result[1])))-base)
+		}
+		return ids, depths, nil
+	}
+
+	results, err := session.treeQuery(fmt.Sprintf("SELECT descendant, depth FROM %s WHERE ancestor = ?",
+		session.engine.Quote(tableName+"_closure")), id)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, result := range results {
+		var depth int
+		fmt.Sscan(treeKey(result[1]), &depth)
+		ids = append(ids, result[0])
+		depths = append(depths, depth)
+	}
+	return ids, depths, nil
+}
+
+// treeMove moves the node and its descendants under the parent, nil parent
+// means the node will be a root
+func (session *Session) treeMove(info *treeInfo, tableName string, id, parent interface{}) error {
+	subtree, depths, err := session.treeSubtree(info, tableName, id)
+	if err != nil {
+		return err
+	}
+	for _, descendant := range subtree {
+		if parent != nil && treeKey(descendant) == treeKey(parent) {
+			return ErrTreeCycle
+		}
+	}
+
+	if info.pathCol != nil {
+		oldPath, err := session.treePathOf(info, tableName, id)
+		if err != nil {
+			return err
+		}
+		newPath, err := session.treeNewPath(info, tableName, id, parent)
+		if err != nil {
+			return err
+		}
+		return session.treeRewritePaths(info, tableName, oldPath, newPath)
+	}
+
+	closure := session.engine.Quote(tableName + "_closure")
+
+	// detach the subtree from the old ancestors
+	results, err := session.treeQuery(fmt.Sprintf("SELECT ancestor FROM %s WHERE descendant = ? AND depth > 0", closure), id)
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 {
+		var ancestors = make([]interface{}, 0, len(results))
+		for _, result := range results {
+			ancestors = append(ancestors, result[0])
+		}
+		for start := 0; start < len(subtree); start += treeInsertBatchSize {
+			end := start + treeInsertBatchSize
+			if end > len(subtree) {
+				end = len(subtree)
+			}
+			condSQL, condArgs, err := builder.ToSQL(builder.In("descendant", subtree[start:end]...).
+				And(builder.In("ancestor", ancestors...)))
+			if err != nil {
+				return err
+			}
+			if err := session.treeExec(fmt.Sprintf("DELETE FROM %s WHERE %s", closure, condSQL), condArgs...); err != nil {
+				return err
+			}
+		}
+	}
+
+	if parent == nil {
+		return nil
+	}
+
+	// attach the subtree to the new ancestors
+	results, err = session.treeQuery(fmt.Sprintf("SELECT ancestor, depth FROM %s WHERE descendant = ?", closure), parent)
+	if err != nil {
+		return err
+	}
+	var values []string
+	var args []interface{}
+	var flush = func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		err := session.treeExec(fmt.Sprintf("INSERT INTO %s (ancestor, descendant, depth) VALUES %s",
+			closure, strings.Join(values, ", ")), args...)
+		values, args = values[:0], args[:0]
+		return err
+	}
+	for _, result := range results {
+		var depth int
+		fmt.Sscan(treeKey(result[1]), &depth)
+		for i, descendant := range subtree {
+			values = append(values, "(?, ?, ?)")
+			args = append(args, result[0], descendant, depth+depths[i]+1)
+			if len(values) >= treeInsertBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return flush()
+}
+
+// treeRewritePaths replaces the prefix of the paths of the subtree
+func (session *Session) treeRewritePaths(info *treeInfo, tableName, oldPrefix, newPrefix string) error {
+	results, err := session.treeQuery(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s LIKE ?",
+		session.engine.Quote(info.pkCol.Name), session.engine.Quote(info.pathCol.Name),
+		session.engine.Quote(tableName), session.engine.Quote(info.pathCol.Name)), oldPrefix+"%")
+	if err != nil {
+		return err
+	}
+
+	sqlStr := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", session.engine.Quote(tableName),
+		session.engine.Quote(info.pathCol.Name), session.engine.Quote(info.pkCol.Name))
+	for _, result := range results {
+		path := treeKey(result[1])
+		if !strings.HasPrefix(path, oldPrefix) {
+			continue
+		}
+		if err := session.treeExec(sqlStr, newPrefix+path[len(oldPrefix):], result[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// treeRootValue returns the value of the parent column of the roots
+func treeRootValue(info *treeInfo) interface{} {
+	if info.parentCol.Nullable {
+		return nil
+	}
+	if info.parentCol.SQLType.IsText() {
+		return ""
+	}
+	return 0
+}
+
+// treeAfterDelete removes the deleted nodes from the tree, their children
+// will be the roots of their subtrees
+func (session *Session) treeAfterDelete(info *treeInfo, tableName string, ids []interface{}) error {
+	for start := 0; start < len(ids); start += treeInsertBatchSize {
+		end := start + treeInsertBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		condSQL, condArgs, err := builder.ToSQL(builder.In(session.engine.Quote(info.parentCol.Name), ids[start:end]...))
+		if err != nil {
+			return err
+		}
+		children, err := session.treeQuery(fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+			session.engine.Quote(info.pkCol.Name), session.engine.Quote(tableName), condSQL), condArgs...)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := session.treeMove(info, tableName, child[0], nil); err != nil {
+				return err
+			}
+			if err := session.treeExec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?",
+				session.engine.Quote(tableName), session.engine.Quote(info.parentCol.Name),
+				session.engine.Quote(info.pkCol.Name)), treeRootValue(info), child[0]); err != nil {
+				return err
+			}
+		}
+
+		if info.pathCol != nil {
+			continue
+		}
+		condSQL, condArgs, err = builder.ToSQL(builder.In("ancestor", ids[start:end]...).
+			Or(builder.In("descendant", ids[start:end]...)))
+		if err != nil {
+			return err
+		}
+		if err := session.treeExec(fmt.Sprintf("DELETE FROM %s WHERE %s",
+			session.engine.Quote(tableName+"_closure"), condSQL), condArgs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move moves the node and its descendants under the parent node, if parent
+// is nil the node will be a root.
+func (session *Session) Move(node, parent interface{}) error {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	info, tableName, id, err := session.treeNode(node)
+	if err != nil {
+		return err
+	}
+
+	var parentID interface{}
+	if parent != nil {
+		_, _, parentID, err = session.treeNode(parent)
+		if err != nil {
+			return err
+		}
+	}
+
+	var value = parentID
+	if parentID == nil {
+		value = treeRootValue(info)
+	}
+	if err := session.inTx(func() error {
+		if err := session.treeMove(info, tableName, id, parentID); err != nil {
+			return err
+		}
+		return session.treeExec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?",
+			session.engine.Quote(tableName), session.engine.Quote(info.parentCol.Name),
+			session.engine.Quote(info.pkCol.Name)), value, id)
+	}); err != nil {
+		return err
+	}
+
+	if parentValue, err := info.parentCol.ValueOf(node); err == nil && parentValue.CanSet() {
+		if parentID == nil {
+			parentValue.Set(reflect.Zero(parentValue.Type()))
+		} else if rv := reflect.ValueOf(parentID); rv.Type().ConvertibleTo(parentValue.Type()) {
+			parentValue.Set(rv.Convert(parentValue.Type()))
+		}
+	}
+	return nil
+}
+
+// Ancestors finds the ancestors of the node into beans, ordered from the root
+// to the parent.
+func (session *Session) Ancestors(node interface{}, beans interface{}) error {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	info, tableName, id, err := session.treeNode(node)
+	if err != nil {
+		return err
+	}
+
+	if info.pathCol != nil {
+		path, err := session.treePathOf(info, tableName, id)
+		if err != nil {
+			return err
+		}
+		ids := treePathIds(path)
+		ids = ids[:len(ids)-1]
+		if len(ids) == 0 {
+			return nil
+		}
+		var args = make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		if err := session.Table(tableName).In(session.engine.Quote(info.pkCol.Name), args...).find(beans); err != nil {
+			return err
+		}
+		return sortTreeBeans(session.engine, beans, func(key string) int {
+			for i, id := range ids {
+				if id == key {
+					return i
+				}
+			}
+			return len(ids)
+		})
+	}
+
+	return session.Table(tableName).
+		Join("INNER", []string{tableName + "_closure", "xorm_tree"},
+			fmt.Sprintf("%s.ancestor = %s.%s", session.engine.Quote("xorm_tree"),
+				session.engine.Quote(tableName), session.engine.Quote(info.pkCol.Name))).
+		And(fmt.Sprintf("%s.descendant = ? AND %s.depth > 0", session.engine.Quote("xorm_tree"), session.engine.Quote("xorm_tree")), id).
+		Desc("xorm_tree.depth").
+		find(beans)
+}
+
+// Descendants finds the descendants of the node into beans ordered by the
+// depth, depth limits the levels of the descendants and 0 means no limit.
+func (session *Session) Descendants(node interface{}, depth int, beans interface{}) error {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	info, tableName, id, err := session.treeNode(node)
+	if err != nil {
+		return err
+	}
+
+	if info.pathCol != nil {
+		path, err := session.treePathOf(info, tableName, id)
+		if err != nil {
+			return err
+		}
+		pathCol := session.engine.Quote(tableName) + "." + session.engine.Quote(info.pathCol.Name)
+		if err := session.Table(tableName).And(pathCol+" LIKE ?", path+"%").
+			And(pathCol+" <> ?", path).find(beans); err != nil {
+			return err
+		}
+
+		var base = len(treePathIds(path))
+		var depthOf = func(bean reflect.Value) int {
+			pathValue, err := info.pathCol.ValueOfV(&bean)
+			if err != nil {
+				return 0
+			}
+			return len(treePathIds(pathValue.String())) - base
+		}
+		return filterTreeBeans(beans, depth, depthOf)
+	}
+
+	cond := fmt.Sprintf("%s.ancestor = ? AND %s.depth > 0", session.engine.Quote("xorm_tree"), session.engine.Quote("xorm_tree"))
+	var args = []interface{}{id}
+	if depth > 0 {
+		cond += fmt.Sprintf(" AND %s.depth <= ?", session.engine.Quote("xorm_tree"))
+		args = append(args, depth)
+	}
+	return session.Table(tableName).
+		Join("INNER", []string{tableName + "_closure", "xorm_tree"},
+			fmt.Sprintf("%s.descendant = %s.%s", session.engine.Quote("xorm_tree"),
+				session.engine.Quote(tableName), session.engine.Quote(info.pkCol.Name))).
+		And(cond, args...).
+		Asc("xorm_tree.depth", tableName+"."+info.pkCol.Name).
+		find(beans)
+}
+
+// sortTreeBeans sorts the slice of beans by the order of their ids
+func sortTreeBeans(engine *Engine, beans interface{}, order func(key string) int) error {
+	slice := reflect.Indirect(reflect.ValueOf(beans))
+	var keys = make([]int, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		pk, err := engine.idOfV(slice.Index(i))
+		if err != nil {
+			return err
+		}
+		keys[i] = order(treeKey(pk[0]))
+	}
+
+	sorted := reflect.MakeSlice(slice.Type(), slice.Len(), slice.Len())
+	var idx = make([]int, slice.Len())
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return keys[idx[i]] < keys[idx[j]]
+	})
+	for i, j := range idx {
+		sorted.Index(i).Set(slice.Index(j))
+	}
+	slice.Set(sorted)
+	return nil
+}
+
+// filterTreeBeans removes the beans deeper than depth and sorts them by the depth
+func filterTreeBeans(beans interface{}, depth int, depthOf func(bean reflect.Value) int) error {
+	slice := reflect.Indirect(reflect.ValueOf(beans))
+	var depths []int
+	filtered := reflect.MakeSlice(slice.Type(), 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		d := depthOf(reflect.Indirect(slice.Index(i)))
+		if depth > 0 && d > depth {
+			continue
+		}
+		depths = append(depths, d)
+		filtered = reflect.Append(filtered, slice.Index(i))
+	}
+
+	var idx = make([]int, filtered.Len())
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return depths[idx[i]] < depths[idx[j]]
+	})
+	sorted := reflect.MakeSlice(slice.Type(), filtered.Len(), filtered.Len())
+	for i, j := range idx {
+		sorted.Index(i).Set(filtered.Index(j))
+	}
+	slice.Set(sorted)
+	return nil
+}
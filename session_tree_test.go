@@ -0,0 +1,150 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type TreeClosureNode struct {
+	Id       int64
+	ParentId int64 `xorm:"index tree(parent)"`
+	Name     string
+}
+
+type TreePathNode struct {
+	Id       int64
+	ParentId int64  `xorm:"index tree(parent)"`
+	Path     string `xorm:"varchar(255) index tree(path)"`
+	Name     string
+}
+
+func testTree(t *testing.T, bean interface{}, newNode func(parentID int64, name string) interface{}, names func(beans interface{}) []string, slice func() interface{}) {
+	assert.NoError(t, testEngine.Sync2(bean))
+
+	// root -> a -> a1 -> a1x
+	//      -> b
+	assert.NoError(t, insertTreeNode(newNode(0, "root")))
+	cnt, err := testEngine.Insert(newNode(1, "a"), newNode(1, "b"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	assert.NoError(t, insertTreeNode(newNode(2, "a1")))
+	assert.NoError(t, insertTreeNode(newNode(4, "a1x")))
+
+	var node = func(id int64) interface{} {
+		n := newNode(0, "")
+		_, err := testEngine.ID(id).Get(n)
+		assert.NoError(t, err)
+		return n
+	}
+
+	beans := slice()
+	assert.NoError(t, testEngine.Ancestors(node(5), beans))
+	assert.EqualValues(t, []string{"root", "a", "a1"}, names(beans))
+
+	beans = slice()
+	assert.NoError(t, testEngine.Descendants(node(1), 0, beans))
+	assert.EqualValues(t, []string{"a", "b", "a1", "a1x"}, names(beans))
+
+	beans = slice()
+	assert.NoError(t, testEngine.Descendants(node(1), 1, beans))
+	assert.EqualValues(t, []string{"a", "b"}, names(beans))
+
+	// move a under b
+	assert.NoError(t, testEngine.Move(node(2), node(3)))
+	beans = slice()
+	assert.NoError(t, testEngine.Ancestors(node(5), beans))
+	assert.EqualValues(t, []string{"root", "b", "a", "a1"}, names(beans))
+
+	assert.EqualValues(t, ErrTreeCycle, testEngine.Move(node(3), node(5)))
+
+	// move a1 under root by updating the parent column
+	_, err = testEngine.ID(4).Cols("parent_id").Update(newNode(1, ""))
+	assert.NoError(t, err)
+	beans = slice()
+	assert.NoError(t, testEngine.Ancestors(node(5), beans))
+	assert.EqualValues(t, []string{"root", "a1"}, names(beans))
+
+	// the children of the deleted node are roots
+	_, err = testEngine.ID(4).Delete(newNode(0, ""))
+	assert.NoError(t, err)
+	beans = slice()
+	assert.NoError(t, testEngine.Ancestors(node(5), beans))
+	assert.EqualValues(t, []string{}, names(beans))
+	beans = slice()
+	assert.NoError(t, testEngine.Descendants(node(1), 0, beans))
+	assert.EqualValues(t, []string{"b", "a"}, names(beans))
+
+	// the grandchildren keep their parents: b -> a -> a2 -> a2x
+	assert.NoError(t, insertTreeNode(newNode(2, "a2")))
+	assert.NoError(t, insertTreeNode(newNode(6, "a2x")))
+	_, err = testEngine.ID(2).Delete(newNode(0, ""))
+	assert.NoError(t, err)
+	beans = slice()
+	assert.NoError(t, testEngine.Ancestors(node(7), beans))
+	assert.EqualValues(t, []string{"a2"}, names(beans))
+	beans = slice()
+	assert.NoError(t, testEngine.Descendants(node(1), 0, beans))
+	assert.EqualValues(t, []string{"b"}, names(beans))
+	cnt, err = testEngine.Where("parent_id = ? OR parent_id IS NULL", 0).Count(newNode(0, ""))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+
+	// the nodes deleted by conditions are removed from the tree too
+	_, err = testEngine.Where("name = ?", "a2").Delete(newNode(0, ""))
+	assert.NoError(t, err)
+	beans = slice()
+	assert.NoError(t, testEngine.Ancestors(node(7), beans))
+	assert.EqualValues(t, []string{}, names(beans))
+	beans = slice()
+	assert.NoError(t, testEngine.Descendants(node(7), 0, beans))
+	assert.EqualValues(t, []string{}, names(beans))
+}
+
+func insertTreeNode(bean interface{}) error {
+	_, err := testEngine.InsertOne(bean)
+	return err
+}
+
+func TestTreeClosure(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.DropTables("tree_closure_node_closure"))
+
+	testTree(t, new(TreeClosureNode), func(parentID int64, name string) interface{} {
+		return &TreeClosureNode{ParentId: parentID, Name: name}
+	}, func(beans interface{}) []string {
+		var names = []string{}
+		for _, node := range *beans.(*[]TreeClosureNode) {
+			names = append(names, node.Name)
+		}
+		return names
+	}, func() interface{} {
+		return &[]TreeClosureNode{}
+	})
+}
+
+func TestTreePath(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	testTree(t, new(TreePathNode), func(parentID int64, name string) interface{} {
+		return &TreePathNode{ParentId: parentID, Name: name}
+	}, func(beans interface{}) []string {
+		var names = []string{}
+		for _, node := range *beans.(*[]*TreePathNode) {
+			names = append(names, node.Name)
+		}
+		return names
+	}, func() interface{} {
+		return &[]*TreePathNode{}
+	})
+
+	var node TreePathNode
+	has, err := testEngine.ID(5).Get(&node)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "/5/", node.Path)
+}
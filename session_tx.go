@@ -7,8 +7,13 @@ package xorm
 // Begin a transaction
 func (session *Session) Begin() error {
 	if session.isAutoCommit {
+		// the slot is held until the transaction is finished
+		if err := session.acquireSlot(); err != nil {
+			return err
+		}
 		tx, err := session.DB().BeginTx(session.ctx, nil)
 		if err != nil {
+			session.releaseSlot()
 			return err
 		}
 		session.isAutoCommit = false
@@ -19,12 +24,29 @@ func (session *Session) Begin() error {
 	return nil
 }
 
+// inTx runs fn in the transaction of the session, or in a new transaction
+// which is committed after fn succeeds if there is none
+func (session *Session) inTx(fn func() error) error {
+	if !session.isAutoCommit {
+		return fn()
+	}
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
 // Rollback When using transaction, you can rollback if any error
 func (session *Session) Rollback() error {
 	if !session.isAutoCommit && !session.isCommitedOrRollbacked {
 		session.saveLastSQL(session.engine.dialect.RollBackStr())
 		session.isCommitedOrRollbacked = true
 		session.isAutoCommit = true
+		defer session.releaseSlot()
 		return session.tx.Rollback()
 	}
 	return nil
@@ -36,6 +58,7 @@ func (session *Session) Commit() error {
 		session.saveLastSQL("COMMIT")
 		session.isCommitedOrRollbacked = true
 		session.isAutoCommit = true
+		defer session.releaseSlot()
 		var err error
 		if err = session.tx.Commit(); err == nil {
 			// handle processors after tx committed
@@ -41,14 +41,14 @@ func (session *Session) cacheUpdate(table *core.Table, tableName, sqlStr string,
 	}
 
 	cacher := session.engine.getCacher(tableName)
-	session.engine.logger.Debug("[cacheUpdate] get cache sql", newsql, args[nStart:])
+	session.engine.logger.Debug("[cacheUpdate] get cache sql", newsql, session.engine.redactArgs(newsql, args[nStart:]))
 	ids, err := core.GetCacheSql(cacher, tableName, newsql, args[nStart:])
 	if err != nil {
 		rows, err := session.NoCache().queryRows(newsql, args[nStart:]...)
 		if err != nil {
 			return err
 		}
-		defer rows.Close()
+		defer session.closeRows(rows)
 
 		ids = make([]core.PK, 0)
 		for rows.Next() {
@@ -204,6 +204,44 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 
 	table := session.statement.RefTable
 
+	// io.Reader fields are written by primary key after the record updated
+	var streams []blobStream
+	var streamPK core.PK
+	var streamTableName = session.statement.TableName()
+	if isStruct && session.engine.hasStreamColumn(table) {
+		streams, err = session.blobStreams(table, bean)
+		if err != nil {
+			return 0, err
+		}
+		if len(streams) > 0 {
+			if session.statement.idParam != nil {
+				streamPK = *session.statement.idParam
+			} else if streamPK, err = session.engine.idOfV(reflect.ValueOf(bean)); err != nil {
+				return 0, err
+			}
+			if len(streamPK) != len(table.PrimaryKeys) || isPKZero(streamPK) {
+				return 0, errors.New("streaming io.Reader fields needs the primary key")
+			}
+		}
+	}
+
+	// the tree node is moved by primary key if its parent column is changed
+	var tree *treeInfo
+	var treeID interface{}
+	if isStruct {
+		if tree = session.engine.treeInfo(table); tree != nil {
+			var pk = core.PK{}
+			if session.statement.idParam != nil {
+				pk = *session.statement.idParam
+			} else if pk, err = session.engine.idOfV(reflect.ValueOf(bean)); err != nil {
+				return 0, err
+			}
+			if len(pk) == 1 && !isPKZero(pk) {
+				treeID = pk[0]
+			}
+		}
+	}
+
 	if session.statement.UseAutoTime && table != nil && table.Updated != "" {
 		if !session.statement.columnMap.contain(table.Updated) &&
 			!session.statement.omitColumnMap.contain(table.Updated) {
@@ -250,6 +288,7 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		}
 	}
 
+	session.statement.mergePolicyCond()
 	if err = session.statement.processIDParam(); err != nil {
 		return 0, err
 	}
@@ -328,12 +367,13 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	// TODO: Oracle support needed
 	var top string
 	if st.LimitN > 0 {
-		if st.Engine.dialect.DBType() == core.MYSQL {
+		caps := st.Engine.Capabilities()
+		if caps.WriteLimit {
 			condSQL = condSQL + fmt.Sprintf(" LIMIT %d", st.LimitN)
-		} else if st.Engine.dialect.DBType() == core.SQLITE {
+		} else if caps.RowID != "" {
 			tempCondSQL := condSQL + fmt.Sprintf(" LIMIT %d", st.LimitN)
-			cond = cond.And(builder.Expr(fmt.Sprintf("rowid IN (SELECT rowid FROM %v %v)",
-				session.engine.Quote(tableName), tempCondSQL), condArgs...))
+			cond = cond.And(builder.Expr(fmt.Sprintf("%s IN (SELECT %s FROM %v %v)",
+				caps.RowID, caps.RowID, session.engine.Quote(tableName), tempCondSQL), condArgs...))
 			condSQL, condArgs, err = builder.ToSQL(cond)
 			if err != nil {
 				return 0, err
@@ -341,21 +381,8 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 			if len(condSQL) > 0 {
 				condSQL = "WHERE " + condSQL
 			}
-		} else if st.Engine.dialect.DBType() == core.POSTGRES {
-			tempCondSQL := condSQL + fmt.Sprintf(" LIMIT %d", st.LimitN)
-			cond = cond.And(builder.Expr(fmt.Sprintf("CTID IN (SELECT CTID FROM %v %v)",
-				session.engine.Quote(tableName), tempCondSQL), condArgs...))
-			condSQL, condArgs, err = builder.ToSQL(cond)
-			if err != nil {
-				return 0, err
-			}
-
-			if len(condSQL) > 0 {
-				condSQL = "WHERE " + condSQL
-			}
-		} else if st.Engine.dialect.DBType() == core.MSSQL {
-			if st.OrderStr != "" && st.Engine.dialect.DBType() == core.MSSQL &&
-				table != nil && len(table.PrimaryKeys) == 1 {
+		} else if caps.Limit == LimitTop {
+			if st.OrderStr != "" && table != nil && len(table.PrimaryKeys) == 1 {
 				cond = builder.Expr(fmt.Sprintf("%s IN (SELECT TOP (%d) %s FROM %v%v)",
 					table.PrimaryKeys[0], st.LimitN, table.PrimaryKeys[0],
 					session.engine.Quote(tableName), condSQL), condArgs...)
@@ -380,11 +407,10 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	var tableAlias = session.engine.Quote(tableName)
 	var fromSQL string
 	if session.statement.TableAlias != "" {
-		switch session.engine.dialect.DBType() {
-		case core.MSSQL:
+		if session.engine.Capabilities().UpdateFrom {
 			fromSQL = fmt.Sprintf("FROM %s %s ", tableAlias, session.statement.TableAlias)
 			tableAlias = session.statement.TableAlias
-		default:
+		} else {
 			tableAlias = fmt.Sprintf("%s AS %s", tableAlias, session.statement.TableAlias)
 		}
 	}
@@ -396,6 +422,16 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		fromSQL,
 		condSQL)
 
+	// the io.Reader fields and the tree are written in the same transaction as the record
+	var inTx bool
+	if (len(streams) > 0 || treeID != nil) && session.isAutoCommit {
+		if err := session.Begin(); err != nil {
+			return 0, err
+		}
+		inTx = true
+		defer session.Rollback()
+	}
+
 	res, err := session.exec(sqlStr, append(args, condArgs...)...)
 	if err != nil {
 		return 0, err
@@ -405,6 +441,22 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		}
 	}
 
+	if err = session.writeBlobStreams(streamTableName, table, streamPK, streams); err != nil {
+		return 0, err
+	}
+
+	if treeID != nil {
+		if err = session.treeAfterUpdate(tree, streamTableName, treeID); err != nil {
+			return 0, err
+		}
+	}
+
+	if inTx {
+		if err = session.Commit(); err != nil {
+			return 0, err
+		}
+	}
+
 	if cacher := session.engine.getCacher(tableName); cacher != nil && session.statement.UseCache {
 		// session.cacheUpdate(table, tableName, sqlStr, args...)
 		session.engine.logger.Debug("[cacheUpdate] clear table ", tableName)
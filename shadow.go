@@ -0,0 +1,498 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// DefaultShadowBatchSize is the number of the rows copied by one batch of ShadowMigrator
+const DefaultShadowBatchSize = 1000
+
+// ErrShadowNeedsSinglePK is returned when the table has no primary key or
+// a composite primary key
+var ErrShadowNeedsSinglePK = errors.New("shadow migration needs a single primary key")
+
+// ThrottleFunc is called before copying every batch with the number of the
+// copied rows, it could sleep to slow down the copying or return an error to
+// stop it.
+type ThrottleFunc func(ctx context.Context, copied int64) error
+
+// ShadowMigrator migrates a table to the shape of the bean without locking
+// it for a long time. It creates a shadow table from the bean, keeps the
+// shadow table in sync with the triggers on the original table, copies the
+// rows in batches ordered by the primary key and then swaps the tables
+// atomically. The original table is kept with the old name after swapping.
+//
+// The columns existing on both tables are copied, the renamedfrom tags are
+// respected and the new columns get their default values. Copying is
+// idempotent, so an interrupted Backfill could be run again from the
+// beginning.
+type ShadowMigrator struct {
+	engine     *Engine
+	bean       interface{}
+	table      *core.Table
+	tableName  string
+	shadowName string
+	oldName    string
+	batchSize  int
+	throttle   ThrottleFunc
+
+	cols    []string // the columns of the shadow table
+	srcCols []string // the related columns of the original table
+	pk      string
+	srcPK   string
+
+	copied   int64
+	paused   int32
+	resumeCh chan struct{}
+}
+
+// NewShadowMigrator creates a ShadowMigrator to migrate the bean's table
+func (engine *Engine) NewShadowMigrator(bean interface{}) (*ShadowMigrator, error) {
+	v := rValue(bean)
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("needs a pointer to a struct")
+	}
+	table, err := engine.autoMapType(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.PrimaryKeys) != 1 {
+		return nil, ErrShadowNeedsSinglePK
+	}
+
+	tableName := engine.TableName(bean)
+	return &ShadowMigrator{
+		engine:     engine,
+		bean:       bean,
+		table:      table,
+		tableName:  tableName,
+		shadowName: engine.truncateName(tableName + "_shadow"),
+		oldName:    engine.truncateName(tableName + "_old"),
+		batchSize:  DefaultShadowBatchSize,
+		resumeCh:   make(chan struct{}, 1),
+	}, nil
+}
+
+// BatchSize sets the number of the rows copied by one batch
+func (m *ShadowMigrator) BatchSize(size int) *ShadowMigrator {
+	if size > 0 {
+		m.batchSize = size
+	}
+	return m
+}
+
+// Throttle sets the function called before copying every batch
+func (m *ShadowMigrator) Throttle(fun ThrottleFunc) *ShadowMigrator {
+	m.throttle = fun
+	return m
+}
+
+// OldTableName sets the name of the original table after swapping, the
+// default is the table name with the suffix _old.
+func (m *ShadowMigrator) OldTableName(name string) *ShadowMigrator {
+	m.oldName = name
+	return m
+}
+
+// Copied returns the number of the rows copied by Backfill
+func (m *ShadowMigrator) Copied() int64 {
+	return atomic.LoadInt64(&m.copied)
+}
+
+// Pause pauses Backfill before the next batch
+func (m *ShadowMigrator) Pause() {
+	atomic.StoreInt32(&m.paused, 1)
+}
+
+// Resume resumes the paused Backfill
+func (m *ShadowMigrator) Resume() {
+	atomic.StoreInt32(&m.paused, 0)
+	select {
+	case m.resumeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Run migrates the table, it's Start, Backfill and Swap.
+func (m *ShadowMigrator) Run(ctx context.Context) error {
+	if err := m.Start(); err != nil {
+		return err
+	}
+	if err := m.Backfill(ctx); err != nil {
+		return err
+	}
+	return m.Swap()
+}
+
+// Start creates the shadow table and the triggers which copy the changes of
+// the original table to the shadow table.
+func (m *ShadowMigrator) Start() error {
+	if err := m.mapColumns(); err != nil {
+		return err
+	}
+
+	exist, err := m.engine.IsTableExist(m.shadowName)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		if err = m.engine.Table(m.shadowName).CreateTable(m.bean); err != nil {
+			return err
+		}
+		if err = m.engine.Table(m.shadowName).CreateIndexes(m.bean); err != nil {
+			return err
+		}
+		if err = m.engine.Table(m.shadowName).CreateUniques(m.bean); err != nil {
+			return err
+		}
+	}
+
+	for _, sqlStr := range append(m.dropTriggersSQL(), m.createTriggersSQL()...) {
+		if _, err := m.engine.Exec(sqlStr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapColumns finds the columns of the original table to be copied
+func (m *ShadowMigrator) mapColumns() error {
+	engine := m.engine
+	switch engine.dialect.DBType() {
+	case core.SQLITE, core.MYSQL, core.POSTGRES, core.MSSQL:
+	default:
+		return ErrNotImplemented
+	}
+
+	colSeq, _, err := engine.dialect.GetColumns(m.tableName)
+	if err != nil {
+		return err
+	}
+	var findColumn = func(name string) string {
+		for _, colName := range colSeq {
+			if strings.EqualFold(colName, name) {
+				return colName
+			}
+		}
+		return ""
+	}
+
+	m.cols, m.srcCols = nil, nil
+	for _, col := range m.table.Columns() {
+		if engine.columnExpr(col) != "" {
+			continue
+		}
+		src := findColumn(col.Name)
+		if src == "" {
+			src = findColumn(engine.columnRenamedFrom(col))
+		}
+		if src == "" {
+			continue
+		}
+		m.cols = append(m.cols, col.Name)
+		m.srcCols = append(m.srcCols, src)
+		if col.IsPrimaryKey {
+			m.pk, m.srcPK = col.Name, src
+		}
+	}
+	if m.pk == "" {
+		return errors.New("the primary key doesn't exist on the original table")
+	}
+	return nil
+}
+
+// quoteCols quotes the columns with the prefix
+func (m *ShadowMigrator) quoteCols(prefix string, cols []string) string {
+	var quoted = make([]string, 0, len(cols))
+	for _, col := range cols {
+		quoted = append(quoted, prefix+m.engine.Quote(col))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// triggerName returns the name of the trigger, it's truncated to the max
+// length of the database like the shadow table's name
+func (m *ShadowMigrator) triggerName(suffix string) string {
+	return m.engine.truncateName("xorm_shadow_" + m.tableName + "_" + suffix)
+}
+
+// identityInsert wraps the sql with SET IDENTITY_INSERT on mssql, so the
+// primary keys could be copied to the autoincrement column
+func (m *ShadowMigrator) identityInsert(sqlStr string) string {
+	if m.engine.dialect.DBType() != core.MSSQL || m.table.AutoIncrement == "" {
+		return sqlStr
+	}
+	shadow := m.engine.Quote(m.engine.TableName(m.shadowName, true))
+	return fmt.Sprintf("SET IDENTITY_INSERT %s ON; %s; SET IDENTITY_INSERT %s OFF", shadow, sqlStr, shadow)
+}
+
+// createTriggersSQL returns the sqls to create the triggers on the original table
+func (m *ShadowMigrator) createTriggersSQL() []string {
+	engine := m.engine
+	table := engine.Quote(engine.TableName(m.tableName, true))
+	shadow := engine.Quote(engine.TableName(m.shadowName, true))
+	cols := m.quoteCols("", m.cols)
+	pk := engine.Quote(m.pk)
+	srcPK := engine.Quote(m.srcPK)
+
+	switch engine.dialect.DBType() {
+	case core.SQLITE:
+		insert := fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s);", shadow, cols, m.quoteCols("NEW.", m.srcCols))
+		del := fmt.Sprintf("DELETE FROM %s WHERE %s = OLD.%s;", shadow, pk, srcPK)
+		return []string{
+			fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT ON %s BEGIN %s END", engine.Quote(m.triggerName("ins")), table, insert),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON %s BEGIN %s %s END", engine.Quote(m.triggerName("upd")), table, del, insert),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER DELETE ON %s BEGIN %s END", engine.Quote(m.triggerName("del")), table, del),
+		}
+	case core.MYSQL:
+		insert := fmt.Sprintf("REPLACE INTO %s (%s) VALUES (%s);", shadow, cols, m.quoteCols("NEW.", m.srcCols))
+		del := fmt.Sprintf("DELETE FROM %s WHERE %s = OLD.%s;", shadow, pk, srcPK)
+		return []string{
+			fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT ON %s FOR EACH ROW %s", engine.Quote(m.triggerName("ins")), table, insert),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON %s FOR EACH ROW BEGIN %s %s END", engine.Quote(m.triggerName("upd")), table, del, insert),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER DELETE ON %s FOR EACH ROW %s", engine.Quote(m.triggerName("del")), table, del),
+		}
+	case core.POSTGRES:
+		var sets []string
+		for _, col := range m.cols {
+			if col != m.pk {
+				sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", engine.Quote(col), engine.Quote(col)))
+			}
+		}
+		var conflict = "DO NOTHING"
+		if len(sets) > 0 {
+			conflict = "DO UPDATE SET " + strings.Join(sets, ", ")
+		}
+		fn := engine.Quote(engine.TableName(m.triggerName("fn"), true))
+		return []string{
+			fmt.Sprintf("CREATE OR REPLACE FUNCTION %s() RETURNS trigger AS $$ BEGIN "+
+				"IF TG_OP <> 'INSERT' THEN DELETE FROM %s WHERE %s = OLD.%s; END IF; "+
+				"IF TG_OP <> 'DELETE' THEN INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s; END IF; "+
+				"RETURN NULL; END $$ LANGUAGE plpgsql", fn, shadow, pk, srcPK, shadow, cols, m.quoteCols("NEW.", m.srcCols), pk, conflict),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE PROCEDURE %s()",
+				engine.Quote(m.triggerName("trg")), table, fn),
+		}
+	case core.MSSQL:
+		return []string{
+			fmt.Sprintf("CREATE TRIGGER %s ON %s AFTER INSERT, UPDATE, DELETE AS BEGIN SET NOCOUNT ON; "+
+				"DELETE FROM %s WHERE %s IN (SELECT %s FROM deleted UNION SELECT %s FROM inserted); %s; END",
+				engine.Quote(m.triggerName("trg")), table, shadow, pk, srcPK, srcPK,
+				m.identityInsert(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM inserted", shadow, cols, m.quoteCols("", m.srcCols)))),
+		}
+	}
+	return nil
+}
+
+// dropTriggersSQL returns the sqls to drop the triggers
+func (m *ShadowMigrator) dropTriggersSQL() []string {
+	engine := m.engine
+	switch engine.dialect.DBType() {
+	case core.SQLITE, core.MYSQL:
+		var sqls []string
+		for _, suffix := range []string{"ins", "upd", "del"} {
+			sqls = append(sqls, "DROP TRIGGER IF EXISTS "+engine.Quote(m.triggerName(suffix)))
+		}
+		return sqls
+	case core.POSTGRES:
+		return []string{
+			"DROP FUNCTION IF EXISTS " + engine.Quote(engine.TableName(m.triggerName("fn"), true)) + "() CASCADE",
+		}
+	case core.MSSQL:
+		return []string{"DROP TRIGGER IF EXISTS " + engine.Quote(m.triggerName("trg"))}
+	}
+	return nil
+}
+
+// copySQL returns the sql to copy the rows whose primary keys are in the range,
+// the rows already copied by the triggers are ignored.
+func (m *ShadowMigrator) copySQL(cond builder.Cond) (string, []interface{}, error) {
+	engine := m.engine
+	table := engine.Quote(engine.TableName(m.tableName, true))
+	shadow := engine.Quote(engine.TableName(m.shadowName, true))
+	cols := m.quoteCols("", m.cols)
+	pk := engine.Quote(m.pk)
+	srcPK := engine.Quote(m.srcPK)
+
+	if engine.dialect.DBType() == core.MSSQL {
+		cond = cond.And(builder.Expr(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s.%s = %s.%s)", shadow, shadow, pk, table, srcPK)))
+	}
+	where, args, err := builder.ToSQL(cond)
+	if err != nil {
+		return "", nil, err
+	}
+
+	selectStr := fmt.Sprintf("SELECT %s FROM %s WHERE %s", m.quoteCols("", m.srcCols), table, where)
+	switch engine.dialect.DBType() {
+	case core.SQLITE, core.POSTGRES:
+		return fmt.Sprintf("INSERT INTO %s (%s) %s ON CONFLICT (%s) DO NOTHING", shadow, cols, selectStr, pk), args, nil
+	case core.MYSQL:
+		return fmt.Sprintf("INSERT IGNORE INTO %s (%s) %s", shadow, cols, selectStr), args, nil
+	}
+	return m.identityInsert(fmt.Sprintf("INSERT INTO %s (%s) %s", shadow, cols, selectStr)), args, nil
+}
+
+// waitResume blocks until the migrator is resumed or the context is done
+func (m *ShadowMigrator) waitResume(ctx context.Context) error {
+	for atomic.LoadInt32(&m.paused) == 1 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.resumeCh:
+		}
+	}
+	return nil
+}
+
+// Backfill copies the rows of the original table to the shadow table in
+// batches ordered by the primary key.
+func (m *ShadowMigrator) Backfill(ctx context.Context) error {
+	if m.cols == nil {
+		if err := m.mapColumns(); err != nil {
+			return err
+		}
+	}
+
+	atomic.StoreInt64(&m.copied, 0)
+	srcPK := m.engine.Quote(m.srcPK)
+	var last interface{}
+	for {
+		if err := m.waitResume(ctx); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if m.throttle != nil {
+			if err := m.throttle(ctx, m.Copied()); err != nil {
+				return err
+			}
+		}
+
+		var lower = builder.NewCond()
+		if last != nil {
+			lower = builder.Gt{srcPK: last}
+		}
+		rows, err := m.engine.Table(m.tableName).Context(ctx).Select(srcPK).Where(lower).
+			OrderBy(srcPK).Limit(m.batchSize).QueryInterface()
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return m.syncSequence(ctx)
+		}
+		upper := rows[len(rows)-1][m.srcPK]
+
+		sqlStr, args, err := m.copySQL(lower.And(builder.Lte{srcPK: upper}))
+		if err != nil {
+			return err
+		}
+		if _, err = m.engine.Context(ctx).Exec(append([]interface{}{sqlStr}, args...)...); err != nil {
+			return err
+		}
+		atomic.AddInt64(&m.copied, int64(len(rows)))
+
+		if len(rows) < m.batchSize {
+			return m.syncSequence(ctx)
+		}
+		last = upper
+	}
+}
+
+// syncSequenceSQL returns the sql to move the sequence of the shadow table's
+// serial primary key to the max copied primary key, since the rows are
+// copied with their primary keys and the sequence isn't advanced by them.
+// It's empty if the database has no such sequences.
+func (m *ShadowMigrator) syncSequenceSQL() string {
+	engine := m.engine
+	if engine.dialect.DBType() != core.POSTGRES || m.table.AutoIncrement == "" {
+		return ""
+	}
+	shadow := engine.Quote(engine.TableName(m.shadowName, true))
+	return fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), MAX(%s)) FROM %s",
+		shadow, m.pk, engine.Quote(m.pk), shadow)
+}
+
+// syncSequence moves the sequence of the shadow table after copying the rows
+func (m *ShadowMigrator) syncSequence(ctx context.Context) error {
+	sqlStr := m.syncSequenceSQL()
+	if sqlStr == "" {
+		return nil
+	}
+	_, err := m.engine.Context(ctx).Exec(sqlStr)
+	return err
+}
+
+// Swap renames the original table to the old name and the shadow table to
+// the table name atomically, then drops the triggers.
+func (m *ShadowMigrator) Swap() error {
+	engine := m.engine
+	table := engine.TableName(m.tableName, true)
+	shadow := engine.TableName(m.shadowName, true)
+
+	if !engine.Capabilities().TransactionalDDL {
+		// the renames could not be rolled back, RENAME TABLE of mysql renames
+		// multiple tables atomically
+		sqlStr := fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", engine.Quote(table),
+			engine.Quote(engine.TableName(m.oldName, true)), engine.Quote(shadow), engine.Quote(table))
+		if _, err := engine.Exec(sqlStr); err != nil {
+			return err
+		}
+		return m.dropTriggers()
+	}
+
+	session := engine.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return err
+	}
+
+	var sqls [][]interface{}
+	if sqlStr := m.syncSequenceSQL(); sqlStr != "" {
+		// the rows copied by the triggers after Backfill
+		sqls = append(sqls, []interface{}{sqlStr})
+	}
+	for _, names := range [][2]string{{table, m.oldName}, {shadow, m.tableName}} {
+		sqlStr, args := engine.RenameTableSQL(names[0], names[1])
+		sqls = append(sqls, append([]interface{}{sqlStr}, args...))
+	}
+	for _, sqlStr := range m.dropTriggersSQL() {
+		sqls = append(sqls, []interface{}{sqlStr})
+	}
+	for _, sqlOrArgs := range sqls {
+		if _, err := session.Exec(sqlOrArgs...); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+	return session.Commit()
+}
+
+// dropTriggers drops the triggers on the original table
+func (m *ShadowMigrator) dropTriggers() error {
+	for _, sqlStr := range m.dropTriggersSQL() {
+		if _, err := m.engine.Exec(sqlStr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cleanup aborts the migration, it drops the triggers and the shadow table
+func (m *ShadowMigrator) Cleanup() error {
+	if err := m.dropTriggers(); err != nil {
+		return err
+	}
+	return m.engine.DropTables(m.shadowName)
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+type ShadowUserV1 struct {
+	Id   int64
+	Name string
+	Age  int
+}
+
+func (ShadowUserV1) TableName() string {
+	return "shadow_user"
+}
+
+type ShadowUserV2 struct {
+	Id       int64
+	FullName string `xorm:"varchar(100) index renamedfrom(name)"`
+	Age      int64
+	Note     string `xorm:"varchar(20) notnull default 'none'"`
+}
+
+func (ShadowUserV2) TableName() string {
+	return "shadow_user"
+}
+
+func TestShadowMigrator(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	switch testEngine.Dialect().DBType() {
+	case core.SQLITE, core.MYSQL, core.POSTGRES, core.MSSQL:
+	default:
+		t.Skip("shadow migration is not supported")
+	}
+	assert.NoError(t, testEngine.DropTables("shadow_user", "shadow_user_old", "shadow_user_shadow"))
+	assertSync(t, new(ShadowUserV1))
+
+	var users = make([]ShadowUserV1, 0, 95)
+	for i := 0; i < 95; i++ {
+		users = append(users, ShadowUserV1{Name: fmt.Sprintf("user%d", i), Age: i})
+	}
+	_, err := testEngine.Insert(&users)
+	assert.NoError(t, err)
+
+	migrator, err := testEngine.(*Engine).NewShadowMigrator(new(ShadowUserV2))
+	assert.NoError(t, err)
+
+	var batches int
+	migrator.BatchSize(20).Throttle(func(ctx context.Context, copied int64) error {
+		batches++
+		if batches == 2 {
+			// the changes during the migration are copied by the triggers
+			_, err := testEngine.Insert(&ShadowUserV1{Name: "new", Age: 100})
+			assert.NoError(t, err)
+			_, err = testEngine.ID(3).Cols("name").Update(&ShadowUserV1{Name: "changed"})
+			assert.NoError(t, err)
+			_, err = testEngine.ID(50).Delete(new(ShadowUserV1))
+			assert.NoError(t, err)
+
+			migrator.Pause()
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				migrator.Resume()
+			}()
+		}
+		return nil
+	})
+
+	assert.NoError(t, migrator.Run(context.Background()))
+	assert.EqualValues(t, 5, batches)
+	assert.EqualValues(t, 95, migrator.Copied())
+
+	exist, err := testEngine.IsTableExist("shadow_user_old")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = testEngine.IsTableExist("shadow_user_shadow")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	cnt, err := testEngine.Count(new(ShadowUserV2))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 95, cnt)
+
+	var user ShadowUserV2
+	has, err := testEngine.ID(3).Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, ShadowUserV2{Id: 3, FullName: "changed", Age: 2, Note: "none"}, user)
+
+	has, err = testEngine.ID(50).Get(new(ShadowUserV2))
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	has, err = testEngine.Where("full_name = ?", "new").Get(new(ShadowUserV2))
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	// the triggers are dropped, the writes only go to the new table
+	_, err = testEngine.Insert(&ShadowUserV2{FullName: "after", Age: 1, Note: "x"})
+	assert.NoError(t, err)
+	cnt, err = testEngine.Table("shadow_user_old").Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 95, cnt)
+}
+
+func TestShadowMigratorCleanup(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	if testEngine.Dialect().DBType() != core.SQLITE {
+		t.Skip()
+	}
+	assert.NoError(t, testEngine.DropTables("shadow_user", "shadow_user_old", "shadow_user_shadow"))
+	assertSync(t, new(ShadowUserV1))
+
+	migrator, err := testEngine.(*Engine).NewShadowMigrator(new(ShadowUserV2))
+	assert.NoError(t, err)
+	assert.NoError(t, migrator.Start())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	migrator.Pause()
+	cancel()
+	assert.EqualValues(t, context.Canceled, migrator.Backfill(ctx))
+
+	assert.NoError(t, migrator.Cleanup())
+	exist, err := testEngine.IsTableExist("shadow_user_shadow")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	_, err = testEngine.Insert(&ShadowUserV1{Name: "a"})
+	assert.NoError(t, err)
+}
+
+func TestShadowMigratorLongNames(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	if testEngine.Dialect().DBType() != core.SQLITE {
+		t.Skip()
+	}
+	engine := testEngine.(*Engine)
+	assert.NoError(t, engine.DropTables("shadow_user"))
+	assertSync(t, new(ShadowUserV1))
+	_, err := engine.Insert(&ShadowUserV1{Name: "a"})
+	assert.NoError(t, err)
+
+	// the names of the shadow table, the old table and the triggers are truncated
+	engine.SetMaxIdentifierLength(16)
+	defer engine.SetMaxIdentifierLength(0)
+	migrator, err := engine.NewShadowMigrator(new(ShadowUserV2))
+	assert.NoError(t, err)
+	for _, name := range []string{migrator.shadowName, migrator.oldName, migrator.triggerName("ins"), migrator.triggerName("upd")} {
+		assert.True(t, len(name) <= 16, name)
+	}
+	assert.NotEqual(t, migrator.triggerName("ins"), migrator.triggerName("upd"))
+	defer engine.DropTables(migrator.oldName)
+
+	assert.NoError(t, migrator.Run(context.Background()))
+	exist, err := engine.IsTableExist(migrator.oldName)
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	cnt, err := engine.Count(new(ShadowUserV2))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+}
+
+func TestShadowMigratorSequence(t *testing.T) {
+	dialect := new(postgres)
+	assert.NoError(t, dialect.Init(nil, &core.Uri{DbType: core.POSTGRES}, "", ""))
+	table := core.NewEmptyTable()
+	table.AutoIncrement = "id"
+	migrator := &ShadowMigrator{
+		engine:     &Engine{dialect: dialect},
+		table:      table,
+		tableName:  "user",
+		shadowName: "user_shadow",
+		pk:         "id",
+	}
+
+	// the serial sequence of the shadow table is moved past the copied ids
+	assert.EqualValues(t, `SELECT setval(pg_get_serial_sequence('"user_shadow"', 'id'), MAX("id")) FROM "user_shadow"`,
+		migrator.syncSequenceSQL())
+
+	table.AutoIncrement = ""
+	assert.EqualValues(t, "", migrator.syncSequenceSQL())
+}
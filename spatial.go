@@ -0,0 +1,642 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+// the spatial sql types, the length of the type is the SRID, i.e. point(4326)
+const (
+	Geometry  = "GEOMETRY"
+	Geography = "GEOGRAPHY"
+	PointType = "POINT"
+	PolyType  = "POLYGON"
+)
+
+// SpatialIndexType is the type of the spatial indexes created by the spatial tag
+const SpatialIndexType = core.UniqueType + 1
+
+// the wkb geometry types
+const (
+	wkbPoint   uint32 = 1
+	wkbPolygon uint32 = 3
+
+	ewkbSRIDFlag uint32 = 0x20000000
+)
+
+var (
+	// ErrInvalidWKB is returned when decoding an invalid well-known binary
+	ErrInvalidWKB = errors.New("invalid wkb")
+	// ErrInvalidWKT is returned when parsing an invalid well-known text
+	ErrInvalidWKT = errors.New("invalid wkt")
+)
+
+// RegisterSpatialTypes enables the spatial types as the tags of the engine,
+// i.e. `xorm:"point(4326) spatial"`. They are not tags by default since the
+// columns named point or polygon would be taken as the types, the fields of
+// GeometryValue types are mapped as geometry columns without the tags. It
+// should be called before the structs are mapped.
+func (engine *Engine) RegisterSpatialTypes() {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	var handlers = make(map[string]tagHandler, len(engine.tagHandlers)+4)
+	for name, handler := range engine.tagHandlers {
+		handlers[name] = handler
+	}
+	for _, name := range []string{Geometry, Geography, PointType, PolyType} {
+		handlers[name] = SQLTypeTagHandler
+	}
+	engine.tagHandlers = handlers
+}
+
+// isSpatialType returns true if the sql type is a spatial type
+func isSpatialType(tp core.SQLType) bool {
+	switch strings.ToUpper(tp.Name) {
+	case Geometry, Geography, PointType, PolyType:
+		return true
+	}
+	return false
+}
+
+// spatialSQLType returns the column type of the spatial column on the
+// database, spatial columns are stored as WKB blobs if the database has no
+// spatial types.
+func spatialSQLType(dbType core.DbType, c *core.Column) string {
+	var name = strings.ToUpper(c.SQLType.Name)
+	switch dbType {
+	case core.MYSQL:
+		if name == Geography {
+			name = Geometry
+		}
+		if c.Length > 0 {
+			return fmt.Sprintf("%s SRID %d", name, c.Length)
+		}
+		return name
+	case core.POSTGRES:
+		var tp = "geometry"
+		if name == Geography {
+			tp = "geography"
+		}
+		var sub = "Geometry"
+		switch name {
+		case PointType:
+			sub = "Point"
+		case PolyType:
+			sub = "Polygon"
+		}
+		if c.Length > 0 {
+			return fmt.Sprintf("%s(%s,%d)", tp, sub, c.Length)
+		}
+		if sub != "Geometry" {
+			return fmt.Sprintf("%s(%s)", tp, sub)
+		}
+		return tp
+	case core.MSSQL:
+		if name == Geometry {
+			return "GEOMETRY"
+		}
+		return "GEOGRAPHY"
+	}
+	return core.Blob
+}
+
+// GeometryValue is a spatial value which could be encoded as the well-known
+// binary and the well-known text
+type GeometryValue interface {
+	WKB() []byte
+	WKT() string
+}
+
+// geometryUnmarshaler is a spatial value which could be decoded
+type geometryUnmarshaler interface {
+	UnmarshalWKB(data []byte) error
+	UnmarshalWKT(s string) error
+}
+
+var (
+	tpGeometryValue       = reflect.TypeOf((*GeometryValue)(nil)).Elem()
+	tpGeometryUnmarshaler = reflect.TypeOf((*geometryUnmarshaler)(nil)).Elem()
+)
+
+// Point is a spatial point, X is the longitude and Y is the latitude if
+// it's a geography point
+type Point struct {
+	X, Y float64
+}
+
+// Polygon is a spatial polygon, the first ring is the exterior ring and
+// the others are the holes. A ring is closed, its first and last points are
+// the same.
+type Polygon [][]Point
+
+// wkbWriter writes the well-known binary in little endian
+type wkbWriter struct {
+	bytes.Buffer
+}
+
+func (w *wkbWriter) uint32(v uint32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	w.Write(buf[:])
+}
+
+func (w *wkbWriter) point(p Point) {
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], math.Float64bits(p.X))
+	binary.LittleEndian.PutUint64(buf[8:], math.Float64bits(p.Y))
+	w.Write(buf[:])
+}
+
+// header writes the byte order, the type and the SRID if it's not zero
+func (w *wkbWriter) header(tp uint32, srid int) {
+	w.WriteByte(1)
+	if srid > 0 {
+		w.uint32(tp | ewkbSRIDFlag)
+		w.uint32(uint32(srid))
+		return
+	}
+	w.uint32(tp)
+}
+
+// WKB returns the well-known binary of the point
+func (p Point) WKB() []byte {
+	return p.ewkb(0)
+}
+
+func (p Point) ewkb(srid int) []byte {
+	var w wkbWriter
+	w.header(wkbPoint, srid)
+	w.point(p)
+	return w.Bytes()
+}
+
+// WKT returns the well-known text of the point
+func (p Point) WKT() string {
+	return "POINT(" + formatWKTPoint(p) + ")"
+}
+
+// WKB returns the well-known binary of the polygon
+func (poly Polygon) WKB() []byte {
+	return poly.ewkb(0)
+}
+
+func (poly Polygon) ewkb(srid int) []byte {
+	var w wkbWriter
+	w.header(wkbPolygon, srid)
+	w.uint32(uint32(len(poly)))
+	for _, ring := range poly {
+		w.uint32(uint32(len(ring)))
+		for _, p := range ring {
+			w.point(p)
+		}
+	}
+	return w.Bytes()
+}
+
+// WKT returns the well-known text of the polygon
+func (poly Polygon) WKT() string {
+	var rings = make([]string, 0, len(poly))
+	for _, ring := range poly {
+		var points = make([]string, 0, len(ring))
+		for _, p := range ring {
+			points = append(points, formatWKTPoint(p))
+		}
+		rings = append(rings, "("+strings.Join(points, ",")+")")
+	}
+	return "POLYGON(" + strings.Join(rings, ",") + ")"
+}
+
+func formatWKTPoint(p Point) string {
+	return strconv.FormatFloat(p.X, 'f', -1, 64) + " " + strconv.FormatFloat(p.Y, 'f', -1, 64)
+}
+
+// ewkbOf returns the extended well-known binary with the SRID
+func ewkbOf(g GeometryValue, srid int) []byte {
+	switch t := g.(type) {
+	case Point:
+		return t.ewkb(srid)
+	case *Point:
+		return t.ewkb(srid)
+	case Polygon:
+		return t.ewkb(srid)
+	case *Polygon:
+		return t.ewkb(srid)
+	}
+	return g.WKB()
+}
+
+// wkbReader reads the well-known binary
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.data) < 4 {
+		return 0, ErrInvalidWKB
+	}
+	v := r.order.Uint32(r.data)
+	r.data = r.data[4:]
+	return v, nil
+}
+
+func (r *wkbReader) point() (Point, error) {
+	if len(r.data) < 16 {
+		return Point{}, ErrInvalidWKB
+	}
+	p := Point{
+		X: math.Float64frombits(r.order.Uint64(r.data)),
+		Y: math.Float64frombits(r.order.Uint64(r.data[8:])),
+	}
+	r.data = r.data[16:]
+	return p, nil
+}
+
+// header reads the byte order and the type, the SRID of EWKB is skipped
+func (r *wkbReader) header() (uint32, error) {
+	if len(r.data) < 5 {
+		return 0, ErrInvalidWKB
+	}
+	switch r.data[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return 0, ErrInvalidWKB
+	}
+	r.data = r.data[1:]
+
+	tp, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if tp&ewkbSRIDFlag != 0 {
+		if _, err := r.uint32(); err != nil {
+			return 0, err
+		}
+	}
+	return tp &^ ewkbSRIDFlag, nil
+}
+
+// UnmarshalWKB decodes the well-known binary or the extended one of PostGIS
+func (p *Point) UnmarshalWKB(data []byte) error {
+	r := wkbReader{data: data}
+	tp, err := r.header()
+	if err != nil {
+		return err
+	}
+	if tp != wkbPoint {
+		return fmt.Errorf("wkb type %d is not a point", tp)
+	}
+	*p, err = r.point()
+	return err
+}
+
+// UnmarshalWKB decodes the well-known binary or the extended one of PostGIS
+func (poly *Polygon) UnmarshalWKB(data []byte) error {
+	r := wkbReader{data: data}
+	tp, err := r.header()
+	if err != nil {
+		return err
+	}
+	if tp != wkbPolygon {
+		return fmt.Errorf("wkb type %d is not a polygon", tp)
+	}
+
+	numRings, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	// every ring needs 4 bytes at least
+	if int(numRings) > len(r.data)/4 {
+		return ErrInvalidWKB
+	}
+	var rings = make(Polygon, 0, numRings)
+	for i := uint32(0); i < numRings; i++ {
+		numPoints, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		if int(numPoints) > len(r.data)/16 {
+			return ErrInvalidWKB
+		}
+		var ring = make([]Point, 0, numPoints)
+		for j := uint32(0); j < numPoints; j++ {
+			p, err := r.point()
+			if err != nil {
+				return err
+			}
+			ring = append(ring, p)
+		}
+		rings = append(rings, ring)
+	}
+	*poly = rings
+	return nil
+}
+
+// trimWKT removes the SRID prefix and the type name of the well-known text
+// and returns the content in the outer parentheses
+func trimWKT(s, typeName string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToUpper(s), "SRID=") {
+		i := strings.Index(s, ";")
+		if i < 0 {
+			return "", ErrInvalidWKT
+		}
+		s = strings.TrimSpace(s[i+1:])
+	}
+	if !strings.HasPrefix(strings.ToUpper(s), typeName) {
+		return "", ErrInvalidWKT
+	}
+	s = strings.TrimSpace(s[len(typeName):])
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return "", ErrInvalidWKT
+	}
+	return strings.TrimSpace(s[1 : len(s)-1]), nil
+}
+
+func parseWKTPoint(s string) (Point, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Point{}, ErrInvalidWKT
+	}
+	x, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Point{}, ErrInvalidWKT
+	}
+	y, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Point{}, ErrInvalidWKT
+	}
+	return Point{x, y}, nil
+}
+
+// UnmarshalWKT parses the well-known text like POINT(1 2)
+func (p *Point) UnmarshalWKT(s string) error {
+	content, err := trimWKT(s, PointType)
+	if err != nil {
+		return err
+	}
+	*p, err = parseWKTPoint(content)
+	return err
+}
+
+// UnmarshalWKT parses the well-known text like POLYGON((0 0,1 0,1 1,0 0))
+func (poly *Polygon) UnmarshalWKT(s string) error {
+	content, err := trimWKT(s, PolyType)
+	if err != nil {
+		return err
+	}
+
+	var rings Polygon
+	for len(content) > 0 {
+		if content[0] != '(' {
+			return ErrInvalidWKT
+		}
+		end := strings.Index(content, ")")
+		if end < 0 {
+			return ErrInvalidWKT
+		}
+		var ring []Point
+		for _, s := range strings.Split(content[1:end], ",") {
+			p, err := parseWKTPoint(s)
+			if err != nil {
+				return err
+			}
+			ring = append(ring, p)
+		}
+		rings = append(rings, ring)
+
+		content = strings.TrimSpace(content[end+1:])
+		if strings.HasPrefix(content, ",") {
+			content = strings.TrimSpace(content[1:])
+		} else if len(content) > 0 {
+			return ErrInvalidWKT
+		}
+	}
+	*poly = rings
+	return nil
+}
+
+// unmarshalGeometry decodes the hex EWKB of PostGIS, the WKB or the WKT
+func unmarshalGeometry(g geometryUnmarshaler, data []byte) error {
+	if len(data) == 0 {
+		return ErrInvalidWKB
+	}
+	if data[0] == '0' && len(data)%2 == 0 {
+		if bs, err := hex.DecodeString(string(data)); err == nil {
+			data = bs
+		}
+	}
+	if data[0] == 0 || data[0] == 1 {
+		return g.UnmarshalWKB(data)
+	}
+	return g.UnmarshalWKT(string(data))
+}
+
+// geometryToDB converts the spatial value to the argument of the dialect
+func (engine *Engine) geometryToDB(col *core.Column, g GeometryValue) interface{} {
+	var srid = col.Length
+	switch engine.dialect.DBType() {
+	case core.MYSQL:
+		// the internal format of mysql is the SRID and the WKB
+		var w wkbWriter
+		w.uint32(uint32(srid))
+		w.Write(g.WKB())
+		return w.Bytes()
+	case core.POSTGRES:
+		return hex.EncodeToString(ewkbOf(g, srid))
+	case core.MSSQL:
+		return g.WKT()
+	}
+	return g.WKB()
+}
+
+// geometryFromDB decodes the spatial value from the data of the dialect
+func (engine *Engine) geometryFromDB(g geometryUnmarshaler, data []byte) error {
+	if engine.dialect.DBType() == core.MYSQL && len(data) > 4 {
+		data = data[4:]
+	}
+	return unmarshalGeometry(g, data)
+}
+
+// setGeometryField decodes the data into the field if it's a spatial value,
+// returns false if it's not.
+func (engine *Engine) setGeometryField(fieldValue reflect.Value, data []byte) (bool, error) {
+	if fieldValue.Kind() == reflect.Ptr && fieldValue.Type().Implements(tpGeometryUnmarshaler) {
+		if fieldValue.IsNil() {
+			fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+		}
+		return true, engine.geometryFromDB(fieldValue.Interface().(geometryUnmarshaler), data)
+	}
+	if fieldValue.CanAddr() {
+		if g, ok := fieldValue.Addr().Interface().(geometryUnmarshaler); ok {
+			return true, engine.geometryFromDB(g, data)
+		}
+	}
+	return false, nil
+}
+
+// spatialColumnStr returns the select expression of the spatial column,
+// mssql returns its own format so the WKB is selected.
+func (engine *Engine) spatialColumnStr(col *core.Column, colName string) string {
+	if engine.dialect.DBType() == core.MSSQL && isSpatialType(col.SQLType) {
+		return fmt.Sprintf("%s.STAsBinary() AS %s", colName, engine.Quote(col.Name))
+	}
+	return colName
+}
+
+// createIndexSQL returns the sql to create the index, the spatial indexes
+// are created by the dialect
+func (engine *Engine) createIndexSQL(tableName string, index *core.Index, online bool) string {
+	index = limitIndex(tableName, index, engine.MaxIdentifierLength())
+	if index.Type != SpatialIndexType {
+		return engine.onlineIndexSQL(engine.dialect.CreateIndexSql(tableName, index), index, online)
+	}
+
+	idxName := engine.Quote(index.XName(tableName))
+	cols := engine.Quote(strings.Join(index.Cols, engine.Quote(",")))
+	var sqlStr string
+	switch engine.dialect.DBType() {
+	case core.MYSQL, core.MSSQL:
+		sqlStr = fmt.Sprintf("CREATE SPATIAL INDEX %s ON %s (%s)", idxName, engine.Quote(tableName), cols)
+	case core.POSTGRES:
+		sqlStr = fmt.Sprintf("CREATE INDEX %s ON %s USING GIST (%s)", idxName, engine.Quote(tableName), cols)
+	default:
+		sqlStr = fmt.Sprintf("CREATE INDEX %s ON %s (%s)", idxName, engine.Quote(tableName), cols)
+	}
+	return engine.onlineIndexSQL(sqlStr, index, online)
+}
+
+// spatialCond is a condition of spatial predicates which may be not
+// supported by the dialect
+type spatialCond struct {
+	sql  string
+	args []interface{}
+	err  error
+}
+
+var _ builder.Cond = spatialCond{}
+
+// WriteTo implements builder.Cond
+func (cond spatialCond) WriteTo(w builder.Writer) error {
+	if cond.err != nil {
+		return cond.err
+	}
+	if _, err := fmt.Fprint(w, cond.sql); err != nil {
+		return err
+	}
+	w.Append(cond.args...)
+	return nil
+}
+
+// And implements builder.Cond
+func (cond spatialCond) And(conds ...builder.Cond) builder.Cond {
+	return builder.And(cond, builder.And(conds...))
+}
+
+// Or implements builder.Cond
+func (cond spatialCond) Or(conds ...builder.Cond) builder.Cond {
+	return builder.Or(cond, builder.Or(conds...))
+}
+
+// IsValid implements builder.Cond
+func (cond spatialCond) IsValid() bool {
+	return cond.err != nil || len(cond.sql) > 0
+}
+
+// geomFromText returns the expression to create a spatial value from WKT
+func (engine *Engine) geomFromText(srid int) (string, error) {
+	switch engine.dialect.DBType() {
+	case core.MYSQL, core.POSTGRES:
+		if srid > 0 {
+			return fmt.Sprintf("ST_GeomFromText(?, %d)", srid), nil
+		}
+		return "ST_GeomFromText(?)", nil
+	case core.MSSQL:
+		if srid <= 0 {
+			srid = 4326
+		}
+		return fmt.Sprintf("geography::STGeomFromText(?, %d)", srid), nil
+	}
+	return "", ErrNotImplemented
+}
+
+// GeoDistanceWithin returns the condition that the distance in meters
+// between the geography column and the point is not greater than distance
+func (engine *Engine) GeoDistanceWithin(colName string, p Point, distance float64, srid ...int) builder.Cond {
+	var id = 4326
+	if len(srid) > 0 {
+		id = srid[0]
+	}
+	geom, err := engine.geomFromText(id)
+	if err != nil {
+		return spatialCond{err: err}
+	}
+
+	col := engine.Quote(colName)
+	var sqlStr string
+	switch engine.dialect.DBType() {
+	case core.MYSQL:
+		sqlStr = fmt.Sprintf("ST_Distance_Sphere(%s, %s) <= ?", col, geom)
+	case core.POSTGRES:
+		sqlStr = fmt.Sprintf("ST_DWithin(%s::geography, %s::geography, ?)", col, geom)
+	case core.MSSQL:
+		sqlStr = fmt.Sprintf("%s.STDistance(%s) <= ?", col, geom)
+	}
+	return spatialCond{sql: sqlStr, args: []interface{}{p.WKT(), distance}}
+}
+
+// GeoWithin returns the condition that the column is within the geometry
+func (engine *Engine) GeoWithin(colName string, g GeometryValue, srid ...int) builder.Cond {
+	return engine.geoPredicate("ST_Within", "STWithin", colName, g, srid...)
+}
+
+// GeoIntersects returns the condition that the column intersects the geometry
+func (engine *Engine) GeoIntersects(colName string, g GeometryValue, srid ...int) builder.Cond {
+	return engine.geoPredicate("ST_Intersects", "STIntersects", colName, g, srid...)
+}
+
+func (engine *Engine) geoPredicate(fn, mssqlMethod, colName string, g GeometryValue, srid ...int) builder.Cond {
+	var id int
+	if len(srid) > 0 {
+		id = srid[0]
+	}
+	geom, err := engine.geomFromText(id)
+	if err != nil {
+		return spatialCond{err: err}
+	}
+
+	col := engine.Quote(colName)
+	if engine.dialect.DBType() == core.MSSQL {
+		return spatialCond{sql: fmt.Sprintf("%s.%s(%s) = 1", col, mssqlMethod, geom), args: []interface{}{g.WKT()}}
+	}
+	return spatialCond{sql: fmt.Sprintf("%s(%s, %s)", fn, col, geom), args: []interface{}{g.WKT()}}
+}
+
+// SpatialTagHandler describes spatial tag handler, a spatial index will be
+// created on the column
+func SpatialTagHandler(ctx *tagContext) error {
+	if len(ctx.params) > 0 {
+		ctx.indexNames[ctx.params[0]] = SpatialIndexType
+	} else {
+		ctx.isSpatial = true
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/builder"
+	"xorm.io/core"
+)
+
+func TestWKBCodec(t *testing.T) {
+	p := Point{X: 1.5, Y: -2}
+	assert.EqualValues(t, "0101000000000000000000f83f00000000000000c0", hex.EncodeToString(p.WKB()))
+	assert.EqualValues(t, "POINT(1.5 -2)", p.WKT())
+
+	var p2 Point
+	assert.NoError(t, p2.UnmarshalWKB(p.WKB()))
+	assert.EqualValues(t, p, p2)
+
+	// big endian
+	bs, _ := hex.DecodeString("00000000013ff8000000000000c000000000000000")
+	var p3 Point
+	assert.NoError(t, p3.UnmarshalWKB(bs))
+	assert.EqualValues(t, p, p3)
+
+	// EWKB with SRID 4326
+	var p4 Point
+	assert.NoError(t, p4.UnmarshalWKB(p.ewkb(4326)))
+	assert.EqualValues(t, p, p4)
+
+	poly := Polygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{2, 2}, {3, 2}, {3, 3}, {2, 2}},
+	}
+	assert.EqualValues(t, "POLYGON((0 0,10 0,10 10,0 10,0 0),(2 2,3 2,3 3,2 2))", poly.WKT())
+
+	var poly2 Polygon
+	assert.NoError(t, poly2.UnmarshalWKB(poly.WKB()))
+	assert.EqualValues(t, poly, poly2)
+
+	var poly3 Polygon
+	assert.NoError(t, poly3.UnmarshalWKT(poly.WKT()))
+	assert.EqualValues(t, poly, poly3)
+
+	assert.Error(t, poly2.UnmarshalWKB(p.WKB()))
+	assert.Error(t, p2.UnmarshalWKB(poly.WKB()))
+	assert.EqualValues(t, ErrInvalidWKB, p2.UnmarshalWKB(p.WKB()[:10]))
+	assert.EqualValues(t, ErrInvalidWKB, poly2.UnmarshalWKB(poly.WKB()[:30]))
+}
+
+func TestWKTParse(t *testing.T) {
+	var p Point
+	assert.NoError(t, p.UnmarshalWKT("SRID=4326;POINT (116.4 39.9)"))
+	assert.EqualValues(t, Point{116.4, 39.9}, p)
+
+	assert.EqualValues(t, ErrInvalidWKT, p.UnmarshalWKT("POINT(1)"))
+	assert.EqualValues(t, ErrInvalidWKT, p.UnmarshalWKT("POLYGON((0 0))"))
+
+	var poly Polygon
+	assert.EqualValues(t, ErrInvalidWKT, poly.UnmarshalWKT("POLYGON((0 0,1 1)"))
+
+	// hex EWKB of PostGIS is detected
+	var p2 Point
+	assert.NoError(t, unmarshalGeometry(&p2, []byte(hex.EncodeToString(Point{3, 4}.ewkb(4326)))))
+	assert.EqualValues(t, Point{3, 4}, p2)
+}
+
+func TestSpatialSQLType(t *testing.T) {
+	point := &core.Column{SQLType: core.SQLType{Name: PointType}, Length: 4326}
+	poly := &core.Column{SQLType: core.SQLType{Name: PolyType}}
+	geog := &core.Column{SQLType: core.SQLType{Name: Geography}}
+
+	assert.EqualValues(t, "geometry(Point,4326)", spatialSQLType(core.POSTGRES, point))
+	assert.EqualValues(t, "geometry(Polygon)", spatialSQLType(core.POSTGRES, poly))
+	assert.EqualValues(t, "geography", spatialSQLType(core.POSTGRES, geog))
+	assert.EqualValues(t, "POINT SRID 4326", spatialSQLType(core.MYSQL, point))
+	assert.EqualValues(t, "GEOMETRY", spatialSQLType(core.MYSQL, geog))
+	assert.EqualValues(t, "GEOGRAPHY", spatialSQLType(core.MSSQL, point))
+	assert.EqualValues(t, core.Blob, spatialSQLType(core.SQLITE, poly))
+}
+
+type SpatialPlace struct {
+	Id       int64
+	Name     string
+	Location Point    `xorm:"point(4326) spatial"`
+	Area     *Polygon `xorm:"polygon"`
+}
+
+func TestRegisterSpatialTypes(t *testing.T) {
+	engine := &Engine{mutex: &sync.RWMutex{}, tagHandlers: defaultTagHandlers}
+	engine.RegisterSpatialTypes()
+	_, ok := engine.tagHandlers[PointType]
+	assert.True(t, ok)
+
+	// the global types and tags are not changed
+	_, ok = defaultTagHandlers[PointType]
+	assert.False(t, ok)
+	_, ok = core.SqlTypes[PointType]
+	assert.False(t, ok)
+}
+
+func TestSpatialInsertGet(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	testEngine.RegisterSpatialTypes()
+	assertSync(t, new(SpatialPlace))
+
+	table := testEngine.TableInfo(new(SpatialPlace))
+	assert.EqualValues(t, SpatialIndexType, table.Indexes["location"].Type)
+
+	var area = Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}
+	_, err := testEngine.Insert(&SpatialPlace{
+		Name:     "a",
+		Location: Point{116.4, 39.9},
+		Area:     &area,
+	}, &SpatialPlace{
+		Name:     "b",
+		Location: Point{-0.1, 51.5},
+	})
+	assert.NoError(t, err)
+
+	var place SpatialPlace
+	has, err := testEngine.Where("name = ?", "a").Get(&place)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, Point{116.4, 39.9}, place.Location)
+	if assert.NotNil(t, place.Area) {
+		assert.EqualValues(t, area, *place.Area)
+	}
+
+	var places []SpatialPlace
+	assert.NoError(t, testEngine.Asc("id").Find(&places))
+	assert.EqualValues(t, 2, len(places))
+	assert.EqualValues(t, Point{-0.1, 51.5}, places[1].Location)
+	assert.Nil(t, places[1].Area)
+
+	_, err = testEngine.Where("name = ?", "b").Update(&SpatialPlace{Location: Point{2.3, 48.9}})
+	assert.NoError(t, err)
+	var updated SpatialPlace
+	has, err = testEngine.Where("name = ?", "b").Get(&updated)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, Point{2.3, 48.9}, updated.Location)
+
+	// the spatial conditions are not supported by sqlite
+	if testEngine.Dialect().DBType() == core.SQLITE {
+		_, _, err = builder.ToSQL(testEngine.(*Engine).GeoWithin("location", area))
+		assert.EqualValues(t, ErrNotImplemented, err)
+	}
+}
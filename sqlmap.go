@@ -0,0 +1,481 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	"xorm.io/core"
+)
+
+var (
+	// ErrSQLMapNotLoaded the engine has no sql map
+	ErrSQLMapNotLoaded = errors.New("sql map is not loaded")
+)
+
+// ErrSQLTemplateNotFound the named sql template does not exist
+type ErrSQLTemplateNotFound struct {
+	Name string
+}
+
+func (e ErrSQLTemplateNotFound) Error() string {
+	return fmt.Sprintf("sql template %s is not found", e.Name)
+}
+
+// the functions of the sql templates which write to the sql directly, the
+// output of the other actions are bound as parameters by arg.
+var sqlTemplateFuncs = map[string]bool{
+	"arg":   true, // {{arg .Value}} binds the value as a parameter
+	"in":    true, // {{in .Values}} binds every element of the slice
+	"ident": true, // {{ident .Column}} quotes the identifier
+	"raw":   true, // {{raw .Fragment}} writes the trusted fragment as it is
+}
+
+// SQLMap is a set of named sql statements loaded from the files in a
+// directory. The names are the paths of the files without extensions, i.e.
+// reports/monthly.sql is named reports.monthly, and the statements in a file
+// could be named with the comments:
+//
+//	-- name: monthly
+//	SELECT * FROM report WHERE month = {{.Month}}
+//
+// which is reports.monthly in reports.sql. The YAML files map the names to
+// the statements, or to the statements of the dialects:
+//
+//	monthly: SELECT * FROM report WHERE month = {{.Month}}
+//	yearly:
+//	  default: SELECT * FROM report WHERE year = {{.Year}}
+//	  postgres: SELECT * FROM report WHERE date_part('year', day) = {{.Year}}
+//
+// The files for a dialect have the database type before the extension, i.e.
+// reports.postgres.sql, the statements in them override the default ones.
+//
+// The statements are text/template, the output of every action is bound as
+// a parameter unless it's written by ident or raw, so the values are never
+// concatenated into the sql.
+type SQLMap struct {
+	dir       string
+	hotReload bool
+
+	mutex     sync.RWMutex
+	templates map[string]map[core.DbType]*template.Template
+	modTimes  map[string]time.Time
+}
+
+// NewSQLMap loads the sql files in the directory, all the templates are
+// parsed so the errors are returned on startup.
+func NewSQLMap(dir string) (*SQLMap, error) {
+	m := &SQLMap{dir: dir}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetHotReload sets if the files should be reloaded when they are changed,
+// it stats all the files before rendering so it's only for development.
+func (m *SQLMap) SetHotReload(hotReload bool) {
+	m.mutex.Lock()
+	m.hotReload = hotReload
+	m.mutex.Unlock()
+}
+
+// Names returns the sorted names of the statements
+func (m *SQLMap) Names() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var names = make([]string, 0, len(m.templates))
+	for name := range m.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reload loads the files again, the loaded statements are kept if any
+// file is invalid.
+func (m *SQLMap) Reload() error {
+	var templates = make(map[string]map[core.DbType]*template.Template)
+	var modTimes = make(map[string]time.Time)
+
+	err := filepath.Walk(m.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".sql" && ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		modTimes[path] = info.ModTime()
+
+		rel, err := filepath.Rel(m.dir, path)
+		if err != nil {
+			return err
+		}
+		prefix := strings.Replace(filepath.ToSlash(rel[:len(rel)-len(ext)]), "/", ".", -1)
+		var dbType core.DbType
+		if i := strings.LastIndex(prefix, "."); i > -1 && isSQLMapDbType(prefix[i+1:]) {
+			prefix, dbType = prefix[:i], core.DbType(prefix[i+1:])
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var stmts map[string]map[core.DbType]string
+		if ext == ".sql" {
+			stmts, err = parseSQLFile(prefix, dbType, string(content))
+		} else {
+			stmts, err = parseYAMLFile(prefix, dbType, content)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		for name, dialects := range stmts {
+			if templates[name] == nil {
+				templates[name] = make(map[core.DbType]*template.Template)
+			}
+			for tp, text := range dialects {
+				if _, ok := templates[name][tp]; ok {
+					return fmt.Errorf("%s: sql template %s is defined more than once", path, name)
+				}
+				tmpl, err := parseSQLTemplate(name, text)
+				if err != nil {
+					return fmt.Errorf("%s: %v", path, err)
+				}
+				templates[name][tp] = tmpl
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.templates = templates
+	m.modTimes = modTimes
+	m.mutex.Unlock()
+	return nil
+}
+
+// isSQLMapDbType returns true if the suffix of the file name is a database type
+func isSQLMapDbType(s string) bool {
+	switch core.DbType(s) {
+	case core.MYSQL, core.POSTGRES, core.SQLITE, core.MSSQL, core.ORACLE:
+		return true
+	}
+	return false
+}
+
+// parseSQLFile splits the file into the statements by the name comments, the
+// file without them is a statement named by the prefix. Only comments could
+// be put before the first name comment.
+func parseSQLFile(prefix string, dbType core.DbType, content string) (map[string]map[core.DbType]string, error) {
+	var stmts = make(map[string]map[core.DbType]string)
+	var name string
+	var buf strings.Builder
+	var unnamed int // the line of the first statement before the name comments
+
+	var add = func() {
+		text := strings.TrimSpace(buf.String())
+		buf.Reset()
+		if text == "" {
+			return
+		}
+		stmtName := prefix
+		if name != "" {
+			stmtName = prefix + "." + name
+		}
+		stmts[stmtName] = map[core.DbType]string{dbType: text}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			comment := strings.TrimSpace(strings.TrimPrefix(trimmed, "--"))
+			if strings.HasPrefix(comment, "name:") {
+				if name != "" {
+					add()
+				} else if unnamed > 0 {
+					return nil, fmt.Errorf("line %d: the statement has no name comment", unnamed)
+				} else {
+					buf.Reset()
+				}
+				name = strings.TrimSpace(strings.TrimPrefix(comment, "name:"))
+				continue
+			}
+		} else if trimmed != "" && name == "" && unnamed == 0 {
+			unnamed = lineNo
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	add()
+	return stmts, nil
+}
+
+// parseYAMLFile parses the names and statements of a YAML file
+func parseYAMLFile(prefix string, dbType core.DbType, content []byte) (map[string]map[core.DbType]string, error) {
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+
+	var stmts = make(map[string]map[core.DbType]string)
+	for name, value := range values {
+		stmtName := prefix + "." + name
+		switch v := value.(type) {
+		case string:
+			stmts[stmtName] = map[core.DbType]string{dbType: v}
+		case map[interface{}]interface{}:
+			stmts[stmtName] = make(map[core.DbType]string)
+			for k, text := range v {
+				key := fmt.Sprint(k)
+				s, ok := text.(string)
+				if !ok {
+					return nil, fmt.Errorf("sql template %s of %s should be a string", stmtName, key)
+				}
+				if key == "default" {
+					stmts[stmtName][dbType] = s
+				} else if isSQLMapDbType(key) {
+					stmts[stmtName][core.DbType(key)] = s
+				} else {
+					return nil, fmt.Errorf("sql template %s has unknown dialect %s", stmtName, key)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("sql template %s should be a string or a map of dialects", stmtName)
+		}
+	}
+	return stmts, nil
+}
+
+// parseSQLTemplate parses the template and binds the output of the actions
+func parseSQLTemplate(name, text string) (*template.Template, error) {
+	var funcs = make(template.FuncMap, len(sqlTemplateFuncs))
+	for fn := range sqlTemplateFuncs {
+		funcs[fn] = func(...interface{}) string { return "" }
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			bindActions(t.Tree, t.Tree.Root)
+		}
+	}
+	return tmpl, nil
+}
+
+// bindActions appends arg to the pipelines whose output is written to the sql
+func bindActions(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			bindActions(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 || len(n.Pipe.Cmds) == 0 {
+			return
+		}
+		last := n.Pipe.Cmds[len(n.Pipe.Cmds)-1]
+		if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && sqlTemplateFuncs[ident.Ident] {
+			return
+		}
+		arg := parse.NewIdentifier("arg").SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{arg},
+		})
+	case *parse.IfNode:
+		bindActions(tree, n.List)
+		bindActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		bindActions(tree, n.List)
+		bindActions(tree, n.ElseList)
+	case *parse.WithNode:
+		bindActions(tree, n.List)
+		bindActions(tree, n.ElseList)
+	}
+}
+
+// reloadIfChanged reloads the files if any of them is added, removed or
+// changed since the last loading.
+func (m *SQLMap) reloadIfChanged() error {
+	m.mutex.RLock()
+	hotReload, modTimes := m.hotReload, m.modTimes
+	m.mutex.RUnlock()
+	if !hotReload {
+		return nil
+	}
+
+	var changed bool
+	var count int
+	err := filepath.Walk(m.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || changed {
+			return err
+		}
+		if modTime, ok := modTimes[path]; ok {
+			count++
+			changed = !modTime.Equal(info.ModTime())
+		} else {
+			ext := strings.ToLower(filepath.Ext(path))
+			changed = ext == ".sql" || ext == ".yaml" || ext == ".yml"
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if changed || count != len(modTimes) {
+		return m.Reload()
+	}
+	return nil
+}
+
+// lookup returns the template of the dialect, or the default one
+func (m *SQLMap) lookup(name string, dbType core.DbType) (*template.Template, error) {
+	if err := m.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	dialects, ok := m.templates[name]
+	if !ok {
+		return nil, ErrSQLTemplateNotFound{name}
+	}
+	if tmpl, ok := dialects[dbType]; ok {
+		return tmpl, nil
+	}
+	if tmpl, ok := dialects[""]; ok {
+		return tmpl, nil
+	}
+	return nil, ErrSQLTemplateNotFound{name + " of " + string(dbType)}
+}
+
+// render executes the template, the values are collected as the parameters
+func (m *SQLMap) render(name string, dbType core.DbType, quote func(string) string, params interface{}) (string, []interface{}, error) {
+	tmpl, err := m.lookup(name, dbType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var args []interface{}
+	tmpl, err = tmpl.Clone()
+	if err != nil {
+		return "", nil, err
+	}
+	tmpl.Funcs(template.FuncMap{
+		"arg": func(v interface{}) string {
+			args = append(args, v)
+			return "?"
+		},
+		"in": func(v interface{}) (string, error) {
+			value := reflect.ValueOf(v)
+			if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+				return "", fmt.Errorf("in needs a slice but got %T", v)
+			}
+			if value.Len() == 0 {
+				return "NULL", nil
+			}
+			for i := 0; i < value.Len(); i++ {
+				args = append(args, value.Index(i).Interface())
+			}
+			return strings.TrimSuffix(strings.Repeat("?,", value.Len()), ","), nil
+		},
+		"ident": func(v interface{}) string {
+			return quote(fmt.Sprint(v))
+		},
+		"raw": func(v interface{}) string {
+			return fmt.Sprint(v)
+		},
+	})
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSpace(buf.String()), args, nil
+}
+
+// LoadSQLMap loads the sql files in the directory as the sql map of the engine
+func (engine *Engine) LoadSQLMap(dir string) error {
+	m, err := NewSQLMap(dir)
+	if err != nil {
+		return err
+	}
+	engine.SetSQLMap(m)
+	return nil
+}
+
+// SetSQLMap sets the sql map of the engine
+func (engine *Engine) SetSQLMap(m *SQLMap) {
+	engine.sqlMap = m
+}
+
+// SQLMap returns the sql map of the engine
+func (engine *Engine) SQLMap() *SQLMap {
+	return engine.sqlMap
+}
+
+// RenderSQL renders the named sql template for the dialect of the engine and
+// returns the sql and its parameters
+func (engine *Engine) RenderSQL(name string, params interface{}) (string, []interface{}, error) {
+	if engine.sqlMap == nil {
+		return "", nil, ErrSQLMapNotLoaded
+	}
+	return engine.sqlMap.render(name, engine.dialect.DBType(), engine.Quote, params)
+}
+
+// SQLTemplate renders the named sql template as the raw sql of the session
+func (session *Session) SQLTemplate(name string, params interface{}) *Session {
+	sqlStr, args, err := session.engine.RenderSQL(name, params)
+	if err != nil {
+		session.statement.lastError = err
+		return session
+	}
+	return session.SQL(sqlStr, args...)
+}
+
+// SQLTemplate renders the named sql template as the raw sql
+func (engine *Engine) SQLTemplate(name string, params interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.SQLTemplate(name, params)
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"xorm.io/core"
+)
+
+func writeSQLFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "xorm_sqlmap")
+	assert.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestSQLMap(t *testing.T) {
+	dir := writeSQLFiles(t, map[string]string{
+		"reports.sql": `-- the reports
+-- name: monthly
+SELECT * FROM report WHERE month = {{.Month}} AND name = {{.Name | printf "%s%%"}}
+
+-- name: by_ids
+SELECT * FROM report WHERE id IN ({{in .Ids}}){{if .Order}} ORDER BY {{ident .Order}} {{raw .Dir}}{{end}}
+`,
+		"reports.postgres.sql": `-- name: monthly
+SELECT * FROM report WHERE date_part('month', day) = {{.Month}}
+`,
+		"users/active.sql": `SELECT * FROM user WHERE active = {{.}}`,
+		"users.yaml": `
+by_name: SELECT * FROM user WHERE name = {{.}}
+count:
+  default: SELECT count(*) FROM user
+  mysql: SELECT count(*) FROM user USE INDEX (PRIMARY)
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	m, err := NewSQLMap(dir)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"reports.by_ids", "reports.monthly", "users.active", "users.by_name", "users.count"}, m.Names())
+
+	var quote = func(s string) string { return "`" + s + "`" }
+	sqlStr, args, err := m.render("reports.monthly", core.SQLITE, quote, map[string]interface{}{
+		"Month": 5,
+		"Name":  "x' OR 1=1 --",
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT * FROM report WHERE month = ? AND name = ?", sqlStr)
+	assert.EqualValues(t, []interface{}{5, "x' OR 1=1 --%"}, args)
+
+	sqlStr, args, err = m.render("reports.monthly", core.POSTGRES, quote, map[string]interface{}{"Month": 5})
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT * FROM report WHERE date_part('month', day) = ?", sqlStr)
+	assert.EqualValues(t, []interface{}{5}, args)
+
+	sqlStr, args, err = m.render("reports.by_ids", core.SQLITE, quote, map[string]interface{}{
+		"Ids":   []int64{1, 2, 3},
+		"Order": "created",
+		"Dir":   "DESC",
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT * FROM report WHERE id IN (?,?,?) ORDER BY `created` DESC", sqlStr)
+	assert.EqualValues(t, []interface{}{int64(1), int64(2), int64(3)}, args)
+
+	sqlStr, args, err = m.render("reports.by_ids", core.SQLITE, quote, map[string]interface{}{
+		"Ids":   []int64{},
+		"Order": "",
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT * FROM report WHERE id IN (NULL)", sqlStr)
+	assert.EqualValues(t, 0, len(args))
+
+	sqlStr, args, err = m.render("users.active", core.SQLITE, quote, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT * FROM user WHERE active = ?", sqlStr)
+	assert.EqualValues(t, []interface{}{true}, args)
+
+	sqlStr, _, err = m.render("users.count", core.MYSQL, quote, nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT count(*) FROM user USE INDEX (PRIMARY)", sqlStr)
+	sqlStr, _, err = m.render("users.count", core.SQLITE, quote, nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT count(*) FROM user", sqlStr)
+
+	_, _, err = m.render("users.none", core.SQLITE, quote, nil)
+	assert.EqualValues(t, ErrSQLTemplateNotFound{"users.none"}, err)
+
+	// the missing keys are errors
+	_, _, err = m.render("reports.monthly", core.SQLITE, quote, map[string]interface{}{})
+	assert.Error(t, err)
+}
+
+func TestSQLMapInvalid(t *testing.T) {
+	dir := writeSQLFiles(t, map[string]string{
+		"reports.sql": "-- name: broken\nSELECT * FROM report WHERE id = {{.Id}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := NewSQLMap(dir)
+	assert.Error(t, err)
+
+	// only comments could be put before the first name comment
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "reports.sql"), []byte("SELECT 0;\n-- name: one\nSELECT 1"), 0644))
+	_, err = NewSQLMap(dir)
+	assert.Error(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "reports.sql"), []byte("-- the reports\n\n-- name: one\nSELECT 1"), 0644))
+	m, err := NewSQLMap(dir)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"reports.one"}, m.Names())
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "reports.sql"), []byte("SELECT 1"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "reports.yaml"), []byte("monthly: SELECT 2"), 0644))
+	m, err = NewSQLMap(dir)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"reports", "reports.monthly"}, m.Names())
+
+	// the statement is defined twice
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "reports.sql"), []byte("-- name: monthly\nSELECT 1"), 0644))
+	_, err = NewSQLMap(dir)
+	assert.Error(t, err)
+	assert.NoError(t, os.Remove(filepath.Join(dir, "reports.yaml")))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "more.yaml"), []byte("a:\n  sybase: SELECT 1"), 0644))
+	_, err = NewSQLMap(dir)
+	assert.Error(t, err)
+}
+
+func TestSQLMapHotReload(t *testing.T) {
+	dir := writeSQLFiles(t, map[string]string{
+		"users.sql": "-- name: one\nSELECT 1",
+	})
+	defer os.RemoveAll(dir)
+
+	m, err := NewSQLMap(dir)
+	assert.NoError(t, err)
+
+	var quote = func(s string) string { return s }
+	path := filepath.Join(dir, "users.sql")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("-- name: one\nSELECT 2"), 0644))
+	modTime := time.Now().Add(time.Second)
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+
+	sqlStr, _, err := m.render("users.one", core.SQLITE, quote, nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT 1", sqlStr)
+
+	m.SetHotReload(true)
+	sqlStr, _, err = m.render("users.one", core.SQLITE, quote, nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT 2", sqlStr)
+
+	// the loaded templates are kept if the changed file is invalid
+	assert.NoError(t, ioutil.WriteFile(path, []byte("-- name: one\nSELECT {{"), 0644))
+	modTime = modTime.Add(time.Second)
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	_, _, err = m.render("users.one", core.SQLITE, quote, nil)
+	assert.Error(t, err)
+	m.SetHotReload(false)
+	sqlStr, _, err = m.render("users.one", core.SQLITE, quote, nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT 2", sqlStr)
+}
+
+type SQLTemplateReport struct {
+	Id    int64
+	Month int
+	Total int
+}
+
+func TestSQLTemplate(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SQLTemplateReport))
+
+	_, err := testEngine.Insert([]SQLTemplateReport{
+		{Month: 1, Total: 10},
+		{Month: 2, Total: 20},
+		{Month: 2, Total: 30},
+	})
+	assert.NoError(t, err)
+
+	dir := writeSQLFiles(t, map[string]string{
+		"reports.sql": `-- name: monthly
+SELECT * FROM {{ident .Table}} WHERE month = {{.Month}} ORDER BY total
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	var rows []SQLTemplateReport
+	err = testEngine.SQLTemplate("reports.monthly", nil).Find(&rows)
+	assert.EqualValues(t, ErrSQLMapNotLoaded, err)
+
+	assert.NoError(t, testEngine.LoadSQLMap(dir))
+	defer testEngine.SetSQLMap(nil)
+
+	var params = map[string]interface{}{
+		"Table": testEngine.TableName(new(SQLTemplateReport), true),
+		"Month": 2,
+	}
+	assert.NoError(t, testEngine.SQLTemplate("reports.monthly", params).Find(&rows))
+	assert.EqualValues(t, 2, len(rows))
+	assert.EqualValues(t, 20, rows[0].Total)
+	assert.EqualValues(t, 30, rows[1].Total)
+
+	results, err := testEngine.SQLTemplate("reports.monthly", params).QueryString()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(results))
+
+	_, err = testEngine.SQLTemplate("reports.none", params).QueryString()
+	assert.EqualValues(t, ErrSQLTemplateNotFound{"reports.none"}, err)
+}
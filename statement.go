@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"context"
 	"database/sql/driver"
 	"fmt"
 	"reflect"
@@ -48,6 +49,7 @@ type Statement struct {
 	allUseBool      bool
 	checkVersion    bool
 	unscoped        bool
+	onlineDDL       bool
 	columnMap       columnMap
 	omitColumnMap   columnMap
 	mustColumnMap   map[string]bool
@@ -59,6 +61,8 @@ type Statement struct {
 	bufferSize      int
 	context         ContextCache
 	lastError       error
+	policyMerged    bool
+	ctx             context.Context // the context of the session, kept after Init
 }
 
 // Init reset all the statement's fields
@@ -94,6 +98,7 @@ func (statement *Statement) Init() {
 	statement.nullableMap = make(map[string]bool)
 	statement.checkVersion = true
 	statement.unscoped = false
+	statement.onlineDDL = false
 	statement.incrColumns = exprParams{}
 	statement.decrColumns = exprParams{}
 	statement.exprColumns = exprParams{}
@@ -101,6 +106,7 @@ func (statement *Statement) Init() {
 	statement.bufferSize = 0
 	statement.context = nil
 	statement.lastError = nil
+	statement.policyMerged = false
 }
 
 // NoAutoCondition if you do not want convert bean's field as query condition, then use this function
@@ -218,6 +224,12 @@ func (statement *Statement) setRefValue(v reflect.Value) error {
 }
 
 func (statement *Statement) setRefBean(bean interface{}) error {
+	if table := statement.Engine.registeredTableOf(bean); table != nil {
+		statement.RefTable = table
+		statement.tableName = statement.Engine.TableName(bean, true)
+		return nil
+	}
+
 	var err error
 	statement.RefTable, err = statement.Engine.autoMapType(rValue(bean))
 	if err != nil {
@@ -285,6 +297,15 @@ func (statement *Statement) buildUpdates(bean interface{},
 		}
 
 		fieldValue := *fieldValuePtr
+		if engine.isStreamColumn(col) {
+			// the content of io.Reader will be written after the record updated
+			if !fieldValue.IsNil() {
+				args = append(args, []byte{})
+				colNames = append(colNames, fmt.Sprintf("%v = ?", engine.Quote(col.Name)))
+			}
+			continue
+		}
+
 		fieldType := reflect.TypeOf(fieldValue.Interface())
 		if fieldType == nil {
 			continue
@@ -352,6 +373,15 @@ func (statement *Statement) buildUpdates(bean interface{},
 			}
 		}
 
+		if g, ok := fieldValue.Interface().(GeometryValue); ok && isSpatialType(col.SQLType) {
+			if !requiredField && (fieldType.Kind() == reflect.Slice && fieldValue.Len() == 0 ||
+				fieldType.Kind() == reflect.Struct && isStructZero(fieldValue)) {
+				continue
+			}
+			val = engine.geometryToDB(col, g)
+			goto APPEND
+		}
+
 		switch fieldType.Kind() {
 		case reflect.Bool:
 			if allUseBool || requiredField {
@@ -807,6 +837,10 @@ func (statement *Statement) genColumnStr() string {
 			continue
 		}
 
+		if len(statement.columnMap) == 0 && statement.Engine.isLazyColumn(col) {
+			continue
+		}
+
 		if col.MapType == core.ONLYTODB {
 			continue
 		}
@@ -815,24 +849,32 @@ func (statement *Statement) genColumnStr() string {
 			buf.WriteString(", ")
 		}
 
+		if expr := statement.Engine.columnExpr(col); expr != "" {
+			fmt.Fprintf(&buf, "(%s) AS ", expr)
+			statement.Engine.QuoteTo(&buf, col.Name)
+			continue
+		}
+
+		var colName strings.Builder
 		if statement.JoinStr != "" {
 			if statement.TableAlias != "" {
-				buf.WriteString(statement.TableAlias)
+				colName.WriteString(statement.TableAlias)
 			} else {
-				buf.WriteString(statement.TableName())
+				colName.WriteString(statement.TableName())
 			}
 
-			buf.WriteString(".")
+			colName.WriteString(".")
 		}
 
-		statement.Engine.QuoteTo(&buf, col.Name)
+		statement.Engine.QuoteTo(&colName, col.Name)
+		buf.WriteString(statement.Engine.spatialColumnStr(col, colName.String()))
 	}
 
 	return buf.String()
 }
 
 func (statement *Statement) genCreateTableSQL() string {
-	return statement.Engine.dialect.CreateTableSql(statement.RefTable, statement.TableName(),
+	return statement.Engine.dialect.CreateTableSql(statement.Engine.ddlTable(statement.RefTable), statement.TableName(),
 		statement.StoreEngine, statement.Charset)
 }
 
@@ -840,11 +882,11 @@ func (statement *Statement) genIndexSQL() []string {
 	var sqls []string
 	tbName := statement.TableName()
 	for _, index := range statement.RefTable.Indexes {
-		if index.Type == core.IndexType {
-			sql := statement.Engine.dialect.CreateIndexSql(tbName, index)
+		if index.Type == core.IndexType || index.Type == SpatialIndexType {
+			sql := statement.Engine.createIndexSQL(tbName, index, statement.onlineDDL)
 			/*idxTBName := strings.Replace(tbName, ".", "_", -1)
 			idxTBName = strings.Replace(idxTBName, `"`, "", -1)
-			sql := fmt.Sprintf("CREATE INDEX %v ON %v (%v);", quote(indexName(idxTBName, idxName)),
+			sql := fmt.Sprintf("CREATE INDEX %v ON %v (%v);", quote(indexName(idxTBName, idxName, 0)),
 				quote(tbName), quote(strings.Join(index.Cols, quote(","))))*/
 			sqls = append(sqls, sql)
 		}
@@ -852,8 +894,8 @@ func (statement *Statement) genIndexSQL() []string {
 	return sqls
 }
 
-func uniqueName(tableName, uqeName string) string {
-	return fmt.Sprintf("UQE_%v_%v", tableName, uqeName)
+func uniqueName(tableName, uqeName string, max int) string {
+	return truncateIdentifier(fmt.Sprintf("UQE_%v_%v", tableName, uqeName), max)
 }
 
 func (statement *Statement) genUniqueSQL() []string {
@@ -861,7 +903,7 @@ func (statement *Statement) genUniqueSQL() []string {
 	tbName := statement.TableName()
 	for _, index := range statement.RefTable.Indexes {
 		if index.Type == core.UniqueType {
-			sql := statement.Engine.dialect.CreateIndexSql(tbName, index)
+			sql := statement.Engine.createIndexSQL(tbName, index, statement.onlineDDL)
 			sqls = append(sqls, sql)
 		}
 	}
@@ -876,9 +918,9 @@ func (statement *Statement) genDelIndexSQL() []string {
 	for idxName, index := range statement.RefTable.Indexes {
 		var rIdxName string
 		if index.Type == core.UniqueType {
-			rIdxName = uniqueName(idxPrefixName, idxName)
-		} else if index.Type == core.IndexType {
-			rIdxName = indexName(idxPrefixName, idxName)
+			rIdxName = uniqueName(idxPrefixName, idxName, statement.Engine.MaxIdentifierLength())
+		} else if index.Type == core.IndexType || index.Type == SpatialIndexType {
+			rIdxName = indexName(idxPrefixName, idxName, statement.Engine.MaxIdentifierLength())
 		}
 		sql := fmt.Sprintf("DROP INDEX %v", statement.Engine.Quote(statement.Engine.TableName(rIdxName, true)))
 		if statement.Engine.dialect.IndexOnTable() {
@@ -893,9 +935,13 @@ func (statement *Statement) genAddColumnStr(col *core.Column) (string, []interfa
 	quote := statement.Engine.Quote
 	sql := fmt.Sprintf("ALTER TABLE %v ADD %v", quote(statement.TableName()),
 		col.String(statement.Engine.dialect))
-	if statement.Engine.dialect.DBType() == core.MYSQL && len(col.Comment) > 0 {
+	caps := statement.Engine.Capabilities()
+	if caps.ColumnComment && len(col.Comment) > 0 {
 		sql += " COMMENT '" + col.Comment + "'"
 	}
+	if statement.onlineDDL && caps.InplaceAlter {
+		sql += ", ALGORITHM=INPLACE, LOCK=NONE"
+	}
 	sql += ";"
 	return sql, []interface{}{}
 }
@@ -914,6 +960,7 @@ func (statement *Statement) mergeConds(bean interface{}) error {
 		}
 		statement.cond = statement.cond.And(autoCond)
 	}
+	statement.mergePolicyCond()
 
 	if err := statement.processIDParam(); err != nil {
 		return err
@@ -967,6 +1014,7 @@ func (statement *Statement) genGetSQL(bean interface{}) (string, []interface{},
 			return "", nil, err
 		}
 	} else {
+		statement.mergePolicyCond()
 		if err := statement.processIDParam(); err != nil {
 			return "", nil, err
 		}
@@ -992,6 +1040,7 @@ func (statement *Statement) genCountSQL(beans ...interface{}) (string, []interfa
 		statement.setRefBean(beans[0])
 		condSQL, condArgs, err = statement.genConds(beans[0])
 	} else {
+		statement.mergePolicyCond()
 		condSQL, condArgs, err = builder.ToSQL(statement.cond)
 	}
 	if err != nil {
@@ -1054,24 +1103,26 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string, needLimit, n
 		whereStr = " WHERE " + condSQL
 	}
 
-	if dialect.DBType() == core.MSSQL && strings.Contains(statement.TableName(), "..") {
+	caps := statement.Engine.Capabilities()
+	if caps.CrossDatabase && strings.Contains(statement.TableName(), "..") {
 		fromStr += statement.TableName()
 	} else {
 		fromStr += quote(statement.TableName())
 	}
 
 	if statement.TableAlias != "" {
-		if dialect.DBType() == core.ORACLE {
-			fromStr += " " + quote(statement.TableAlias)
-		} else {
+		if caps.TableAliasAs {
 			fromStr += " AS " + quote(statement.TableAlias)
+		} else {
+			fromStr += " " + quote(statement.TableAlias)
 		}
 	}
 	if statement.JoinStr != "" {
 		fromStr = fmt.Sprintf("%v %v", fromStr, statement.JoinStr)
 	}
 
-	if dialect.DBType() == core.MSSQL {
+	limitStyle := caps.Limit
+	if limitStyle == LimitTop {
 		if statement.LimitN > 0 {
 			top = fmt.Sprintf("TOP %d ", statement.LimitN)
 		}
@@ -1132,13 +1183,13 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string, needLimit, n
 		fmt.Fprint(&buf, " ORDER BY ", statement.OrderStr)
 	}
 	if needLimit {
-		if dialect.DBType() != core.MSSQL && dialect.DBType() != core.ORACLE {
+		if limitStyle == LimitOffset {
 			if statement.Start > 0 {
 				fmt.Fprintf(&buf, " LIMIT %v OFFSET %v", statement.LimitN, statement.Start)
 			} else if statement.LimitN > 0 {
 				fmt.Fprint(&buf, " LIMIT ", statement.LimitN)
 			}
-		} else if dialect.DBType() == core.ORACLE {
+		} else if limitStyle == LimitRowNum {
 			if statement.Start != 0 || statement.LimitN != 0 {
 				oldString := buf.String()
 				buf.Reset()
@@ -1204,7 +1255,7 @@ func (statement *Statement) convertIDSQL(sqlStr string) string {
 		}
 
 		var top string
-		if statement.LimitN > 0 && statement.Engine.dialect.DBType() == core.MSSQL {
+		if statement.LimitN > 0 && statement.Engine.Capabilities().Limit == LimitTop {
 			top = fmt.Sprintf("TOP %d ", statement.LimitN)
 		}
 
@@ -1231,11 +1282,11 @@ func (statement *Statement) convertUpdateSQL(sqlStr string) (string, string) {
 
 	var whereStr = sqls[1]
 
-	// TODO: for postgres only, if any other database?
 	var paraStr string
-	if statement.Engine.dialect.DBType() == core.POSTGRES {
+	switch statement.Engine.Capabilities().Placeholder {
+	case PlaceholderDollar:
 		paraStr = "$"
-	} else if statement.Engine.dialect.DBType() == core.MSSQL {
+	case PlaceholderColon:
 		paraStr = ":"
 	}
 
@@ -23,6 +23,8 @@ type tagContext struct {
 	fieldValue      reflect.Value
 	isIndex         bool
 	isUnique        bool
+	isSpatial       bool
+	children        *graphRelation
 	indexNames      map[string]int
 	engine          *Engine
 	hasCacheTag     bool
@@ -36,25 +38,35 @@ type tagHandler func(ctx *tagContext) error
 var (
 	// defaultTagHandlers enumerates all the default tag handler
 	defaultTagHandlers = map[string]tagHandler{
-		"<-":       OnlyFromDBTagHandler,
-		"->":       OnlyToDBTagHandler,
-		"PK":       PKTagHandler,
-		"NULL":     NULLTagHandler,
-		"NOT":      IgnoreTagHandler,
-		"AUTOINCR": AutoIncrTagHandler,
-		"DEFAULT":  DefaultTagHandler,
-		"CREATED":  CreatedTagHandler,
-		"UPDATED":  UpdatedTagHandler,
-		"DELETED":  DeletedTagHandler,
-		"VERSION":  VersionTagHandler,
-		"UTC":      UTCTagHandler,
-		"LOCAL":    LocalTagHandler,
-		"NOTNULL":  NotNullTagHandler,
-		"INDEX":    IndexTagHandler,
-		"UNIQUE":   UniqueTagHandler,
-		"CACHE":    CacheTagHandler,
-		"NOCACHE":  NoCacheTagHandler,
-		"COMMENT":  CommentTagHandler,
+		"<-":          OnlyFromDBTagHandler,
+		"->":          OnlyToDBTagHandler,
+		"PK":          PKTagHandler,
+		"NULL":        NULLTagHandler,
+		"NOT":         IgnoreTagHandler,
+		"AUTOINCR":    AutoIncrTagHandler,
+		"DEFAULT":     DefaultTagHandler,
+		"CREATED":     CreatedTagHandler,
+		"UPDATED":     UpdatedTagHandler,
+		"DELETED":     DeletedTagHandler,
+		"VERSION":     VersionTagHandler,
+		"UTC":         UTCTagHandler,
+		"LOCAL":       LocalTagHandler,
+		"NOTNULL":     NotNullTagHandler,
+		"INDEX":       IndexTagHandler,
+		"UNIQUE":      UniqueTagHandler,
+		"CACHE":       CacheTagHandler,
+		"NOCACHE":     NoCacheTagHandler,
+		"COMMENT":     CommentTagHandler,
+		"NOEXTENDS":   NoExtendsTagHandler,
+		"EXPR":        ExprTagHandler,
+		"LAZY":        LazyTagHandler,
+		"PII":         PIITagHandler,
+		"SECRET":      SecretTagHandler,
+		"SENSITIVE":   SecretTagHandler,
+		"TREE":        TreeTagHandler,
+		"SPATIAL":     SpatialTagHandler,
+		"RENAMEDFROM": RenamedFromTagHandler,
+		"CHILDREN":    ChildrenTagHandler,
 	}
 )
 
@@ -294,6 +306,65 @@ func ExtendsTagHandler(ctx *tagContext) error {
 	return nil
 }
 
+// ExprTagHandler describes expr tag handler, the column will be selected as
+// the sql expression and never be written to database
+func ExprTagHandler(ctx *tagContext) error {
+	if len(ctx.params) == 0 {
+		return fmt.Errorf("field %s tag expr needs an expression", ctx.col.FieldName)
+	}
+	ctx.col.MapType = core.ONLYFROMDB
+	ctx.engine.setColumnExtra(ctx.col).expr = strings.TrimSpace(strings.Join(ctx.params, ","))
+	return nil
+}
+
+// LazyTagHandler describes lazy tag handler, the column will not be selected
+// by default and could be loaded by Session.Load
+func LazyTagHandler(ctx *tagContext) error {
+	ctx.engine.setColumnExtra(ctx.col).lazy = true
+	return nil
+}
+
+// PIITagHandler describes pii tag handler, the column is anonymized with
+// the rule like pii(email) when dumping by DumpAllAnonymized
+func PIITagHandler(ctx *tagContext) error {
+	if len(ctx.params) == 0 {
+		return fmt.Errorf("pii tag needs a rule")
+	}
+	rule, err := newAnonymizeRule(ctx.params[0], ctx.params[1:]...)
+	if err != nil {
+		return err
+	}
+	ctx.engine.setColumnExtra(ctx.col).pii = rule
+	return nil
+}
+
+// SecretTagHandler describes secret and sensitive tag handler, the arguments
+// bound to the column are masked in the SQL logs, LastSQL and driver errors
+func SecretTagHandler(ctx *tagContext) error {
+	ctx.engine.setColumnExtra(ctx.col).secret = true
+	return nil
+}
+
+// TreeTagHandler describes tree tag handler, tree(parent) marks the parent
+// column and tree(path) marks the materialized path column of a tree
+func TreeTagHandler(ctx *tagContext) error {
+	if len(ctx.params) != 1 {
+		return fmt.Errorf("field %s tag tree needs parent or path", ctx.col.FieldName)
+	}
+	mode := strings.ToLower(strings.TrimSpace(ctx.params[0]))
+	if mode != treeParent && mode != treePath {
+		return fmt.Errorf("field %s tag tree needs parent or path", ctx.col.FieldName)
+	}
+	ctx.engine.setColumnExtra(ctx.col).tree = mode
+	return nil
+}
+
+// NoExtendsTagHandler describes noextends tag handler, an anonymous embedded
+// struct with this tag is mapped as a single column instead of being flattened
+func NoExtendsTagHandler(ctx *tagContext) error {
+	return nil
+}
+
 // CacheTagHandler describes cache tag handler
 func CacheTagHandler(ctx *tagContext) error {
 	if !ctx.hasCacheTag {
@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"testing"
@@ -606,3 +607,125 @@ func TestExtends5(t *testing.T) {
 		}
 	}
 }
+
+type EmbedTimestamps struct {
+	Created time.Time `xorm:"created"`
+	Updated time.Time `xorm:"updated"`
+}
+
+type EmbedBaseModel struct {
+	Id   int64
+	Name string
+	EmbedTimestamps
+}
+
+type EmbedAuthor struct {
+	Name string
+}
+
+type EmbedArticle struct {
+	EmbedBaseModel
+	*EmbedAuthor
+	Title string
+	Name  string
+}
+
+type EmbedNoExtends struct {
+	Id          int64
+	EmbedAuthor `xorm:"noextends"`
+}
+
+func TestEmbeddedStructFlatten(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(EmbedArticle))
+
+	table := testEngine.TableInfo(new(EmbedArticle))
+	assert.EqualValues(t, []string{
+		colMapper.Obj2Table("Id"),
+		colMapper.Obj2Table("Created"),
+		colMapper.Obj2Table("Updated"),
+		colMapper.Obj2Table("Title"),
+		colMapper.Obj2Table("Name"),
+	}, table.ColumnsSeq())
+	assert.EqualValues(t, []string{colMapper.Obj2Table("Id")}, table.PrimaryKeys)
+	assert.EqualValues(t, "Name", table.GetColumn(colMapper.Obj2Table("Name")).FieldName)
+
+	var article = EmbedArticle{
+		Title: "title",
+		Name:  "article",
+	}
+	cnt, err := testEngine.Insert(&article)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.True(t, article.Id > 0)
+	assert.False(t, article.Created.IsZero())
+
+	var article2 EmbedArticle
+	has, err := testEngine.Where("title = ?", "title").Get(&article2)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, article.Id, article2.Id)
+	assert.EqualValues(t, "article", article2.Name)
+	assert.False(t, article2.Created.IsZero())
+
+	cnt, err = testEngine.Update(&EmbedArticle{Title: "title2"}, &EmbedArticle{
+		EmbedBaseModel: EmbedBaseModel{Id: article.Id},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var articles []EmbedArticle
+	assert.NoError(t, testEngine.Find(&articles, &EmbedArticle{Title: "title2"}))
+	assert.EqualValues(t, 1, len(articles))
+	assert.EqualValues(t, article.Id, articles[0].Id)
+
+	table = testEngine.TableInfo(new(EmbedNoExtends))
+	assert.EqualValues(t, 2, len(table.Columns()))
+	assert.EqualValues(t, "EmbedAuthor", table.Columns()[1].FieldName)
+}
+
+type EmbedNullName struct {
+	Id int64
+	sql.NullString
+}
+
+type EmbedWithExtends struct {
+	Id        int64
+	Author    EmbedAuthor `xorm:"extends"`
+	EmbedPerson
+}
+
+type EmbedPerson struct {
+	Name string
+	Age  int
+}
+
+func TestEmbeddedStructShadowing(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	// the embedded types implementing sql.Scanner are mapped as a single column
+	table := testEngine.TableInfo(new(EmbedNullName))
+	assert.EqualValues(t, []string{
+		colMapper.Obj2Table("Id"),
+		colMapper.Obj2Table("NullString"),
+	}, table.ColumnsSeq())
+
+	// the promoted name is ambiguous with the name of the extends
+	table = testEngine.TableInfo(new(EmbedWithExtends))
+	assert.EqualValues(t, []string{
+		colMapper.Obj2Table("Id"),
+		colMapper.Obj2Table("Name"),
+		colMapper.Obj2Table("Age"),
+	}, table.ColumnsSeq())
+	assert.EqualValues(t, "Author.Name", table.GetColumn(colMapper.Obj2Table("Name")).FieldName)
+
+	assertSync(t, new(EmbedWithExtends))
+	_, err := testEngine.Insert(&EmbedWithExtends{Author: EmbedAuthor{Name: "a"}, EmbedPerson: EmbedPerson{Name: "b", Age: 3}})
+	assert.NoError(t, err)
+	var got EmbedWithExtends
+	has, err := testEngine.Get(&got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "a", got.Author.Name)
+	assert.EqualValues(t, 3, got.Age)
+}
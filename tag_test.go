@@ -540,6 +540,7 @@ func TestSplitTag(t *testing.T) {
 		{"TEXT", []string{"TEXT"}},
 		{"default('2000-01-01 00:00:00')", []string{"default('2000-01-01 00:00:00')"}},
 		{"json  binary", []string{"json", "binary"}},
+		{"expr(price * (quantity + 1)) index", []string{"expr(price * (quantity + 1))", "index"}},
 	}
 
 	for _, kase := range cases {
@@ -598,3 +599,59 @@ func TestTagPrimarykey(t *testing.T) {
 	assert.True(t, col2.IsPrimaryKey)
 	assert.False(t, col2.IsAutoIncrement)
 }
+
+func TestTagExpr(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type TagExpr struct {
+		Id       int64
+		Price    int `xorm:"'price'"`
+		Quantity int `xorm:"'quantity'"`
+		Total    int `xorm:"'total' expr(price * quantity)"`
+	}
+
+	assertSync(t, new(TagExpr))
+
+	tables, err := testEngine.DBMetas()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(tables))
+	assert.Nil(t, tables[0].GetColumn("total"))
+
+	// sync again should not add the computed column
+	assert.NoError(t, testEngine.Sync2(new(TagExpr)))
+
+	cnt, err := testEngine.Insert(&[]TagExpr{
+		{Price: 2, Quantity: 3, Total: 100},
+		{Price: 5, Quantity: 6},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	var te TagExpr
+	has, err := testEngine.Where("price = ?", 2).Get(&te)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 6, te.Total)
+
+	cnt, err = testEngine.ID(te.Id).Update(&TagExpr{Quantity: 10, Total: 1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var tes []TagExpr
+	assert.NoError(t, testEngine.Desc("total").Find(&tes))
+	assert.EqualValues(t, 2, len(tes))
+	assert.EqualValues(t, 30, tes[0].Total)
+	assert.EqualValues(t, 20, tes[1].Total)
+
+	tes = nil
+	assert.NoError(t, testEngine.OrderBy("total").Find(&tes, &TagExpr{Total: 20}))
+	assert.EqualValues(t, 1, len(tes))
+	assert.EqualValues(t, 10, tes[0].Quantity)
+
+	var totals []int
+	assert.NoError(t, testEngine.Asc("total").Iterate(new(TagExpr), func(idx int, bean interface{}) error {
+		totals = append(totals, bean.(*TagExpr).Total)
+		return nil
+	}))
+	assert.EqualValues(t, []int{20, 30}, totals)
+}
@@ -1 +1,2 @@
-go test -db=mymysql -conn_str="xorm_test/root/"
\ No newline at end of file
+go test -db=mymysql -conn_str="xorm_test/root/"
+go test ./conformance -db=mymysql -conn_str="xorm_test/root/"
\ No newline at end of file
@@ -1 +1,2 @@
-go test -db=mysql -conn_str="root:@/xorm_test"
\ No newline at end of file
+go test -db=mysql -conn_str="root:@/xorm_test"
+go test ./conformance -db=mysql -conn_str="root:@/xorm_test"
\ No newline at end of file
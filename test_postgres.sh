@@ -1 +1,2 @@
-go test -db=postgres -conn_str="dbname=xorm_test sslmode=disable"
\ No newline at end of file
+go test -db=postgres -conn_str="dbname=xorm_test sslmode=disable"
+go test ./conformance -db=postgres -conn_str="dbname=xorm_test sslmode=disable"
\ No newline at end of file
@@ -1 +1,2 @@
-go test -db=sqlite3 -conn_str="./test.db?cache=shared&mode=rwc"
\ No newline at end of file
+go test -db=sqlite3 -conn_str="./test.db?cache=shared&mode=rwc"
+go test ./conformance -db=sqlite3 -conn_str="./conformance.sqlite3"
\ No newline at end of file
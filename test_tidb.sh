@@ -1 +1,2 @@
-go test -db=mysql -conn_str="root:@tcp(localhost:4000)/xorm_test" -ignore_select_update=true
\ No newline at end of file
+go test -db=mysql -conn_str="root:@tcp(localhost:4000)/xorm_test" -ignore_select_update=true
+go test ./conformance -db=mysql -conn_str="root:@tcp(localhost:4000)/xorm_test"
\ No newline at end of file